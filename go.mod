@@ -8,7 +8,6 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/prometheus/client_golang v1.23.0
 	github.com/rabbitmq/amqp091-go v1.10.0
-	github.com/streadway/amqp v1.1.0
 )
 
 require (
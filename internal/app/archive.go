@@ -0,0 +1,53 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/service"
+)
+
+type RestoreArchiveRequest struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Limit int       `json:"limit"`
+}
+
+type RestoreArchiveResponse struct {
+	Restored int `json:"restored"`
+}
+
+// RestoreArchive moves up to limit of the caller's archived messages created
+// in [from, to) back into PostgreSQL.
+func (s *Server) RestoreArchive(w http.ResponseWriter, r *http.Request) {
+	if s.archiveService == nil {
+		http.Error(w, "Archiving is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	var req RestoreArchiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.To.After(req.From) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 || req.Limit > service.MaxRestoreBatch {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d, or 0 for the default", service.MaxRestoreBatch), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := tenantIDFromContext(r.Context())
+	restored, err := s.archiveService.Restore(r.Context(), tenantID, req.From, req.To, req.Limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RestoreArchiveResponse{Restored: restored})
+}
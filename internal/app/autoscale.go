@@ -0,0 +1,75 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/consumer"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// AutoscaleDecisionsResponse lists a tenant's recent scaling decisions.
+type AutoscaleDecisionsResponse struct {
+	Decisions []consumer.ScalingDecision `json:"decisions"`
+}
+
+// UpdateAutoscale replaces a tenant's autoscale policy and applies it to
+// the tenant's running consumer.
+func (s *Server) UpdateAutoscale(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	var policy models.AutoscalePolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		cfg.Autoscale = &policy
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.tenantManager.SetAutoscalePolicy(tenantID, &policy); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenant)
+}
+
+// GetAutoscaleDecisions returns the autoscaler's recent decisions for a
+// tenant, oldest first.
+func (s *Server) GetAutoscaleDecisions(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := s.tenantService.GetTenantByID(r.Context(), tenantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AutoscaleDecisionsResponse{Decisions: s.autoscaler.Decisions(tenantID)})
+}
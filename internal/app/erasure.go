@@ -0,0 +1,84 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/gorilla/mux"
+)
+
+type ErasureRequest struct {
+	SubjectHeader string `json:"subject_header"`
+	SubjectValue  string `json:"subject_value"`
+}
+
+type VerifyCertificateResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// RequestErasure starts an asynchronous erasure of the tenant's data, or of
+// one subject's data when a subject header and value are given.
+func (s *Server) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["id"]
+	if tenantID != tenantIDFromContext(r.Context()) {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	var req ErasureRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if (req.SubjectHeader == "") != (req.SubjectValue == "") {
+		http.Error(w, "subject_header and subject_value must be given together", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.erasureService.RequestErasure(r.Context(), tenantID, req.SubjectHeader, req.SubjectValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/erasure/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetErasureJob reports an erasure job's status and, once completed, its
+// signed certificate.
+func (s *Server) GetErasureJob(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenantIDFromContext(r.Context())
+
+	job, err := s.erasureService.GetJob(r.Context(), tenantID, mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Erasure job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// VerifyErasureCertificate checks a certificate's signature.
+func (s *Server) VerifyErasureCertificate(w http.ResponseWriter, r *http.Request) {
+	var cert models.ErasureCertificate
+	if err := json.NewDecoder(r.Body).Decode(&cert); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VerifyCertificateResponse{Valid: s.erasureService.VerifyCertificate(&cert)})
+}
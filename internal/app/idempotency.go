@@ -0,0 +1,32 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
+// requestFingerprint hashes the parts of msg that come from the request, so
+// a retried publish can be told apart from a different one reusing its key.
+func requestFingerprint(msg *models.Message) string {
+	body, _ := json.Marshal(struct {
+		Payload         []byte            `json:"payload"`
+		ContentType     string            `json:"content_type"`
+		ContentEncoding string            `json:"content_encoding"`
+		CorrelationID   string            `json:"correlation_id"`
+		CausationID     string            `json:"causation_id"`
+		RoutingKey      string            `json:"routing_key"`
+		OrderingKey     string            `json:"ordering_key,omitempty"`
+		Priority        uint8             `json:"priority"`
+		Headers         models.Headers    `json:"headers"`
+		Attributes      models.Attributes `json:"attributes"`
+	}{msg.Payload, msg.ContentType, msg.ContentEncoding, msg.CorrelationID, msg.CausationID,
+		msg.RoutingKey, msg.OrderingKey, msg.Priority, msg.Headers, msg.Attributes})
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
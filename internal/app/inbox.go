@@ -0,0 +1,46 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// UpdateInbox replaces a tenant's inbox policy and applies it to the
+// tenant's running consumers.
+func (s *Server) UpdateInbox(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	var policy models.InboxPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		cfg.Inbox = &policy
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.tenantManager.SetInboxPolicy(tenantID, &policy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenant)
+}
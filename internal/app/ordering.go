@@ -0,0 +1,50 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+type UpdateOrderingRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// UpdateOrdering turns ordered processing on or off for a tenant. Workers
+// are laid out when the tenant's consumers start, so the new setting
+// applies once they are next started.
+func (s *Server) UpdateOrdering(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateOrderingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		cfg.OrderedProcessing = req.Enabled
+		return cfg.CheckModes()
+	})
+	if errors.Is(err, models.ErrConflictingModes) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenant)
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// TenantResponse is a tenant with the state of its consumers. State is
+// the lifecycle state of the consumers in this process, empty if they are
+// not running here.
+type TenantResponse struct {
+	models.Tenant
+	Paused bool   `json:"paused"`
+	State  string `json:"state,omitempty"`
+}
+
+type ListTenantsResponse struct {
+	Tenants []TenantResponse `json:"tenants"`
+}
+
+func (s *Server) newTenantResponse(tenant models.Tenant) TenantResponse {
+	return TenantResponse{
+		Tenant: tenant,
+		Paused: tenant.Config.Paused,
+		State:  s.tenantManager.TenantState(tenant.ID),
+	}
+}
+
+// ListTenants returns the tenants visible to the caller, which is only the
+// caller's own tenant.
+func (s *Server) ListTenants(w http.ResponseWriter, r *http.Request) {
+	resp := ListTenantsResponse{Tenants: []TenantResponse{}}
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		resp.Tenants = append(resp.Tenants, s.newTenantResponse(*tenant))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetTenant returns the caller's tenant.
+func (s *Server) GetTenant(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.newTenantResponse(*tenant))
+}
+
+// PauseTenant stops consuming a tenant's messages, which keep accumulating
+// in its queues until it is resumed. The paused state survives restarts.
+func (s *Server) PauseTenant(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+// ResumeTenant restores consumption of a paused tenant's messages.
+func (s *Server) ResumeTenant(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
+
+func (s *Server) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		cfg.Paused = paused
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if paused {
+		err = s.tenantManager.Pause(tenantID)
+	} else {
+		err = s.tenantManager.Resume(tenantID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.newTenantResponse(*tenant))
+}
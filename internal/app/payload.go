@@ -0,0 +1,480 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// maxPayloadBytes bounds the size of a publish request body.
+const maxPayloadBytes = 10 << 20
+
+// messageHeaderPrefix marks HTTP headers that become message headers on raw
+// and multipart publishes, e.g. "X-Message-Header-Customer-Id: c-42".
+const messageHeaderPrefix = "X-Message-Header-"
+
+// PublishMessageRequest is the JSON form of a publish. Exactly one of
+// Payload, PayloadBase64 and Content carries the body: Payload for JSON (or,
+// with a text/* content type, a JSON string), PayloadBase64 for binary data
+// and Content for plain text. ExpiresAt (RFC 3339) or TTL (a duration from
+// now) sets when the message expires.
+type PublishMessageRequest struct {
+	Payload         json.RawMessage        `json:"payload,omitempty"`
+	PayloadBase64   string                 `json:"payload_base64,omitempty"`
+	Content         *string                `json:"content,omitempty"`
+	ContentType     string                 `json:"content_type"`
+	ContentEncoding string                 `json:"content_encoding"`
+	CorrelationID   string                 `json:"correlation_id"`
+	CausationID     string                 `json:"causation_id"`
+	RoutingKey      string                 `json:"routing_key"`
+	OrderingKey     string                 `json:"ordering_key"`
+	Priority        int                    `json:"priority"`
+	Headers         map[string]string      `json:"headers"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	ExpiresAt       string                 `json:"expires_at,omitempty"`
+	TTL             string                 `json:"ttl,omitempty"`
+	ScheduleRequest
+}
+
+// MessageResponse is the JSON representation of a message. JSON payloads
+// are embedded as-is and text payloads as a string in Payload; anything
+// else is returned base64-encoded in PayloadBase64.
+type MessageResponse struct {
+	ID               string                  `json:"id"`
+	TenantID         string                  `json:"tenant_id"`
+	Payload          json.RawMessage         `json:"payload,omitempty"`
+	PayloadBase64    string                  `json:"payload_base64,omitempty"`
+	ContentType      string                  `json:"content_type"`
+	ContentEncoding  string                  `json:"content_encoding,omitempty"`
+	CorrelationID    string                  `json:"correlation_id,omitempty"`
+	CausationID      string                  `json:"causation_id,omitempty"`
+	RoutingKey       string                  `json:"routing_key,omitempty"`
+	OrderingKey      string                  `json:"ordering_key,omitempty"`
+	Priority         uint8                   `json:"priority"`
+	Headers          models.Headers          `json:"headers,omitempty"`
+	Attributes       models.Attributes       `json:"attributes,omitempty"`
+	DeliverAt        *time.Time              `json:"deliver_at,omitempty"`
+	ExpiresAt        *time.Time              `json:"expires_at,omitempty"`
+	Status           string                  `json:"status"`
+	StatusTimestamps models.StatusTimestamps `json:"status_timestamps,omitempty"`
+	DeliveryAttempts int                     `json:"delivery_attempts"`
+	CreatedAt        time.Time               `json:"created_at"`
+	UpdatedAt        time.Time               `json:"updated_at"`
+}
+
+// parsePublishRequest builds a message from a JSON, multipart or raw
+// binary publish request.
+func parsePublishRequest(w http.ResponseWriter, r *http.Request) (*models.Message, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPayloadBytes)
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/octet-stream"
+	}
+
+	switch {
+	case mediaType == "application/json":
+		var req PublishMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %w", err)
+		}
+		return req.toMessage()
+
+	case mediaType == "multipart/form-data":
+		return parseMultipartPublish(r)
+
+	default:
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payload: %w", err)
+		}
+		req, err := metadataFromHTTPHeaders(r.Header)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := req.metadataMessage()
+		if err != nil {
+			return nil, err
+		}
+		msg.Payload = payload
+		msg.ContentType = r.Header.Get("Content-Type")
+		if msg.ContentType == "" {
+			msg.ContentType = "application/octet-stream"
+		}
+		return msg, nil
+	}
+}
+
+// parseMultipartPublish reads a "payload" file part and an optional
+// "metadata" part holding a PublishMessageRequest without a body.
+func parseMultipartPublish(r *http.Request) (*models.Message, error) {
+	if err := r.ParseMultipartForm(maxPayloadBytes); err != nil {
+		return nil, fmt.Errorf("invalid multipart body: %w", err)
+	}
+
+	req, err := metadataFromHTTPHeaders(r.Header)
+	if err != nil {
+		return nil, err
+	}
+	if metadata := r.FormValue("metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &req); err != nil {
+			return nil, fmt.Errorf("invalid metadata part: %w", err)
+		}
+	}
+
+	file, header, err := r.FormFile("payload")
+	if err != nil {
+		return nil, errors.New("multipart body must contain a payload part")
+	}
+	defer file.Close()
+	payload, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload part: %w", err)
+	}
+
+	req.Payload, req.PayloadBase64, req.Content = nil, "", nil
+	msg, err := req.metadataMessage()
+	if err != nil {
+		return nil, err
+	}
+	msg.Payload = payload
+	if msg.ContentType == "" {
+		msg.ContentType = header.Header.Get("Content-Type")
+	}
+	if msg.ContentType == "" {
+		msg.ContentType = "application/octet-stream"
+	}
+	return msg, nil
+}
+
+// metadataFromHTTPHeaders reads message metadata sent alongside a raw body.
+func metadataFromHTTPHeaders(h http.Header) (PublishMessageRequest, error) {
+	req := PublishMessageRequest{
+		ContentEncoding: h.Get("Content-Encoding"),
+		CorrelationID:   h.Get("X-Correlation-ID"),
+		CausationID:     h.Get("X-Causation-ID"),
+		RoutingKey:      h.Get("X-Routing-Key"),
+		OrderingKey:     h.Get("X-Ordering-Key"),
+		ExpiresAt:       h.Get("X-Expires-At"),
+		TTL:             h.Get("X-TTL"),
+		ScheduleRequest: ScheduleRequest{
+			DeliverAt: h.Get("X-Deliver-At"),
+			Delay:     h.Get("X-Delay"),
+			Timezone:  h.Get("X-Timezone"),
+		},
+	}
+	if attrs := h.Get("X-Message-Attributes"); attrs != "" {
+		if err := json.Unmarshal([]byte(attrs), &req.Attributes); err != nil {
+			return req, errors.New("X-Message-Attributes must be a JSON object")
+		}
+	}
+	if priority := h.Get("X-Priority"); priority != "" {
+		// An unparseable value becomes -1 so toMessage rejects it
+		if n, err := strconv.Atoi(priority); err == nil {
+			req.Priority = n
+		} else {
+			req.Priority = -1
+		}
+	}
+	for name, values := range h {
+		if suffix, ok := strings.CutPrefix(name, messageHeaderPrefix); ok && len(values) > 0 {
+			if req.Headers == nil {
+				req.Headers = map[string]string{}
+			}
+			req.Headers[strings.ToLower(suffix)] = values[0]
+		}
+	}
+	return req, nil
+}
+
+// toMessage builds a message from a JSON publish, which must carry exactly
+// one of payload, payload_base64 and content.
+func (req *PublishMessageRequest) toMessage() (*models.Message, error) {
+	msg, err := req.metadataMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := 0
+	if req.Payload != nil {
+		bodies++
+		if msg.ContentType == "" {
+			msg.ContentType = "application/json"
+		}
+		switch {
+		case isJSONType(msg.ContentType):
+			msg.Payload = []byte(req.Payload)
+		case isTextType(msg.ContentType):
+			var text string
+			if err := json.Unmarshal(req.Payload, &text); err != nil {
+				return nil, errors.New("payload for a text content type must be a JSON string")
+			}
+			msg.Payload = []byte(text)
+		default:
+			return nil, fmt.Errorf("use payload_base64 for %s payloads", msg.ContentType)
+		}
+	}
+	if req.PayloadBase64 != "" {
+		bodies++
+		payload, err := base64.StdEncoding.DecodeString(req.PayloadBase64)
+		if err != nil {
+			return nil, errors.New("payload_base64 is not valid base64")
+		}
+		msg.Payload = payload
+		if msg.ContentType == "" {
+			msg.ContentType = "application/octet-stream"
+		}
+	}
+	if req.Content != nil {
+		bodies++
+		msg.Payload = []byte(*req.Content)
+		if msg.ContentType == "" {
+			msg.ContentType = "text/plain"
+		}
+	}
+	switch {
+	case bodies == 0:
+		return nil, errors.New("one of payload, payload_base64 and content is required")
+	case bodies > 1:
+		return nil, errors.New("only one of payload, payload_base64 and content may be set")
+	}
+	return msg, nil
+}
+
+// metadataMessage validates everything but the body and returns a message
+// without a payload, which raw and multipart publishes fill in.
+func (req *PublishMessageRequest) metadataMessage() (*models.Message, error) {
+	for name := range req.Headers {
+		if strings.HasPrefix(strings.ToLower(name), "x-") {
+			return nil, errors.New("header names starting with x- are reserved")
+		}
+	}
+
+	if req.Priority < 0 || req.Priority > 255 {
+		return nil, errors.New("priority must be between 0 and 255")
+	}
+	if len(req.RoutingKey) > 255 {
+		return nil, errors.New("routing key must be at most 255 bytes")
+	}
+	if len(req.OrderingKey) > 255 {
+		return nil, errors.New("ordering key must be at most 255 bytes")
+	}
+
+	now := time.Now()
+	deliverAt, err := req.ScheduleRequest.deliveryTime(now)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := req.expiryTime(now)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, errors.New("expires_at must be in the future")
+		}
+		if deliverAt != nil && !expiresAt.After(*deliverAt) {
+			return nil, errors.New("message would expire before its delivery time")
+		}
+	}
+
+	msg := &models.Message{
+		DeliverAt:       deliverAt,
+		ExpiresAt:       expiresAt,
+		ContentType:     req.ContentType,
+		ContentEncoding: req.ContentEncoding,
+		CorrelationID:   req.CorrelationID,
+		CausationID:     req.CausationID,
+		RoutingKey:      req.RoutingKey,
+		OrderingKey:     req.OrderingKey,
+		Priority:        uint8(req.Priority),
+		Headers:         req.Headers,
+		Attributes:      req.Attributes,
+	}
+	return msg, nil
+}
+
+// expiryTime resolves ExpiresAt or TTL relative to now. It returns nil when
+// the message does not expire.
+func (req *PublishMessageRequest) expiryTime(now time.Time) (*time.Time, error) {
+	if req.ExpiresAt != "" && req.TTL != "" {
+		return nil, errors.New("only one of expires_at and ttl may be set")
+	}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid ttl %q", req.TTL)
+		}
+		t := now.Add(ttl)
+		return &t, nil
+	}
+	if req.ExpiresAt == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expires_at %q", req.ExpiresAt)
+	}
+	return &t, nil
+}
+
+// newMessageResponse renders msg for a JSON response.
+func newMessageResponse(msg *models.Message) MessageResponse {
+	resp := MessageResponse{
+		ID:               msg.ID,
+		TenantID:         msg.TenantID,
+		ContentType:      msg.ContentType,
+		ContentEncoding:  msg.ContentEncoding,
+		CorrelationID:    msg.CorrelationID,
+		CausationID:      msg.CausationID,
+		RoutingKey:       msg.RoutingKey,
+		OrderingKey:      msg.OrderingKey,
+		Priority:         msg.Priority,
+		Headers:          msg.Headers,
+		Attributes:       msg.Attributes,
+		DeliverAt:        msg.DeliverAt,
+		ExpiresAt:        msg.ExpiresAt,
+		Status:           msg.Status,
+		StatusTimestamps: msg.StatusTimestamps,
+		DeliveryAttempts: msg.DeliveryAttempts,
+		CreatedAt:        msg.CreatedAt,
+		UpdatedAt:        msg.UpdatedAt,
+	}
+
+	resp.Payload, resp.PayloadBase64 = renderPayload(msg.Payload, msg.ContentType, msg.ContentEncoding)
+	return resp
+}
+
+// renderPayload returns payload as embeddable JSON if it is uncompressed
+// JSON or UTF-8 text, and otherwise base64-encoded.
+func renderPayload(payload []byte, contentType, contentEncoding string) (json.RawMessage, string) {
+	identity := contentEncoding == "" || contentEncoding == "identity"
+	switch {
+	case identity && isJSONType(contentType) && json.Valid(payload):
+		return payload, ""
+	case identity && isTextType(contentType) && utf8.Valid(payload):
+		text, _ := json.Marshal(string(payload))
+		return text, ""
+	default:
+		return nil, base64.StdEncoding.EncodeToString(payload)
+	}
+}
+
+// writeRawMessage writes the payload bytes as the response body, with the
+// message metadata in response headers.
+func writeRawMessage(w http.ResponseWriter, msg *models.Message) {
+	h := w.Header()
+	h.Set("Content-Type", msg.ContentType)
+	if msg.ContentEncoding != "" {
+		h.Set("Content-Encoding", msg.ContentEncoding)
+	}
+	h.Set("X-Message-ID", msg.ID)
+	h.Set("X-Created-At", msg.CreatedAt.Format(time.RFC3339Nano))
+	if msg.CorrelationID != "" {
+		h.Set("X-Correlation-ID", msg.CorrelationID)
+	}
+	if msg.CausationID != "" {
+		h.Set("X-Causation-ID", msg.CausationID)
+	}
+	if msg.RoutingKey != "" {
+		h.Set("X-Routing-Key", msg.RoutingKey)
+	}
+	if msg.OrderingKey != "" {
+		h.Set("X-Ordering-Key", msg.OrderingKey)
+	}
+	h.Set("X-Priority", strconv.Itoa(int(msg.Priority)))
+	if msg.ExpiresAt != nil {
+		h.Set("X-Expires-At", msg.ExpiresAt.Format(time.RFC3339Nano))
+	}
+	h.Set("X-Message-Status", msg.Status)
+	for name, value := range msg.Headers {
+		h.Set(messageHeaderPrefix+textproto.CanonicalMIMEHeaderKey(name), value)
+	}
+	if len(msg.Attributes) > 0 {
+		attrs, _ := json.Marshal(msg.Attributes)
+		h.Set("X-Message-Attributes", string(attrs))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(msg.Payload)))
+	w.Write(msg.Payload)
+}
+
+func isJSONType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isTextType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(mediaType, "text/")
+}
+
+// negotiate returns the offer best matching the Accept header, or "" if
+// none is acceptable. Each offer takes the quality of the most specific
+// range matching it, so "application/json;q=0, */*" refuses JSON; ties go
+// to the offer matched more specifically, then to the earlier offer. A
+// missing Accept header accepts the first offer.
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type rangeSpec struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []rangeSpec
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, rangeSpec{mediaType, q})
+	}
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		offerType, _, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+		q, specificity := 0.0, -1
+		for _, rg := range ranges {
+			// */* scores 0, type/* 1 and an exact type 2
+			if s := 2 - strings.Count(rg.mediaType, "*"); s > specificity && mediaRangeMatches(rg.mediaType, offerType) {
+				q, specificity = rg.q, s
+			}
+		}
+		if q > bestQ || (q > 0 && q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+func mediaRangeMatches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "image/png", "application/octet-stream"}
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"no accept header", "", "application/json"},
+		{"exact match", "image/png", "image/png"},
+		{"wildcard", "*/*", "application/json"},
+		{"subtype wildcard", "image/*", "image/png"},
+		{"quality order", "application/json;q=0.5, image/png", "image/png"},
+		{"specific range over wildcard", "*/*, application/octet-stream", "application/octet-stream"},
+		{"zero quality excluded", "application/json;q=0, */*;q=0.1", "image/png"},
+		{"earlier offer on ties", "image/*, application/*", "application/json"},
+		{"invalid quality ignored", "image/png;q=x", "image/png"},
+		{"malformed ranges skipped", "/;;, image/png", "image/png"},
+		{"nothing acceptable", "text/html", ""},
+		{"everything refused", "*/*;q=0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiate(tt.accept, offers); got != tt.want {
+				t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPayload(t *testing.T) {
+	tests := []struct {
+		name            string
+		payload         string
+		contentType     string
+		contentEncoding string
+		wantJSON        string
+		wantBase64      string
+	}{
+		{name: "json", payload: `{"a":1}`, contentType: "application/json", wantJSON: `{"a":1}`},
+		{name: "json suffix", payload: `[1]`, contentType: "application/vnd.orders+json; charset=utf-8", wantJSON: `[1]`},
+		{name: "identity encoding", payload: `{"a":1}`, contentType: "application/json", contentEncoding: "identity", wantJSON: `{"a":1}`},
+		{name: "invalid json", payload: `{"a":`, contentType: "application/json", wantBase64: "eyJhIjo="},
+		{name: "text", payload: `say "hi"`, contentType: "text/plain", wantJSON: `"say \"hi\""`},
+		{name: "invalid utf-8 text", payload: "\xff", contentType: "text/plain", wantBase64: "/w=="},
+		{name: "compressed json", payload: `{}`, contentType: "application/json", contentEncoding: "gzip", wantBase64: "e30="},
+		{name: "binary", payload: "\x00\x01", contentType: "application/octet-stream", wantBase64: "AAE="},
+		{name: "missing content type", payload: "abc", wantBase64: "YWJj"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotJSON, gotBase64 := renderPayload([]byte(tt.payload), tt.contentType, tt.contentEncoding)
+			if string(gotJSON) != tt.wantJSON || gotBase64 != tt.wantBase64 {
+				t.Errorf("renderPayload = %s, %q, want %s, %q", gotJSON, gotBase64, tt.wantJSON, tt.wantBase64)
+			}
+		})
+	}
+}
+
+func TestToMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         string
+		wantType    string
+		wantPayload string
+		wantErr     string
+	}{
+		{name: "json payload", req: `{"payload": {"a": 1}}`, wantType: "application/json", wantPayload: `{"a": 1}`},
+		{name: "json null payload", req: `{"payload": null}`, wantType: "application/json", wantPayload: `null`},
+		{name: "text payload", req: `{"payload": "hi", "content_type": "text/plain"}`, wantType: "text/plain", wantPayload: "hi"},
+		{name: "text payload not a string", req: `{"payload": 1, "content_type": "text/plain"}`, wantErr: "must be a JSON string"},
+		{name: "binary type needs base64", req: `{"payload": {}, "content_type": "image/png"}`, wantErr: "use payload_base64 for image/png"},
+		{name: "base64", req: `{"payload_base64": "AAE="}`, wantType: "application/octet-stream", wantPayload: "\x00\x01"},
+		{name: "invalid base64", req: `{"payload_base64": "!"}`, wantErr: "not valid base64"},
+		{name: "content", req: `{"content": "hi"}`, wantType: "text/plain", wantPayload: "hi"},
+		{name: "empty content", req: `{"content": ""}`, wantType: "text/plain", wantPayload: ""},
+		{name: "no body", req: `{"routing_key": "orders"}`, wantErr: "one of payload, payload_base64 and content is required"},
+		{name: "two bodies", req: `{"payload": {}, "content": "hi"}`, wantErr: "only one of"},
+		{name: "reserved header", req: `{"content": "hi", "headers": {"X-Trace": "1"}}`, wantErr: "reserved"},
+		{name: "priority too high", req: `{"content": "hi", "priority": 256}`, wantErr: "priority must be between 0 and 255"},
+		{name: "routing key too long", req: `{"content": "hi", "routing_key": "` + strings.Repeat("a", 256) + `"}`, wantErr: "routing key"},
+		{name: "expired", req: `{"content": "hi", "expires_at": "2000-01-01T00:00:00Z"}`, wantErr: "must be in the future"},
+		{name: "expiry and ttl", req: `{"content": "hi", "expires_at": "2100-01-01T00:00:00Z", "ttl": "1h"}`, wantErr: "only one of expires_at and ttl"},
+		{name: "invalid ttl", req: `{"content": "hi", "ttl": "-1h"}`, wantErr: "invalid ttl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req PublishMessageRequest
+			if err := json.Unmarshal([]byte(tt.req), &req); err != nil {
+				t.Fatalf("invalid test request: %v", err)
+			}
+			msg, err := req.toMessage()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("toMessage error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toMessage: %v", err)
+			}
+			if msg.ContentType != tt.wantType {
+				t.Errorf("content type = %q, want %q", msg.ContentType, tt.wantType)
+			}
+			if msg.Payload == nil || string(msg.Payload) != tt.wantPayload {
+				t.Errorf("payload = %q, want %q", msg.Payload, tt.wantPayload)
+			}
+		})
+	}
+}
+
+func TestMetadataFromHTTPHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Routing-Key", "orders.created")
+	h.Set("X-Priority", "7")
+	h.Set("X-Message-Attributes", `{"region": "EU"}`)
+	h.Set("X-Message-Header-Customer-Id", "c-42")
+	req, err := metadataFromHTTPHeaders(h)
+	if err != nil {
+		t.Fatalf("metadataFromHTTPHeaders: %v", err)
+	}
+	if req.RoutingKey != "orders.created" || req.Priority != 7 {
+		t.Errorf("routing key, priority = %q, %d, want orders.created, 7", req.RoutingKey, req.Priority)
+	}
+	if req.Attributes["region"] != "EU" {
+		t.Errorf("attributes = %v, want region EU", req.Attributes)
+	}
+	if req.Headers["customer-id"] != "c-42" {
+		t.Errorf("headers = %v, want customer-id c-42", req.Headers)
+	}
+
+	for _, attrs := range []string{`{"region":`, `["EU"]`} {
+		h.Set("X-Message-Attributes", attrs)
+		if _, err := metadataFromHTTPHeaders(h); err == nil {
+			t.Errorf("X-Message-Attributes %s was accepted", attrs)
+		}
+	}
+}
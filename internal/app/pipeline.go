@@ -0,0 +1,250 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/transform"
+	"github.com/gorilla/mux"
+)
+
+// CreatePipelineVersionRequest adds a version to a subscription's
+// pipeline. Activate defaults to true.
+type CreatePipelineVersionRequest struct {
+	Steps    []models.TransformStep `json:"steps"`
+	Activate *bool                  `json:"activate"`
+}
+
+// ActivatePipelineRequest selects the version applied to deliveries; 0
+// disables the pipeline.
+type ActivatePipelineRequest struct {
+	Version int `json:"version"`
+}
+
+// PreviewPipelineRequest transforms a sample message. Steps, if given, are
+// previewed instead of a stored version; Version defaults to the active one.
+type PreviewPipelineRequest struct {
+	Message PublishMessageRequest  `json:"message"`
+	Steps   []models.TransformStep `json:"steps"`
+	Version int                    `json:"version"`
+}
+
+// PipelinePreviewResponse is the transformed sample message.
+type PipelinePreviewResponse struct {
+	// Version is the stored version applied, or 0 for unsaved steps or
+	// no pipeline.
+	Version       int             `json:"version"`
+	ContentType   string          `json:"content_type"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	PayloadBase64 string          `json:"payload_base64,omitempty"`
+}
+
+// GetPipeline returns a subscription's pipeline with its versions.
+func (s *Server) GetPipeline(w http.ResponseWriter, r *http.Request) {
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if tenant.Config.FindSubscription(name) < 0 {
+		writeSubscriptionError(w, models.ErrSubscriptionNotFound)
+		return
+	}
+	p := tenant.Config.Pipelines[name]
+	if p == nil {
+		p = &models.Pipeline{Versions: []models.PipelineVersion{}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// CreatePipelineVersion stores a new version of a subscription's pipeline
+// and, unless told otherwise, activates it.
+func (s *Server) CreatePipelineVersion(w http.ResponseWriter, r *http.Request) {
+	var req CreatePipelineVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := transform.Compile(req.Steps); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	activate := req.Activate == nil || *req.Activate
+
+	tenantID := tenantIDFromContext(r.Context())
+	name := mux.Vars(r)["name"]
+	var version models.PipelineVersion
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		p, err := configPipeline(cfg, name)
+		if err != nil {
+			return err
+		}
+		version = p.AddVersion(req.Steps, time.Now().UTC())
+		if activate {
+			p.Active = version.Version
+		}
+		return nil
+	})
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	if activate {
+		if err := s.tenantManager.SetPipeline(tenantID, name, tenant.Config.Pipelines[name]); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/subscriptions/%s/pipeline/versions/%d", name, version.Version))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+// GetPipelineVersion returns one version of a subscription's pipeline.
+func (s *Server) GetPipelineVersion(w http.ResponseWriter, r *http.Request) {
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	vars := mux.Vars(r)
+	if tenant.Config.FindSubscription(vars["name"]) < 0 {
+		writeSubscriptionError(w, models.ErrSubscriptionNotFound)
+		return
+	}
+	n, _ := strconv.Atoi(vars["version"])
+	var version *models.PipelineVersion
+	if p := tenant.Config.Pipelines[vars["name"]]; p != nil {
+		version = p.Version(n)
+	}
+	if version == nil {
+		http.Error(w, "Pipeline version not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+// ActivatePipeline switches a subscription to another stored version of
+// its pipeline, e.g. to roll back, or disables it.
+func (s *Server) ActivatePipeline(w http.ResponseWriter, r *http.Request) {
+	var req ActivatePipelineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenantID := tenantIDFromContext(r.Context())
+	name := mux.Vars(r)["name"]
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		p, err := configPipeline(cfg, name)
+		if err != nil {
+			return err
+		}
+		return p.Activate(req.Version)
+	})
+	if errors.Is(err, models.ErrPipelineVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	p := tenant.Config.Pipelines[name]
+	if err := s.tenantManager.SetPipeline(tenantID, name, p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// PreviewPipeline shows what a subscription's handler would receive for a
+// sample message.
+func (s *Server) PreviewPipeline(w http.ResponseWriter, r *http.Request) {
+	var req PreviewPipelineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	msg, err := req.Message.toMessage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if tenant.Config.FindSubscription(name) < 0 {
+		writeSubscriptionError(w, models.ErrSubscriptionNotFound)
+		return
+	}
+
+	steps, version := req.Steps, 0
+	if steps == nil {
+		p := tenant.Config.Pipelines[name]
+		var v *models.PipelineVersion
+		if req.Version != 0 && p != nil {
+			v = p.Version(req.Version)
+		} else if req.Version == 0 {
+			v = p.ActiveVersion()
+		}
+		if req.Version != 0 && v == nil {
+			http.Error(w, "Pipeline version not found", http.StatusNotFound)
+			return
+		}
+		if v != nil {
+			steps, version = v.Steps, v.Version
+		}
+	}
+	pipeline, err := transform.Compile(steps)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(steps) > 0 {
+		if err := pipeline.Apply(msg); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	resp := PipelinePreviewResponse{Version: version, ContentType: msg.ContentType}
+	resp.Payload, resp.PayloadBase64 = renderPayload(msg.Payload, msg.ContentType, msg.ContentEncoding)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// configPipeline returns the pipeline of a subscription in cfg, creating
+// it if needed.
+func configPipeline(cfg *models.TenantConfig, subscription string) (*models.Pipeline, error) {
+	if cfg.FindSubscription(subscription) < 0 {
+		return nil, models.ErrSubscriptionNotFound
+	}
+	if cfg.Pipelines == nil {
+		cfg.Pipelines = make(map[string]*models.Pipeline)
+	}
+	p := cfg.Pipelines[subscription]
+	if p == nil {
+		p = &models.Pipeline{}
+		cfg.Pipelines[subscription] = p
+	}
+	return p, nil
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// RecurringScheduleRequest creates or replaces a recurring schedule. The
+// template takes the publish request's fields, without a delivery time.
+type RecurringScheduleRequest struct {
+	Name           string                `json:"name"`
+	Cron           string                `json:"cron"`
+	Timezone       string                `json:"timezone"`
+	Template       PublishMessageRequest `json:"template"`
+	StartAt        *time.Time            `json:"start_at"`
+	EndAt          *time.Time            `json:"end_at"`
+	MaxOccurrences int                   `json:"max_occurrences"`
+	MisfirePolicy  string                `json:"misfire_policy"`
+	Enabled        *bool                 `json:"enabled"`
+}
+
+// RecurringScheduleResponse shows a schedule with its template payload
+// rendered like a message's.
+type RecurringScheduleResponse struct {
+	*models.RecurringSchedule
+	Template TemplateResponse `json:"template"`
+}
+
+type TemplateResponse struct {
+	Payload         json.RawMessage   `json:"payload,omitempty"`
+	PayloadBase64   string            `json:"payload_base64,omitempty"`
+	ContentType     string            `json:"content_type"`
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	CorrelationID   string            `json:"correlation_id,omitempty"`
+	CausationID     string            `json:"causation_id,omitempty"`
+	RoutingKey      string            `json:"routing_key,omitempty"`
+	OrderingKey     string            `json:"ordering_key,omitempty"`
+	Priority        uint8             `json:"priority"`
+	TTL             string            `json:"ttl,omitempty"`
+	Headers         models.Headers    `json:"headers,omitempty"`
+	Attributes      models.Attributes `json:"attributes,omitempty"`
+}
+
+func newRecurringScheduleResponse(sched *models.RecurringSchedule) RecurringScheduleResponse {
+	tmpl := sched.Template
+	resp := RecurringScheduleResponse{
+		RecurringSchedule: sched,
+		Template: TemplateResponse{
+			ContentType:     tmpl.ContentType,
+			ContentEncoding: tmpl.ContentEncoding,
+			CorrelationID:   tmpl.CorrelationID,
+			CausationID:     tmpl.CausationID,
+			RoutingKey:      tmpl.RoutingKey,
+			OrderingKey:     tmpl.OrderingKey,
+			Priority:        tmpl.Priority,
+			TTL:             tmpl.TTL,
+			Headers:         tmpl.Headers,
+			Attributes:      tmpl.Attributes,
+		},
+	}
+	resp.Template.Payload, resp.Template.PayloadBase64 = renderPayload(tmpl.Payload, tmpl.ContentType, tmpl.ContentEncoding)
+	return resp
+}
+
+func (req *RecurringScheduleRequest) toSchedule(tenantID string) (*models.RecurringSchedule, error) {
+	msg, err := req.Template.toMessage()
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeliverAt != nil {
+		return nil, errors.New("template cannot set deliver_at or delay")
+	}
+	if req.Template.ExpiresAt != "" {
+		return nil, errors.New("template cannot set expires_at; use ttl")
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &models.RecurringSchedule{
+		TenantID: tenantID,
+		Name:     req.Name,
+		Cron:     req.Cron,
+		Timezone: req.Timezone,
+		Template: models.MessageTemplate{
+			Payload:         msg.Payload,
+			ContentType:     msg.ContentType,
+			ContentEncoding: msg.ContentEncoding,
+			CorrelationID:   msg.CorrelationID,
+			CausationID:     msg.CausationID,
+			RoutingKey:      msg.RoutingKey,
+			OrderingKey:     msg.OrderingKey,
+			Priority:        msg.Priority,
+			TTL:             req.Template.TTL,
+			Headers:         msg.Headers,
+			Attributes:      msg.Attributes,
+		},
+		StartAt:        req.StartAt,
+		EndAt:          req.EndAt,
+		MaxOccurrences: req.MaxOccurrences,
+		MisfirePolicy:  req.MisfirePolicy,
+		Enabled:        enabled,
+	}, nil
+}
+
+// CreateRecurringSchedule creates a recurring schedule for the tenant.
+func (s *Server) CreateRecurringSchedule(w http.ResponseWriter, r *http.Request) {
+	var req RecurringScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	sched, err := req.toSchedule(tenantIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.recurringScheduler.Create(r.Context(), sched)
+	if errors.Is(err, service.ErrInvalidSchedule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/schedules/"+sched.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newRecurringScheduleResponse(sched))
+}
+
+// ListRecurringSchedules lists the tenant's recurring schedules.
+func (s *Server) ListRecurringSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := s.recurringScheduler.List(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]RecurringScheduleResponse, 0, len(schedules))
+	for i := range schedules {
+		response = append(response, newRecurringScheduleResponse(&schedules[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRecurringSchedule returns one of the tenant's recurring schedules.
+func (s *Server) GetRecurringSchedule(w http.ResponseWriter, r *http.Request) {
+	sched, err := s.recurringScheduler.Get(r.Context(), tenantIDFromContext(r.Context()), mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecurringScheduleResponse(sched))
+}
+
+// UpdateRecurringSchedule replaces a recurring schedule's definition.
+func (s *Server) UpdateRecurringSchedule(w http.ResponseWriter, r *http.Request) {
+	var req RecurringScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	sched, err := req.toSchedule(tenantIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sched.ID = mux.Vars(r)["id"]
+
+	err = s.recurringScheduler.Update(r.Context(), sched)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidSchedule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRecurringScheduleResponse(sched))
+}
+
+// DeleteRecurringSchedule deletes a recurring schedule and its history.
+func (s *Server) DeleteRecurringSchedule(w http.ResponseWriter, r *http.Request) {
+	err := s.recurringScheduler.Delete(r.Context(), tenantIDFromContext(r.Context()), mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRecurringRuns returns a recurring schedule's run history, newest
+// first.
+func (s *Server) ListRecurringRuns(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	runs, err := s.recurringScheduler.Runs(r.Context(), tenantIDFromContext(r.Context()), mux.Vars(r)["id"], limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []models.RecurringRun{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/service"
+)
+
+// RulesRequest replaces the tenant's routing rules.
+type RulesRequest struct {
+	Rules []models.RoutingRule `json:"rules"`
+}
+
+// TestRulesRequest evaluates routing rules against a sample message. Rules
+// defaults to the tenant's stored rules.
+type TestRulesRequest struct {
+	Message PublishMessageRequest `json:"message"`
+	Rules   []models.RoutingRule  `json:"rules"`
+}
+
+// GetRules returns the tenant's routing rules in evaluation order.
+func (s *Server) GetRules(w http.ResponseWriter, r *http.Request) {
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		writeRulesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RulesRequest{Rules: models.SortRules(tenant.Config.Rules)})
+}
+
+// UpdateRules replaces the tenant's routing rules.
+func (s *Server) UpdateRules(w http.ResponseWriter, r *http.Request) {
+	var req RulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantIDFromContext(r.Context()), func(cfg *models.TenantConfig) error {
+		if err := service.ValidateRules(req.Rules, *cfg); err != nil {
+			return err
+		}
+		cfg.Rules = req.Rules
+		return nil
+	})
+	if err != nil {
+		writeRulesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RulesRequest{Rules: models.SortRules(tenant.Config.Rules)})
+}
+
+// TestRules shows which rules match a sample message and where it would
+// be delivered, without publishing it.
+func (s *Server) TestRules(w http.ResponseWriter, r *http.Request) {
+	var req TestRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	msg, err := req.Message.toMessage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg.TenantID = tenantIDFromContext(r.Context())
+
+	result, err := s.ruleEngine.Test(r.Context(), msg, req.Rules)
+	if err != nil {
+		writeRulesError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeRulesError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidRules):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
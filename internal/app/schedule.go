@@ -0,0 +1,168 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// localTimeLayouts are accepted for deliver_at values without a UTC offset,
+// which are read in the request's timezone.
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ScheduleRequest sets when a message is delivered: either at DeliverAt, an
+// RFC 3339 time or a local time in Timezone (an IANA name, default UTC), or
+// after Delay, a duration such as "30m".
+type ScheduleRequest struct {
+	DeliverAt string `json:"deliver_at,omitempty"`
+	Delay     string `json:"delay,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+}
+
+type ListScheduledResponse struct {
+	Scheduled  []models.ScheduledMessage `json:"scheduled"`
+	NextCursor string                    `json:"next_cursor"`
+}
+
+// deliveryTime resolves the request relative to now. It returns nil when no
+// delivery time was requested.
+func (req ScheduleRequest) deliveryTime(now time.Time) (*time.Time, error) {
+	if req.DeliverAt != "" && req.Delay != "" {
+		return nil, errors.New("only one of deliver_at and delay may be set")
+	}
+
+	if req.Delay != "" {
+		delay, err := time.ParseDuration(req.Delay)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid delay %q", req.Delay)
+		}
+		t := now.Add(delay)
+		return &t, nil
+	}
+
+	if req.DeliverAt == "" {
+		if req.Timezone != "" {
+			return nil, errors.New("timezone requires deliver_at")
+		}
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, req.DeliverAt); err == nil {
+		return &t, nil
+	}
+	loc := time.UTC
+	if req.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(req.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", req.Timezone)
+		}
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, req.DeliverAt, loc); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid deliver_at %q", req.DeliverAt)
+}
+
+// ListScheduled lists the tenant's scheduled messages by delivery time.
+// Only pending ones are listed unless ?status= names another status, or
+// is "all".
+func (s *Server) ListScheduled(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case "":
+		status = models.SchedulePending
+	case "all":
+		status = ""
+	}
+	limit := 50
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	scheduled, next, err := s.scheduler.List(r.Context(), tenantIDFromContext(r.Context()), status, query.Get("cursor"), limit)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if scheduled == nil {
+		scheduled = []models.ScheduledMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ListScheduledResponse{Scheduled: scheduled, NextCursor: next})
+}
+
+// RescheduleMessage moves a pending scheduled message to a new delivery
+// time.
+func (s *Server) RescheduleMessage(w http.ResponseWriter, r *http.Request) {
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	deliverAt, err := req.deliveryTime(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if deliverAt == nil {
+		http.Error(w, "deliver_at or delay is required", http.StatusBadRequest)
+		return
+	}
+
+	tenantID := tenantIDFromContext(r.Context())
+	scheduled, err := s.scheduler.Reschedule(r.Context(), tenantID, mux.Vars(r)["id"], *deliverAt)
+	s.writeScheduled(w, r, scheduled, err)
+}
+
+// CancelScheduledMessage stops a pending scheduled message from being
+// delivered.
+func (s *Server) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenantIDFromContext(r.Context())
+	scheduled, err := s.scheduler.Cancel(r.Context(), tenantID, mux.Vars(r)["id"])
+	s.writeScheduled(w, r, scheduled, err)
+}
+
+// writeScheduled writes the result of changing a schedule. A change that
+// matched nothing is a 404 if the message isn't scheduled and a 409 if it
+// is no longer pending.
+func (s *Server) writeScheduled(w http.ResponseWriter, r *http.Request, scheduled *models.ScheduledMessage, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		current, getErr := s.scheduler.Get(r.Context(), tenantIDFromContext(r.Context()), mux.Vars(r)["id"])
+		if getErr != nil {
+			http.Error(w, "Scheduled message not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Scheduled message is %s", current.Status), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduled)
+}
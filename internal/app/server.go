@@ -2,29 +2,59 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/abiewardani/go-messaging-system/internal/consumer"
 	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
 	"github.com/abiewardani/go-messaging-system/internal/service"
-	"github.com/abiewardani/go-messaging-system/pkg/metrics"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
-	Router         *mux.Router
-	tenantManager  *consumer.TenantManager
-	messageService *service.MessageService
+	Router             *mux.Router
+	tenantManager      *consumer.TenantManager
+	tenantService      *service.TenantService
+	messageService     *service.MessageService
+	retentionJanitor   *service.RetentionJanitor
+	archiveService     *service.ArchiveService
+	erasureService     *service.ErasureService
+	idempotencyService *service.IdempotencyService
+	scheduler          *service.Scheduler
+	recurringScheduler *service.RecurringScheduler
+	ruleEngine         *service.RuleEngine
+	autoscaler         *consumer.Autoscaler
 }
 
 // Request/Response structures
 type CreateTenantRequest struct {
-	Name        string `json:"name"`
-	WorkerCount int32  `json:"worker_count"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	WorkerCount int32                   `json:"worker_count"`
+	MaxPriority int                     `json:"max_priority,omitempty"`
+	Retention   *models.RetentionPolicy `json:"retention,omitempty"`
+	Inbox       *models.InboxPolicy     `json:"inbox,omitempty"`
+	// OrderedProcessing handles messages with the same ordering key one at
+	// a time, in order.
+	OrderedProcessing bool `json:"ordered_processing,omitempty"`
+	// Batch hands messages to handlers in batches.
+	Batch *models.BatchPolicy `json:"batch,omitempty"`
+	// QoS tunes how the tenant's consumers take deliveries.
+	QoS *models.QoSPolicy `json:"qos,omitempty"`
+	// Autoscale adjusts the tenant's workers to its load.
+	Autoscale *models.AutoscalePolicy `json:"autoscale,omitempty"`
+	// Scheduling sets the tenant's share of the shared worker pool.
+	Scheduling *models.SchedulingPolicy `json:"scheduling,omitempty"`
+	// Subscriptions are started with the tenant.
+	Subscriptions []models.Subscription `json:"subscriptions,omitempty"`
 }
 
 type UpdateConcurrencyRequest struct {
@@ -32,16 +62,25 @@ type UpdateConcurrencyRequest struct {
 }
 
 type ListMessagesResponse struct {
-	Messages   []models.Message `json:"messages"`
-	NextCursor string           `json:"next_cursor"`
+	Messages   []MessageResponse `json:"messages"`
+	NextCursor string            `json:"next_cursor"`
 }
 
 // NewServer creates and returns a new Server instance.
-func NewServer(tm *consumer.TenantManager, ms *service.MessageService) *Server {
+func NewServer(tm *consumer.TenantManager, ts *service.TenantService, ms *service.MessageService, rj *service.RetentionJanitor, as *service.ArchiveService, es *service.ErasureService, is *service.IdempotencyService, sc *service.Scheduler, rs *service.RecurringScheduler, re *service.RuleEngine, au *consumer.Autoscaler) *Server {
 	s := &Server{
-		Router:         mux.NewRouter(),
-		tenantManager:  tm,
-		messageService: ms,
+		Router:             mux.NewRouter(),
+		tenantManager:      tm,
+		tenantService:      ts,
+		messageService:     ms,
+		retentionJanitor:   rj,
+		archiveService:     as,
+		erasureService:     es,
+		idempotencyService: is,
+		scheduler:          sc,
+		recurringScheduler: rs,
+		ruleEngine:         re,
+		autoscaler:         au,
 	}
 
 	// Add middleware
@@ -52,9 +91,53 @@ func NewServer(tm *consumer.TenantManager, ms *service.MessageService) *Server {
 	api := s.Router.PathPrefix("/api/v1").Subrouter()
 
 	api.HandleFunc("/tenants", s.CreateTenant).Methods("POST")
+	api.HandleFunc("/tenants", s.ListTenants).Methods("GET")
+	api.HandleFunc("/tenants/{id}", s.GetTenant).Methods("GET")
 	api.HandleFunc("/tenants/{id}", s.DeleteTenant).Methods("DELETE")
+	api.HandleFunc("/tenants/{id}/pause", s.PauseTenant).Methods("POST")
+	api.HandleFunc("/tenants/{id}/resume", s.ResumeTenant).Methods("POST")
 	api.HandleFunc("/tenants/{id}/config/concurrency", s.UpdateConcurrency).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/retention", s.UpdateRetention).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/inbox", s.UpdateInbox).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/priority", s.UpdatePriority).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/ordering", s.UpdateOrdering).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/batch", s.UpdateBatch).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/qos", s.UpdateQoS).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/autoscale", s.UpdateAutoscale).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/config/scheduling", s.UpdateScheduling).Methods("PUT")
+	api.HandleFunc("/tenants/{id}/autoscale/decisions", s.GetAutoscaleDecisions).Methods("GET")
+	api.HandleFunc("/tenants/{id}/erasure", s.RequestErasure).Methods("POST")
+	api.HandleFunc("/erasure/{id}", s.GetErasureJob).Methods("GET")
+	api.HandleFunc("/erasure/certificates/verify", s.VerifyErasureCertificate).Methods("POST")
+	api.HandleFunc("/messages", s.PublishMessage).Methods("POST")
 	api.HandleFunc("/messages", s.ListMessages).Methods("GET")
+	api.HandleFunc("/messages/{id}", s.GetMessage).Methods("GET")
+	api.HandleFunc("/messages/{id}/status", s.GetMessageStatus).Methods("GET")
+	api.HandleFunc("/messages/{id}/timeline", s.GetMessageTimeline).Methods("GET")
+	api.HandleFunc("/scheduled-messages", s.ListScheduled).Methods("GET")
+	api.HandleFunc("/scheduled-messages/{id}", s.RescheduleMessage).Methods("PATCH")
+	api.HandleFunc("/scheduled-messages/{id}", s.CancelScheduledMessage).Methods("DELETE")
+	api.HandleFunc("/schedules", s.CreateRecurringSchedule).Methods("POST")
+	api.HandleFunc("/schedules", s.ListRecurringSchedules).Methods("GET")
+	api.HandleFunc("/schedules/{id}", s.GetRecurringSchedule).Methods("GET")
+	api.HandleFunc("/schedules/{id}", s.UpdateRecurringSchedule).Methods("PUT")
+	api.HandleFunc("/schedules/{id}", s.DeleteRecurringSchedule).Methods("DELETE")
+	api.HandleFunc("/schedules/{id}/runs", s.ListRecurringRuns).Methods("GET")
+	api.HandleFunc("/subscriptions", s.CreateSubscription).Methods("POST")
+	api.HandleFunc("/subscriptions", s.ListSubscriptions).Methods("GET")
+	api.HandleFunc("/subscriptions/{name}", s.GetSubscription).Methods("GET")
+	api.HandleFunc("/subscriptions/{name}", s.UpdateSubscription).Methods("PUT")
+	api.HandleFunc("/subscriptions/{name}", s.DeleteSubscription).Methods("DELETE")
+	api.HandleFunc("/subscriptions/{name}/pipeline", s.GetPipeline).Methods("GET")
+	api.HandleFunc("/subscriptions/{name}/pipeline/versions", s.CreatePipelineVersion).Methods("POST")
+	api.HandleFunc("/subscriptions/{name}/pipeline/versions/{version}", s.GetPipelineVersion).Methods("GET")
+	api.HandleFunc("/subscriptions/{name}/pipeline/active", s.ActivatePipeline).Methods("PUT")
+	api.HandleFunc("/subscriptions/{name}/pipeline/preview", s.PreviewPipeline).Methods("POST")
+	api.HandleFunc("/rules", s.GetRules).Methods("GET")
+	api.HandleFunc("/rules", s.UpdateRules).Methods("PUT")
+	api.HandleFunc("/rules/test", s.TestRules).Methods("POST")
+	api.HandleFunc("/archive/restore", s.RestoreArchive).Methods("POST")
+	api.HandleFunc("/admin/retention", s.RetentionStatus).Methods("GET")
 
 	// Monitoring
 	s.Router.Handle("/metrics", promhttp.Handler())
@@ -70,13 +153,96 @@ func (s *Server) CreateTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.tenantManager.AddTenant(req.Name, req.WorkerCount, nil)
+	if req.Retention != nil {
+		if err := req.Retention.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := models.ValidateMaxPriority(req.MaxPriority); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Inbox != nil {
+		if err := req.Inbox.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Batch != nil {
+		if err := req.Batch.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.QoS != nil {
+		if err := req.QoS.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Autoscale != nil {
+		if err := req.Autoscale.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Scheduling != nil {
+		if err := req.Scheduling.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	names := make(map[string]bool, len(req.Subscriptions))
+	for i := range req.Subscriptions {
+		if err := s.validateSubscription(&req.Subscriptions[i]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if names[req.Subscriptions[i].Name] {
+			http.Error(w, fmt.Sprintf("Duplicate subscription %q", req.Subscriptions[i].Name), http.StatusBadRequest)
+			return
+		}
+		names[req.Subscriptions[i].Name] = true
+	}
+
+	tenant := &models.Tenant{
+		Name:        req.Name,
+		Description: req.Description,
+		Config: models.TenantConfig{
+			WorkerCount:       req.WorkerCount,
+			MaxPriority:       req.MaxPriority,
+			Retention:         req.Retention,
+			Inbox:             req.Inbox,
+			OrderedProcessing: req.OrderedProcessing,
+			Batch:             req.Batch,
+			QoS:               req.QoS,
+			Autoscale:         req.Autoscale,
+			Scheduling:        req.Scheduling,
+			Subscriptions:     req.Subscriptions,
+		},
+	}
+	if err := tenant.Config.CheckModes(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.tenantService.CreateTenant(r.Context(), tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err := s.tenantManager.AddTenant(tenant.ID, tenant.Config, nil)
 	if err != nil {
+		if delErr := s.tenantService.DeleteTenant(r.Context(), tenant.ID); delErr != nil {
+			log.Printf("Failed to roll back tenant %s: %v", tenant.ID, delErr)
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tenant)
 }
 
 func (s *Server) DeleteTenant(w http.ResponseWriter, r *http.Request) {
@@ -84,7 +250,7 @@ func (s *Server) DeleteTenant(w http.ResponseWriter, r *http.Request) {
 	tenantID := vars["id"]
 
 	// Get tenant ID from context (set by auth middleware)
-	ctxTenantID := r.Context().Value("tenant_id").(string)
+	ctxTenantID := tenantIDFromContext(r.Context())
 	if tenantID != ctxTenantID {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
@@ -95,12 +261,19 @@ func (s *Server) DeleteTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.tenantService.DeleteTenant(r.Context(), tenantID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) UpdateConcurrency(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	tenantID := vars["id"]
+	tenantID, ok := authorizedTenantID(w, r)
+	if !ok {
+		return
+	}
 
 	var req UpdateConcurrencyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -109,22 +282,98 @@ func (s *Server) UpdateConcurrency(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate worker count
-	if req.WorkerCount < 1 || req.WorkerCount > 10 {
-		http.Error(w, "Worker count must be between 1 and 10", http.StatusBadRequest)
+	if req.WorkerCount < 1 || req.WorkerCount > models.MaxWorkerCount {
+		http.Error(w, fmt.Sprintf("Worker count must be between 1 and %d", models.MaxWorkerCount), http.StatusBadRequest)
 		return
 	}
 
-	tenant := s.tenantManager.GetTenant(tenantID)
-	if tenant == nil {
+	tenant, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		cfg.WorkerCount = req.WorkerCount
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
 		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.tenantManager.SetWorkerCount(tenantID, req.WorkerCount); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Update metrics before changing worker count
-	metrics.WorkerCount.WithLabelValues(tenantID).Set(float64(req.WorkerCount))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenant)
+}
 
-	// TODO: Implement worker count update in TenantManager
-	w.WriteHeader(http.StatusOK)
+func (s *Server) PublishMessage(w http.ResponseWriter, r *http.Request) {
+	message, err := parsePublishRequest(w, r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message.TenantID = tenantIDFromContext(r.Context())
+
+	// Retries carrying the same Idempotency-Key get the original response
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > maxIdempotencyKeyLength {
+		http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+		return
+	}
+	if key != "" {
+		message.IdempotencyKey = key
+		original, err := s.idempotencyService.Begin(r.Context(), message.TenantID, key, requestFingerprint(message))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyKeyReused):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, service.ErrIdempotencyKeyInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		case original != nil:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(original.StatusCode)
+			w.Write(original.Response)
+			return
+		}
+	}
+
+	if err := s.messageService.Publish(r.Context(), message); err != nil {
+		if key != "" {
+			if relErr := s.idempotencyService.Release(context.Background(), message.TenantID, key); relErr != nil {
+				log.Printf("Failed to release idempotency key %q of tenant %s: %v", key, message.TenantID, relErr)
+			}
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := json.Marshal(newMessageResponse(message))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if key != "" {
+		err := s.idempotencyService.Complete(context.Background(), message.TenantID, key, message.ID, http.StatusCreated, response)
+		if err != nil {
+			log.Printf("Failed to record idempotency key %q of tenant %s: %v", key, message.TenantID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(response)
 }
 
 func (s *Server) ListMessages(w http.ResponseWriter, r *http.Request) {
@@ -137,23 +386,72 @@ func (s *Server) ListMessages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tenantID := r.Context().Value("tenant_id").(string)
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.IsMessageStatus(status) {
+		http.Error(w, fmt.Sprintf("Unknown status %q", status), http.StatusBadRequest)
+		return
+	}
 
-	messages, nextCursor, err := s.messageService.ListMessages(r.Context(), tenantID, cursor, limit)
+	tenantID := tenantIDFromContext(r.Context())
+
+	messages, nextCursor, err := s.messageService.ListMessages(r.Context(), tenantID, cursor, status, limit)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	response := ListMessagesResponse{
-		Messages:   messages,
+		Messages:   make([]MessageResponse, 0, len(messages)),
 		NextCursor: nextCursor,
 	}
+	for i := range messages {
+		response.Messages = append(response.Messages, newMessageResponse(&messages[i]))
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+func (s *Server) GetMessage(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenantIDFromContext(r.Context())
+
+	message, err := s.messageService.GetMessage(r.Context(), tenantID, mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The envelope is preferred; the raw payload is served when asked for by
+	// its own content type, as octet-stream, or with ?format=raw
+	var representation string
+	switch r.URL.Query().Get("format") {
+	case "raw":
+		representation = message.ContentType
+	case "envelope":
+		representation = "application/json"
+	default:
+		representation = negotiate(r.Header.Get("Accept"), []string{"application/json", message.ContentType, "application/octet-stream"})
+	}
+
+	switch representation {
+	case "":
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+	case "application/json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newMessageResponse(message))
+	default:
+		writeRawMessage(w, message)
+	}
+}
+
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 	health := struct {
 		Status    string `json:"status"`
@@ -196,9 +494,28 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+type contextKey string
+
+const tenantKey contextKey = "tenant_id"
+
 // contextWithTenantID sets the tenant_id in the context.
 func contextWithTenantID(ctx context.Context, tenantID string) context.Context {
-	type key string
-	const tenantKey key = "tenant_id"
 	return context.WithValue(ctx, tenantKey, tenantID)
 }
+
+// tenantIDFromContext returns the tenant_id set by authMiddleware.
+func tenantIDFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey).(string)
+	return tenantID
+}
+
+// authorizedTenantID returns the tenant named by the {id} path variable if
+// it is the caller's own, and otherwise responds with 403.
+func authorizedTenantID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tenantID := mux.Vars(r)["id"]
+	if tenantID != tenantIDFromContext(r.Context()) {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return "", false
+	}
+	return tenantID, true
+}
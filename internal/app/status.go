@@ -0,0 +1,71 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// MessageStatusResponse reports where a message is in its lifecycle.
+type MessageStatusResponse struct {
+	ID               string                  `json:"id"`
+	Status           string                  `json:"status"`
+	StatusTimestamps models.StatusTimestamps `json:"status_timestamps"`
+	DeliveryAttempts int                     `json:"delivery_attempts"`
+}
+
+// GetMessageStatus returns a message's current status and when it entered
+// each status.
+func (s *Server) GetMessageStatus(w http.ResponseWriter, r *http.Request) {
+	message, err := s.messageService.GetMessage(r.Context(), tenantIDFromContext(r.Context()), mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	timestamps := message.StatusTimestamps
+	if timestamps == nil {
+		timestamps = models.StatusTimestamps{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageStatusResponse{
+		ID:               message.ID,
+		Status:           message.Status,
+		StatusTimestamps: timestamps,
+		DeliveryAttempts: message.DeliveryAttempts,
+	})
+}
+
+// MessageTimelineResponse lists everything that happened to a message.
+type MessageTimelineResponse struct {
+	ID     string                `json:"id"`
+	Status string                `json:"status"`
+	Events []models.MessageEvent `json:"events"`
+}
+
+// GetMessageTimeline returns a message's lifecycle events, oldest first.
+func (s *Server) GetMessageTimeline(w http.ResponseWriter, r *http.Request) {
+	message, events, err := s.messageService.Timeline(r.Context(), tenantIDFromContext(r.Context()), mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []models.MessageEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageTimelineResponse{ID: message.ID, Status: message.Status, Events: events})
+}
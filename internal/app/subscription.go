@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// validateSubscription checks a subscription from a request, including that
+// its handler is registered.
+func (s *Server) validateSubscription(sub *models.Subscription) error {
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+	if !s.tenantManager.HasHandler(sub.Handler) {
+		return fmt.Errorf("unknown handler %q", sub.Handler)
+	}
+	return nil
+}
+
+// CreateSubscription adds a subscription to the tenant and starts it.
+func (s *Server) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var sub models.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := s.validateSubscription(&sub); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := tenantIDFromContext(r.Context())
+	_, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		if cfg.FindSubscription(sub.Name) >= 0 {
+			return models.ErrSubscriptionExists
+		}
+		cfg.Subscriptions = append(cfg.Subscriptions, sub)
+		return nil
+	})
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+
+	if err := s.tenantManager.AddSubscription(tenantID, sub); err != nil {
+		if _, rbErr := s.tenantService.UpdateConfig(context.Background(), tenantID, removeSubscription(sub.Name)); rbErr != nil {
+			log.Printf("Failed to roll back subscription %s of tenant %s: %v", sub.Name, tenantID, rbErr)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/subscriptions/"+sub.Name)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// ListSubscriptions lists the tenant's subscriptions.
+func (s *Server) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	subs := tenant.Config.Subscriptions
+	if subs == nil {
+		subs = []models.Subscription{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// GetSubscription returns one of the tenant's subscriptions.
+func (s *Server) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	tenant, err := s.tenantService.GetTenantByID(r.Context(), tenantIDFromContext(r.Context()))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	i := tenant.Config.FindSubscription(mux.Vars(r)["name"])
+	if i < 0 {
+		writeSubscriptionError(w, models.ErrSubscriptionNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tenant.Config.Subscriptions[i])
+}
+
+// UpdateSubscription replaces a subscription's patterns, handler and worker
+// count. Messages already in its queue are kept.
+func (s *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	var sub models.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	sub.Name = mux.Vars(r)["name"]
+	if err := s.validateSubscription(&sub); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := tenantIDFromContext(r.Context())
+	_, err := s.tenantService.UpdateConfig(r.Context(), tenantID, func(cfg *models.TenantConfig) error {
+		i := cfg.FindSubscription(sub.Name)
+		if i < 0 {
+			return models.ErrSubscriptionNotFound
+		}
+		cfg.Subscriptions[i] = sub
+		return nil
+	})
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	if err := s.tenantManager.UpdateSubscription(tenantID, sub); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteSubscription stops a subscription and deletes its queue, discarding
+// any messages still in it.
+func (s *Server) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenantIDFromContext(r.Context())
+	name := mux.Vars(r)["name"]
+
+	_, err := s.tenantService.UpdateConfig(r.Context(), tenantID, removeSubscription(name))
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	if err := s.tenantManager.RemoveSubscription(tenantID, name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func removeSubscription(name string) func(*models.TenantConfig) error {
+	return func(cfg *models.TenantConfig) error {
+		i := cfg.FindSubscription(name)
+		if i < 0 {
+			return models.ErrSubscriptionNotFound
+		}
+		for _, rule := range cfg.Rules {
+			if rule.Subscription == name {
+				return fmt.Errorf("%w: rule %q", models.ErrSubscriptionInUse, rule.Name)
+			}
+		}
+		cfg.Subscriptions = append(cfg.Subscriptions[:i], cfg.Subscriptions[i+1:]...)
+		delete(cfg.Pipelines, name)
+		return nil
+	}
+}
+
+func writeSubscriptionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrSubscriptionNotFound):
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrSubscriptionExists), errors.Is(err, models.ErrSubscriptionInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
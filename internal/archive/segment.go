@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// SegmentKey returns the object key for a tenant's segment, partitioned by
+// tenant and by the day of the segment's oldest message.
+func SegmentKey(tenantID, segmentID string, oldest time.Time) string {
+	return fmt.Sprintf("tenant=%s/date=%s/%s.jsonl.gz", tenantID, oldest.UTC().Format(time.DateOnly), segmentID)
+}
+
+// TenantPrefix returns the key prefix shared by all of a tenant's segments.
+func TenantPrefix(tenantID string) string {
+	return fmt.Sprintf("tenant=%s/", tenantID)
+}
+
+// EncodeSegment serialises messages as gzip-compressed JSON lines.
+func EncodeSegment(messages []models.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, msg := range messages {
+		if err := enc.Encode(msg); err != nil {
+			return nil, fmt.Errorf("failed to encode message %s: %w", msg.ID, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// segmentLine decodes a segment line, including lines written before
+// payloads became bytes, which stored the body as a "content" string.
+type segmentLine struct {
+	models.Message
+	Content *string `json:"content"`
+}
+
+// DecodeSegment reverses EncodeSegment.
+func DecodeSegment(body []byte) ([]models.Message, error) {
+	var messages []models.Message
+	err := ScanSegment(body, func(msg models.Message) bool {
+		messages = append(messages, msg)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ScanSegment calls fn for each message of a segment in stored order,
+// stopping early once fn returns false.
+func ScanSegment(body []byte, fn func(models.Message) bool) error {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var line segmentLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("failed to decode segment line: %w", err)
+		}
+		if line.Payload == nil && line.Content != nil {
+			line.Payload = []byte(*line.Content)
+		}
+		if !fn(line.Message) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// NewSegmentID returns a random (version 4) UUID for a new segment.
+func NewSegmentID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -8,7 +8,132 @@ import (
 type Config struct {
 	DatabaseURL string `json:"database_url"`
 	RabbitMQURL string `json:"rabbitmq_url"`
-	Concurrency  int    `json:"concurrency"`
+	Concurrency int    `json:"concurrency"`
+	// NodeID identifies this server instance in message timelines. It
+	// defaults to the hostname.
+	NodeID string `json:"node_id"`
+	// AutoMigrate applies pending schema migrations when the server starts.
+	AutoMigrate  bool              `json:"auto_migrate"`
+	Partitioning PartitionConfig   `json:"partitioning"`
+	Retention    RetentionConfig   `json:"retention"`
+	Archive      ArchiveConfig     `json:"archive"`
+	Erasure      ErasureConfig     `json:"erasure"`
+	Idempotency  IdempotencyConfig `json:"idempotency"`
+	Inbox        InboxConfig       `json:"inbox"`
+	Scheduler    SchedulerConfig   `json:"scheduler"`
+	Consumer     ConsumerConfig    `json:"consumer"`
+	Autoscale    AutoscaleConfig   `json:"autoscale"`
+	Expiry       ExpiryConfig      `json:"expiry"`
+}
+
+// ConsumerConfig controls how tenant consumers handle deliveries.
+type ConsumerConfig struct {
+	// MaxDeliveryAttempts is how many times a message is handed to the
+	// handler before it is dead-lettered.
+	MaxDeliveryAttempts int `json:"max_delivery_attempts"`
+	// SharedPool handles the messages of all tenants with one pool of
+	// Concurrency workers, scheduled fairly between tenants, instead of
+	// dedicated workers per tenant.
+	SharedPool bool `json:"shared_pool"`
+	// DrainTimeoutSeconds is how long stopping a tenant's consumers waits
+	// for the deliveries being handled to finish.
+	DrainTimeoutSeconds int `json:"drain_timeout_seconds"`
+}
+
+// AutoscaleConfig controls the autoscaler of tenant workers.
+type AutoscaleConfig struct {
+	IntervalSeconds int `json:"interval_seconds"`
+	// HistorySize is the number of scaling decisions kept per tenant.
+	HistorySize int `json:"history_size"`
+}
+
+// ExpiryConfig controls recording the expiry of messages RabbitMQ expired
+// in the queue.
+type ExpiryConfig struct {
+	IntervalSeconds int `json:"interval_seconds"`
+	// GraceSeconds is how long after its expiry a queued message is left
+	// for a consumer to dead-letter before it is marked expired.
+	GraceSeconds int `json:"grace_seconds"`
+	BatchSize    int `json:"batch_size"`
+}
+
+// SchedulerConfig controls delivery of scheduled messages and recurring
+// schedules.
+type SchedulerConfig struct {
+	PollIntervalMillis int `json:"poll_interval_millis"`
+	// BatchSize caps the messages claimed per poll.
+	BatchSize int `json:"batch_size"`
+	// LeaseSeconds is how long a claimed message is reserved for one
+	// instance, and so also the delay before a failed delivery is retried.
+	LeaseSeconds int `json:"lease_seconds"`
+	MaxAttempts  int `json:"max_attempts"`
+	// MisfireGraceSeconds is how late a recurring occurrence may run
+	// before the "skip" misfire policy skips it.
+	MisfireGraceSeconds int `json:"misfire_grace_seconds"`
+}
+
+// InboxConfig controls pruning of the consumer deduplication inbox.
+type InboxConfig struct {
+	CleanupIntervalSeconds int `json:"cleanup_interval_seconds"`
+	BatchSize              int `json:"batch_size"`
+}
+
+// IdempotencyConfig controls how long publish idempotency keys are kept.
+type IdempotencyConfig struct {
+	// TTLSeconds is how long a key's response is replayed to retries.
+	TTLSeconds int `json:"ttl_seconds"`
+	// LockTimeoutSeconds is how long an unfinished request holds its key
+	// before another request may take it over.
+	LockTimeoutSeconds     int `json:"lock_timeout_seconds"`
+	CleanupIntervalSeconds int `json:"cleanup_interval_seconds"`
+}
+
+// ErasureConfig controls the tenant data erasure worker.
+type ErasureConfig struct {
+	// SigningKey is the HMAC key used to sign erasure certificates.
+	SigningKey          string `json:"signing_key"`
+	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	BatchSize           int    `json:"batch_size"`
+}
+
+// ArchiveConfig controls moving old messages to cold storage segments.
+type ArchiveConfig struct {
+	Enabled bool `json:"enabled"`
+	// Directory is the root of the local filesystem segment store.
+	Directory string `json:"directory"`
+	// OlderThanDays is the age after which messages are archived.
+	OlderThanDays int `json:"older_than_days"`
+	// SegmentSize is the maximum number of messages per segment file.
+	SegmentSize     int `json:"segment_size"`
+	IntervalSeconds int `json:"interval_seconds"`
+}
+
+// RetentionConfig controls the background job enforcing tenant retention
+// policies.
+type RetentionConfig struct {
+	IntervalSeconds int `json:"interval_seconds"`
+	// BatchSize caps the rows removed per statement.
+	BatchSize int `json:"batch_size"`
+	// BatchPauseMillis is the pause between batches, giving other
+	// transactions a chance at the locks.
+	BatchPauseMillis int `json:"batch_pause_millis"`
+}
+
+// PartitionConfig controls how the messages table is range-partitioned.
+type PartitionConfig struct {
+	// Interval is the width of each range partition: "day", "week" or "month".
+	Interval string `json:"interval"`
+	// Premake is how many future partitions are kept created ahead of time.
+	Premake int `json:"premake"`
+	// RetentionPeriods is how many past partitions are kept; 0 keeps all.
+	RetentionPeriods int `json:"retention_periods"`
+	// DropExpired drops expired partitions instead of only detaching them.
+	DropExpired bool `json:"drop_expired"`
+	// TenantHashPartitions, when > 1, sub-partitions each new range
+	// partition by hash of tenant_id.
+	TenantHashPartitions int `json:"tenant_hash_partitions"`
+	// CheckIntervalSeconds is how often the maintainer runs.
+	CheckIntervalSeconds int `json:"check_interval_seconds"`
 }
 
 func LoadConfig(filePath string) (*Config, error) {
@@ -24,4 +149,4 @@ func LoadConfig(filePath string) (*Config, error) {
 	}
 
 	return config, nil
-}
\ No newline at end of file
+}
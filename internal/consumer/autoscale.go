@@ -0,0 +1,331 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+)
+
+// targetUtilization is the share of time the autoscaler aims to keep
+// workers busy.
+const targetUtilization = 0.75
+
+// ScalingDecision is a change the autoscaler made to a tenant's workers,
+// with the samples it was based on.
+type ScalingDecision struct {
+	At            time.Time `json:"at"`
+	From          int32     `json:"from"`
+	To            int32     `json:"to"`
+	Reason        string    `json:"reason"`
+	QueueDepth    int       `json:"queue_depth"`
+	Utilization   float64   `json:"utilization"`
+	LatencyMillis float64   `json:"latency_millis"`
+	// Error is set if the new worker count could not be applied.
+	Error string `json:"error,omitempty"`
+}
+
+// Autoscaler periodically adjusts the workers of tenants with an autoscale
+// policy to their queue depth, worker utilisation and processing latency.
+type Autoscaler struct {
+	tm  *TenantManager
+	cfg config.AutoscaleConfig
+
+	mu     sync.Mutex
+	states map[string]*scaleState
+}
+
+// scaleState is what the autoscaler remembers about a tenant between
+// samples.
+type scaleState struct {
+	stats     *consumerStats
+	sampledAt time.Time
+	busy      int64
+	calls     int64
+	changedAt time.Time
+	history   []ScalingDecision
+}
+
+// autoscaleTarget is a running tenant with autoscaling enabled.
+type autoscaleTarget struct {
+	tenantID string
+	queue    string
+	workers  int32
+	policy   *models.AutoscalePolicy
+	stats    *consumerStats
+	paused   bool
+}
+
+func NewAutoscaler(tm *TenantManager, cfg config.AutoscaleConfig) *Autoscaler {
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 15
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = 100
+	}
+	return &Autoscaler{tm: tm, cfg: cfg, states: make(map[string]*scaleState)}
+}
+
+// Run samples and scales tenants on every interval until ctx is cancelled.
+func (a *Autoscaler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(a.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.RunOnce(ctx); err != nil {
+				log.Printf("Autoscaler run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce samples every autoscaled tenant and applies the resulting worker
+// counts. A tenant's first sample only sets the baseline for the next.
+func (a *Autoscaler) RunOnce(ctx context.Context) error {
+	targets := a.tm.autoscaleTargets()
+	seen := make(map[string]bool, len(targets))
+	defer a.prune(seen)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	ch, err := a.tm.openChannel()
+	if err != nil {
+		return err
+	}
+	defer func() { ch.Close() }()
+
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		seen[t.tenantID] = true
+		if t.paused {
+			// Sample afresh once resumed
+			a.mu.Lock()
+			if st := a.states[t.tenantID]; st != nil {
+				st.sampledAt = time.Time{}
+			}
+			a.mu.Unlock()
+			continue
+		}
+		q, err := ch.QueueDeclarePassive(t.queue, true, false, false, false, nil)
+		if err != nil {
+			log.Printf("Autoscaler could not sample queue of tenant %s: %v", t.tenantID, err)
+			// The failed declare closed the channel
+			if ch, err = a.tm.openChannel(); err != nil {
+				return err
+			}
+			continue
+		}
+		a.scale(t, q.Messages, time.Now())
+	}
+	return nil
+}
+
+// scale decides on a tenant's worker count from its queue depth and the
+// stats since its last sample, and applies it once the cooldown allows.
+func (a *Autoscaler) scale(t autoscaleTarget, depth int, now time.Time) {
+	a.mu.Lock()
+	st := a.states[t.tenantID]
+	if st == nil {
+		st = &scaleState{}
+		a.states[t.tenantID] = st
+	}
+	busy, calls := t.stats.busy.Load(), t.stats.calls.Load()
+	baseline := st.stats != t.stats || st.sampledAt.IsZero()
+	elapsed := now.Sub(st.sampledAt)
+	dBusy, dCalls := busy-st.busy, calls-st.calls
+	st.stats, st.sampledAt, st.busy, st.calls = t.stats, now, busy, calls
+	if baseline {
+		a.mu.Unlock()
+		return
+	}
+
+	var util, latency float64
+	if t.workers > 0 && elapsed > 0 {
+		util = math.Min(1, float64(dBusy)/(float64(elapsed)*float64(t.workers)))
+	}
+	if dCalls > 0 {
+		latency = float64(dBusy) / float64(dCalls) / float64(time.Millisecond)
+	}
+	metrics.AutoscaleQueueDepth.WithLabelValues(t.tenantID).Set(float64(depth))
+	metrics.AutoscaleUtilization.WithLabelValues(t.tenantID).Set(util)
+	metrics.AutoscaleLatency.WithLabelValues(t.tenantID).Set(latency / 1000)
+
+	to, reason := decide(t.policy, t.workers, depth, util, latency)
+	min, max := t.policy.Bounds()
+	up, down := t.policy.Cooldowns()
+	inBounds := t.workers >= min && t.workers <= max
+	switch {
+	case to == t.workers,
+		inBounds && to > t.workers && now.Sub(st.changedAt) < up,
+		inBounds && to < t.workers && now.Sub(st.changedAt) < down:
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+
+	decision := ScalingDecision{
+		At:            now.UTC(),
+		From:          t.workers,
+		To:            to,
+		Reason:        reason,
+		QueueDepth:    depth,
+		Utilization:   util,
+		LatencyMillis: latency,
+	}
+	if err := a.tm.SetWorkerCount(t.tenantID, to); err != nil {
+		log.Printf("Autoscaler could not scale tenant %s to %d workers: %v", t.tenantID, to, err)
+		decision.Error = err.Error()
+	} else {
+		log.Printf("Autoscaler scaled tenant %s from %d to %d workers (%s)", t.tenantID, t.workers, to, reason)
+		direction := "up"
+		if to < t.workers {
+			direction = "down"
+		}
+		metrics.AutoscaleDecisions.WithLabelValues(t.tenantID, direction).Inc()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st.changedAt = now
+	st.history = append(st.history, decision)
+	if n := len(st.history) - a.cfg.HistorySize; n > 0 {
+		st.history = append(st.history[:0], st.history[n:]...)
+	}
+}
+
+// decide returns the worker count policy calls for and why. Workers are
+// added as needed at once but removed one at a time.
+func decide(p *models.AutoscalePolicy, workers int32, depth int, util, latency float64) (int32, string) {
+	min, max := p.Bounds()
+	switch {
+	case workers < min:
+		return min, "min_workers"
+	case workers > max:
+		return max, "max_workers"
+	}
+
+	need, reason := (depth+p.Backlog()-1)/p.Backlog(), "backlog"
+	if n := int(math.Ceil(util * float64(workers) / targetUtilization)); n > need {
+		need, reason = n, "utilization"
+	}
+	if p.TargetLatencyMillis > 0 && latency > float64(p.TargetLatencyMillis) && util >= targetUtilization && need <= int(workers) {
+		need, reason = int(workers)+1, "latency"
+	}
+	if need < int(workers) {
+		need, reason = int(workers)-1, "underutilized"
+	}
+	if need > int(max) {
+		need = int(max)
+	}
+	return clamp(int32(need), min, max), reason
+}
+
+// Decisions returns the recent scaling decisions for a tenant, oldest
+// first.
+func (a *Autoscaler) Decisions(tenantID string) []ScalingDecision {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	decisions := []ScalingDecision{}
+	if st := a.states[tenantID]; st != nil {
+		decisions = append(decisions, st.history...)
+	}
+	return decisions
+}
+
+// prune forgets tenants that are no longer autoscaled.
+func (a *Autoscaler) prune(seen map[string]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for tenantID := range a.states {
+		if !seen[tenantID] {
+			delete(a.states, tenantID)
+		}
+	}
+}
+
+// autoscaleTargets returns the running tenants with autoscaling enabled.
+func (tm *TenantManager) autoscaleTargets() []autoscaleTarget {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	var targets []autoscaleTarget
+	for tenantID, consumer := range tm.tenants {
+		if p := consumer.autoscale; p != nil && p.Enabled {
+			targets = append(targets, autoscaleTarget{
+				tenantID: tenantID,
+				queue:    consumer.Queue,
+				workers:  consumer.WorkerCount,
+				policy:   p,
+				stats:    consumer.stats,
+				paused:   consumer.paused,
+			})
+		}
+	}
+	return targets
+}
+
+// SetWorkerCount resizes the workers of a running tenant's queue to n in
+// place, see resize. Subscriptions keep their own worker counts.
+func (tm *TenantManager) SetWorkerCount(tenantID string, n int32) error {
+	unlock := tm.lockTenant(tenantID)
+	defer unlock()
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	consumer, exists := tm.tenants[tenantID]
+	if !exists {
+		return fmt.Errorf("tenant %s not found", tenantID)
+	}
+	if consumer.WorkerCount == n {
+		return nil
+	}
+	err := consumer.resize(n)
+	metrics.WorkerCount.WithLabelValues(tenantID).Set(float64(consumer.WorkerCount))
+	if err != nil {
+		tm.setState(tenantID, StateFailed)
+		return fmt.Errorf("failed to resize workers: %w", err)
+	}
+	return nil
+}
+
+// SetAutoscalePolicy changes a running tenant's autoscale policy. A worker
+// count outside the new bounds is corrected right away.
+func (tm *TenantManager) SetAutoscalePolicy(tenantID string, p *models.AutoscalePolicy) error {
+	tm.mu.Lock()
+	consumer, exists := tm.tenants[tenantID]
+	if !exists {
+		tm.mu.Unlock()
+		return fmt.Errorf("tenant %s not found", tenantID)
+	}
+	consumer.autoscale = p
+	workers := consumer.WorkerCount
+	tm.mu.Unlock()
+
+	if p == nil || !p.Enabled {
+		return nil
+	}
+	min, max := p.Bounds()
+	return tm.SetWorkerCount(tenantID, clamp(workers, min, max))
+}
+
+func clamp(n, min, max int32) int32 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
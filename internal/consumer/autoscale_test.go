@@ -0,0 +1,139 @@
+package consumer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+func TestDecide(t *testing.T) {
+	policy := &models.AutoscalePolicy{Enabled: true, MinWorkers: 2, MaxWorkers: 10, TargetLatencyMillis: 200}
+	noLatency := &models.AutoscalePolicy{Enabled: true, MinWorkers: 2, MaxWorkers: 10}
+	tests := []struct {
+		name       string
+		policy     *models.AutoscalePolicy
+		workers    int32
+		depth      int
+		util       float64
+		latency    float64
+		want       int32
+		wantReason string
+	}{
+		{name: "below min", workers: 1, want: 2, wantReason: "min_workers"},
+		{name: "above max", workers: 12, depth: 10000, want: 10, wantReason: "max_workers"},
+		{name: "backlog", workers: 2, depth: 450, want: 5, wantReason: "backlog"},
+		{name: "backlog capped at max", workers: 2, depth: 5000, want: 10, wantReason: "backlog"},
+		{name: "steady backlog", workers: 4, depth: 400, util: 0.5, want: 4, wantReason: "backlog"},
+		{name: "saturated", workers: 4, util: 1, want: 6, wantReason: "utilization"},
+		{name: "utilization over backlog", workers: 4, depth: 300, util: 0.9, want: 5, wantReason: "utilization"},
+		{name: "slow and busy", workers: 4, util: 0.75, latency: 300, want: 5, wantReason: "latency"},
+		{name: "slow but idle", workers: 4, util: 0.5, latency: 300, want: 3, wantReason: "underutilized"},
+		{name: "latency within target", workers: 4, util: 0.75, latency: 150, want: 4, wantReason: "utilization"},
+		{name: "latency without target", policy: noLatency, workers: 4, util: 0.75, latency: 300, want: 4, wantReason: "utilization"},
+		{name: "latency capped at max", workers: 10, util: 0.75, latency: 300, want: 10, wantReason: "latency"},
+		{name: "scale down one at a time", workers: 8, want: 7, wantReason: "underutilized"},
+		{name: "not below min", workers: 2, want: 2, wantReason: "underutilized"},
+		{name: "default backlog", policy: &models.AutoscalePolicy{Enabled: true}, workers: 1, depth: 250, want: 3, wantReason: "backlog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.policy
+			if p == nil {
+				p = policy
+			}
+			got, reason := decide(p, tt.workers, tt.depth, tt.util, tt.latency)
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("decide = %d, %q, want %d, %q", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestScaleCooldown(t *testing.T) {
+	// The tenant is not running, so every applied decision fails, but it is
+	// still recorded and starts the cooldown
+	tm := &TenantManager{tenants: map[string]*TenantConsumer{}, lifecycle: map[string]*sync.Mutex{}}
+	a := NewAutoscaler(tm, config.AutoscaleConfig{HistorySize: 10})
+	policy := &models.AutoscalePolicy{
+		Enabled:                  true,
+		MaxWorkers:               10,
+		ScaleUpCooldownSeconds:   60,
+		ScaleDownCooldownSeconds: 300,
+	}
+	stats := &consumerStats{}
+	start := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name    string
+		at      time.Duration
+		workers int32
+		depth   int
+		stats   *consumerStats
+		want    *ScalingDecision
+	}{
+		{name: "first sample is the baseline", at: 0, workers: 2, depth: 1000},
+		{name: "scale up", at: 15 * time.Second, workers: 2, depth: 1000, want: &ScalingDecision{From: 2, To: 10, Reason: "backlog"}},
+		{name: "up cooldown", at: 30 * time.Second, workers: 2, depth: 1000},
+		{name: "up cooldown over", at: 75 * time.Second, workers: 2, depth: 1000, want: &ScalingDecision{From: 2, To: 10, Reason: "backlog"}},
+		{name: "down cooldown", at: 200 * time.Second, workers: 5},
+		{name: "no change needed", at: 400 * time.Second, workers: 1},
+		{name: "down cooldown over", at: 400 * time.Second, workers: 5, want: &ScalingDecision{From: 5, To: 4, Reason: "underutilized"}},
+		{name: "bounds ignore the cooldown", at: 410 * time.Second, workers: 12, want: &ScalingDecision{From: 12, To: 10, Reason: "max_workers"}},
+		{name: "new stats are a new baseline", at: 500 * time.Second, workers: 2, depth: 1000, stats: &consumerStats{}},
+	}
+	var history int
+	for _, step := range steps {
+		s := stats
+		if step.stats != nil {
+			s = step.stats
+		}
+		target := autoscaleTarget{tenantID: "t1", workers: step.workers, policy: policy, stats: s}
+		a.scale(target, step.depth, start.Add(step.at))
+
+		decisions := a.Decisions("t1")
+		if step.want == nil {
+			if len(decisions) != history {
+				t.Fatalf("%s: got decision %+v, want none", step.name, decisions[len(decisions)-1])
+			}
+			continue
+		}
+		if len(decisions) != history+1 {
+			t.Fatalf("%s: got %d new decisions, want 1", step.name, len(decisions)-history)
+		}
+		history++
+		got := decisions[len(decisions)-1]
+		if got.From != step.want.From || got.To != step.want.To || got.Reason != step.want.Reason {
+			t.Errorf("%s: decision = %d -> %d (%s), want %d -> %d (%s)", step.name,
+				got.From, got.To, got.Reason, step.want.From, step.want.To, step.want.Reason)
+		}
+		if !got.At.Equal(start.Add(step.at)) || got.Error == "" {
+			t.Errorf("%s: decision at %v with error %q, want %v and a not found error", step.name, got.At, got.Error, start.Add(step.at))
+		}
+	}
+}
+
+func TestScaleUtilization(t *testing.T) {
+	tm := &TenantManager{tenants: map[string]*TenantConsumer{}, lifecycle: map[string]*sync.Mutex{}}
+	a := NewAutoscaler(tm, config.AutoscaleConfig{})
+	stats := &consumerStats{}
+	target := autoscaleTarget{tenantID: "t1", workers: 4, policy: &models.AutoscalePolicy{Enabled: true}, stats: stats}
+	start := time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)
+
+	a.scale(target, 0, start)
+	// 4 workers busy for the whole 10 seconds, over 400 calls
+	stats.busy.Add(int64(40 * time.Second))
+	stats.calls.Add(400)
+	a.scale(target, 0, start.Add(10*time.Second))
+
+	decisions := a.Decisions("t1")
+	if len(decisions) != 1 {
+		t.Fatalf("got %d decisions, want 1", len(decisions))
+	}
+	d := decisions[0]
+	if d.Utilization != 1 || d.LatencyMillis != 100 || d.To != 6 || d.Reason != "utilization" {
+		t.Errorf("decision = %+v, want utilization 1, latency 100ms, 6 workers", d)
+	}
+}
@@ -0,0 +1,316 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// BatchHandler is implemented by handlers that can process several messages
+// at once, for tenants with batch handling enabled. Handlers that do not
+// implement it are called once per message of a batch.
+type BatchHandler interface {
+	// ProcessBatch handles msgs. A nil error acks every message; a
+	// *BatchError retries only the messages it lists, and any other error
+	// retries the whole batch. Batches are not handled in an inbox
+	// transaction, so TxFromContext returns nil.
+	ProcessBatch(ctx context.Context, msgs []*models.Message) error
+}
+
+// BatchError reports the messages of a batch that failed, by their index in
+// the batch. The other messages are acked.
+type BatchError struct {
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d messages of the batch failed", len(e.Errors))
+}
+
+// messageBatch adapts a MessageHandler to BatchHandler by handling the
+// messages one at a time.
+type messageBatch struct {
+	MessageHandler
+}
+
+func (h messageBatch) ProcessBatch(ctx context.Context, msgs []*models.Message) error {
+	var failed *BatchError
+	for i, msg := range msgs {
+		if err := h.ProcessMessage(ctx, msg); err != nil {
+			if failed == nil {
+				failed = &BatchError{Errors: make(map[int]error)}
+			}
+			failed.Errors[i] = err
+		}
+	}
+	if failed != nil {
+		return failed
+	}
+	return nil
+}
+
+// batchHandler returns the consumer's handler as a BatchHandler.
+func (tc *TenantConsumer) batchHandler() BatchHandler {
+	if h, ok := tc.handler.(BatchHandler); ok {
+		return h
+	}
+	return messageBatch{tc.handler}
+}
+
+// startBatchWorkers starts WorkerCount workers that each collect batches
+// from their own channel. A multiple ack settles every outstanding delivery
+// of its channel up to the acked one, so workers cannot share a channel.
+// With the shared pool, workers handle each batch on one of its workers.
+func (tc *TenantConsumer) startBatchWorkers() error {
+	for i := int32(0); i < tc.WorkerCount; i++ {
+		if err := tc.addWorker(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBatches collects deliveries into batches of up to the policy's size,
+// waiting at most its max wait after the first one, and handles each batch
+// until the consumer stops. A batch still being collected then is returned
+// to the queue.
+func (tc *TenantConsumer) runBatches(msgs <-chan amqp091.Delivery, w *worker) {
+	defer close(w.done)
+	size := tc.batch.Size()
+	for {
+		var batch []amqp091.Delivery
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				return
+			}
+			batch = append(batch, d)
+		case <-tc.StopChan:
+			reject(msgs)
+			return
+		}
+
+		timer := time.NewTimer(tc.batch.Wait())
+	fill:
+		for len(batch) < size {
+			select {
+			case d, ok := <-msgs:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, d)
+			case <-timer.C:
+				break fill
+			case <-tc.StopChan:
+				timer.Stop()
+				batch[len(batch)-1].Nack(true, true)
+				reject(msgs)
+				return
+			}
+		}
+		timer.Stop()
+		if !tc.runBatch(batch) {
+			reject(msgs)
+			return
+		}
+	}
+}
+
+// runBatch handles batch, on a worker of the shared pool if there is one.
+// It returns false, with the batch returned to the queue, once the
+// consumer is draining.
+func (tc *TenantConsumer) runBatch(batch []amqp091.Delivery) bool {
+	handled := false
+	run := func() {
+		if !tc.inflight.begin() {
+			batch[len(batch)-1].Nack(true, true)
+			return
+		}
+		defer tc.inflight.end()
+		start := time.Now()
+		tc.handleBatch(batch)
+		tc.stats.observe(start)
+		handled = true
+	}
+	if tc.pool == nil {
+		run()
+		return handled
+	}
+	ran := tc.pool.run(tc.TenantID, len(batch), run, func() { batch[len(batch)-1].Nack(true, true) })
+	return ran && handled
+}
+
+// batchItem is a delivery of a batch that is passed to the handler.
+type batchItem struct {
+	d       amqp091.Delivery
+	msg     *models.Message
+	version int
+	// inbox is the message's inbox key if the batch's inbox transaction
+	// recorded it.
+	inbox string
+}
+
+// handleBatch processes a batch of deliveries like handle does one. With the
+// inbox enabled, the batch's messages are recorded in a single transaction,
+// from which the failed ones are released before it commits. Failed messages
+// are settled individually first, then the successful ones are acked
+// together with a single multiple ack.
+func (tc *TenantConsumer) handleBatch(ds []amqp091.Delivery) {
+	metrics.BatchSize.WithLabelValues(tc.TenantID).Observe(float64(len(ds)))
+	ctx := context.Background()
+	policy := tc.inboxPolicy.Load()
+	useInbox := tc.inbox != nil && policy != nil && policy.Enabled
+
+	candidates := make([]batchItem, 0, len(ds))
+	var keys []string
+	for _, d := range ds {
+		msg := messaging.FromDelivery(d)
+		if msg.Expired(time.Now()) {
+			tc.deadLetter(d, msg, reasonExpired, models.MessageExpired, nil)
+			continue
+		}
+		version, err := tc.transform(msg)
+		if err != nil {
+			tc.deadLetter(d, msg, reasonTransformFailed, models.MessageDeadLettered, err)
+			continue
+		}
+		if useInbox && d.MessageId != "" {
+			keys = append(keys, tc.inboxKey(d.MessageId))
+		}
+		candidates = append(candidates, batchItem{d: d, msg: msg, version: version})
+	}
+
+	var tx *sql.Tx
+	var recorded map[string]bool
+	if len(keys) > 0 {
+		var err error
+		if tx, recorded, err = tc.inbox.BeginBatch(ctx, tc.TenantID, keys); err != nil {
+			log.Printf("Failed to check inbox for tenant %s: %v", tc.TenantID, err)
+		}
+	}
+
+	items := make([]batchItem, 0, len(candidates))
+	for _, item := range candidates {
+		d, msg := item.d, item.msg
+		if useInbox && d.MessageId != "" {
+			key := tc.inboxKey(d.MessageId)
+			if tx == nil {
+				d.Nack(false, true)
+				continue
+			}
+			if !recorded[key] {
+				metrics.InboxDuplicates.WithLabelValues(tc.TenantID).Inc()
+				log.Printf("Skipping duplicate message %s for tenant %s", d.MessageId, tc.TenantID)
+				tc.record(d, msg, models.EventDuplicateSkipped, nil)
+				d.Ack(false)
+				continue
+			}
+			// A redelivered copy in the same batch is a duplicate
+			delete(recorded, key)
+			item.inbox = key
+		}
+
+		tc.startAttempt(d, msg)
+		details := models.EventDetails{"redelivered": strconv.FormatBool(d.Redelivered), "batch_size": strconv.Itoa(len(ds))}
+		if item.version > 0 {
+			details["pipeline_version"] = strconv.Itoa(item.version)
+		}
+		tc.record(d, msg, models.EventDeliveryAttempt, details)
+		items = append(items, item)
+	}
+
+	var failures []error
+	if len(items) > 0 {
+		msgs := make([]*models.Message, len(items))
+		for i, item := range items {
+			msgs[i] = item.msg
+		}
+		hctx, cancel := tc.handlerContext(ctx)
+		failures = batchFailures(tc.batchHandler().ProcessBatch(hctx, msgs), len(items))
+		cancel()
+	}
+
+	var commitErr error
+	if tx != nil {
+		commitErr = tc.commitBatch(ctx, tx, items, failures)
+		if commitErr != nil {
+			log.Printf("Failed to commit inbox for tenant %s: %v", tc.TenantID, commitErr)
+		}
+	}
+
+	last := -1
+	for i, item := range items {
+		d, msg := item.d, item.msg
+		if err := failures[i]; err != nil {
+			log.Printf("Failed to process message for tenant %s: %v", tc.TenantID, err)
+			tc.record(d, msg, models.EventHandlerFailed, models.EventDetails{"error": err.Error()})
+			if msg.DeliveryAttempts >= tc.maxAttempts {
+				tc.deadLetter(d, msg, reasonMaxAttempts, models.MessageDeadLettered, err)
+			} else {
+				tc.requeue(d, msg, nil)
+			}
+			continue
+		}
+		if item.inbox != "" && commitErr != nil {
+			tc.requeue(d, msg, models.EventDetails{"error": fmt.Sprintf("failed to commit inbox: %v", commitErr)})
+			continue
+		}
+		tc.record(d, msg, models.EventHandlerSucceeded, nil)
+		tc.transition(context.Background(), msg, models.MessageDelivered)
+		metrics.MessageProcessed.WithLabelValues(tc.TenantID, models.MessageDelivered).Inc()
+		last = i
+	}
+	// Every delivery before the last success has been settled, so one
+	// multiple ack covers exactly the successful ones
+	if last >= 0 {
+		items[last].d.Ack(true)
+	}
+}
+
+// commitBatch releases the inbox records of the failed items of a batch and
+// commits the batch's inbox transaction. tx is rolled back on failure.
+func (tc *TenantConsumer) commitBatch(ctx context.Context, tx *sql.Tx, items []batchItem, failures []error) error {
+	var failed []string
+	for i, item := range items {
+		if item.inbox != "" && failures[i] != nil {
+			failed = append(failed, item.inbox)
+		}
+	}
+	if len(failed) > 0 {
+		if err := tc.inbox.Release(ctx, tx, tc.TenantID, failed); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// batchFailures returns the error of each of the n messages of a batch, or
+// nil for the ones that succeeded.
+func batchFailures(err error, n int) []error {
+	failures := make([]error, n)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		for i, e := range batchErr.Errors {
+			if i >= 0 && i < n {
+				failures[i] = e
+			}
+		}
+		return failures
+	}
+	if err != nil {
+		for i := range failures {
+			failures[i] = err
+		}
+	}
+	return failures
+}
@@ -0,0 +1,72 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// MessageStore records the status of delivered messages.
+type MessageStore interface {
+	// Transition moves msg to status and refreshes msg's status fields,
+	// or returns models.ErrInvalidTransition.
+	Transition(ctx context.Context, msg *models.Message, status string) error
+}
+
+// Dead-letter reasons set by consumers.
+const (
+	reasonExpired     = "expired"
+	reasonMaxAttempts = "max_attempts_exceeded"
+)
+
+// transition records msg's new status. Status tracking never holds up a
+// delivery, so failures are only logged.
+func (tc *TenantConsumer) transition(ctx context.Context, msg *models.Message, status string) {
+	if tc.store == nil {
+		return
+	}
+	err := tc.store.Transition(ctx, msg, status)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		log.Printf("Message %s of tenant %s cannot become %s from its current status", msg.ID, tc.TenantID, status)
+	} else if err != nil {
+		log.Printf("Failed to record status of message %s for tenant %s: %v", msg.ID, tc.TenantID, err)
+	}
+}
+
+// deadLetter publishes a delivery to the tenant's dead-letter queue with
+// reason and, if the handler failed, its error, then records status and
+// acks the delivery.
+func (tc *TenantConsumer) deadLetter(d amqp091.Delivery, msg *models.Message, reason, status string, cause error) {
+	ctx := context.Background()
+
+	publishing := publishingFromDelivery(d)
+	publishing.Expiration = ""
+	publishing.Headers = amqp091.Table{}
+	for k, v := range d.Headers {
+		publishing.Headers[k] = v
+	}
+	publishing.Headers[messaging.HeaderDeadLetterReason] = reason
+	if cause != nil {
+		publishing.Headers[messaging.HeaderDeadLetterError] = cause.Error()
+	}
+	err := tc.Channel.PublishWithContext(ctx, messaging.DeadLetterExchange, messaging.DeadLetterRoutingKey(tc.TenantID), false, false, publishing)
+	if err != nil {
+		log.Printf("Failed to dead-letter message %s for tenant %s: %v", msg.ID, tc.TenantID, err)
+		d.Nack(false, true)
+		return
+	}
+
+	tc.transition(ctx, msg, status)
+	details := models.EventDetails{"reason": reason}
+	if cause != nil {
+		details["error"] = cause.Error()
+	}
+	tc.record(d, msg, status, details)
+	metrics.MessageProcessed.WithLabelValues(tc.TenantID, status).Inc()
+	d.Ack(false)
+}
@@ -0,0 +1,30 @@
+package consumer
+
+import (
+	"context"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// EventRecorder appends to message timelines. Recording is best-effort and
+// never fails a delivery.
+type EventRecorder interface {
+	Record(ctx context.Context, events ...models.MessageEvent)
+}
+
+// record adds an event about delivery d of msg to its timeline, tagged with
+// the consumer and delivery attempt.
+func (tc *TenantConsumer) record(d amqp091.Delivery, msg *models.Message, eventType string, details models.EventDetails) {
+	if tc.events == nil || msg.ID == "" {
+		return
+	}
+	tc.events.Record(context.Background(), models.MessageEvent{
+		TenantID:    tc.TenantID,
+		MessageID:   msg.ID,
+		Type:        eventType,
+		Attempt:     msg.DeliveryAttempts,
+		ConsumerTag: d.ConsumerTag,
+		Details:     details,
+	})
+}
@@ -0,0 +1,36 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Inbox records the messages a tenant has processed, so that redeliveries
+// can be detected.
+type Inbox interface {
+	// Begin starts a transaction recording messageID as processed. It
+	// returns false, and no transaction, if the message was already
+	// recorded.
+	Begin(ctx context.Context, tenantID, messageID string) (*sql.Tx, bool, error)
+	// BeginBatch starts a transaction recording messageIDs as processed,
+	// and returns the ones that were not recorded before.
+	BeginBatch(ctx context.Context, tenantID string, messageIDs []string) (*sql.Tx, map[string]bool, error)
+	// Release removes the records of messageIDs made in tx, for messages
+	// that failed and will be redelivered.
+	Release(ctx context.Context, tx *sql.Tx, tenantID string, messageIDs []string) error
+}
+
+type txKey struct{}
+
+// TxFromContext returns the inbox transaction a message is being handled
+// in, or nil if the tenant's inbox is disabled. Handlers that write through
+// it commit their changes atomically with the inbox record, so a message's
+// effects are applied exactly once.
+func TxFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+func contextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
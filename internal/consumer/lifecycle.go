@@ -0,0 +1,177 @@
+package consumer
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Lifecycle states of a tenant's consumers.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateDraining = "draining"
+	StateStopped  = "stopped"
+	StateFailed   = "failed"
+)
+
+var states = []string{StateStarting, StateRunning, StateDraining, StateStopped, StateFailed}
+
+// inflight counts the deliveries a consumer's workers are handling. Once
+// stopped it admits no more, so a drain waits only for those already
+// started.
+type inflight struct {
+	mu      sync.Mutex
+	n       int
+	stopped bool
+	idle    chan struct{}
+}
+
+// begin admits a delivery for handling, reporting false once stopped.
+func (f *inflight) begin() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return false
+	}
+	f.n++
+	return true
+}
+
+// end marks a delivery admitted by begin as settled.
+func (f *inflight) end() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.n--; f.n == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// stop admits no more deliveries and returns a channel that is closed once
+// those in flight are settled.
+func (f *inflight) stop() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	idle := make(chan struct{})
+	if f.n == 0 {
+		close(idle)
+	} else {
+		f.idle = idle
+	}
+	return idle
+}
+
+// consumerRef is a consumer started on a channel, see consume.
+type consumerRef struct {
+	ch  *amqp091.Channel
+	tag string
+}
+
+// drain stops tc gracefully: its consumers are cancelled so the broker
+// sends no more deliveries, workers return the deliveries they have not
+// started on to the queue, and deliveries being handled are given until
+// deadline to finish and be settled. The channels opened for tc's workers
+// are closed afterwards, returning any unsettled deliveries to the queue;
+// tc.Channel is left open for the caller.
+func (tc *TenantConsumer) drain(deadline time.Time) {
+	for _, c := range tc.consumers {
+		if err := c.ch.Cancel(c.tag, false); err != nil && !c.ch.IsClosed() {
+			log.Printf("Failed to cancel consumer %s: %v", c.tag, err)
+		}
+	}
+	idle := tc.inflight.stop()
+	close(tc.StopChan)
+
+	timer := time.NewTimer(time.Until(deadline))
+	select {
+	case <-idle:
+	case <-timer.C:
+		log.Printf("Timed out draining %s; unsettled deliveries return to the queue", tc.Queue)
+	}
+	timer.Stop()
+	for _, ch := range tc.channels {
+		ch.Close()
+	}
+}
+
+// drainAll drains the consumers of a tenant's queue and subscriptions
+// together.
+func (tc *TenantConsumer) drainAll(deadline time.Time) {
+	var wg sync.WaitGroup
+	for _, sc := range tc.subscriptions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sc.drain(deadline)
+		}()
+	}
+	tc.drain(deadline)
+	wg.Wait()
+}
+
+// lockTenant serializes the operations that stop or start a tenant's
+// consumers, which drain them without holding tm.mu, and returns the
+// unlock function. tm.mu must not be held.
+func (tm *TenantManager) lockTenant(tenantID string) func() {
+	tm.mu.Lock()
+	l := tm.lifecycle[tenantID]
+	if l == nil {
+		l = &sync.Mutex{}
+		tm.lifecycle[tenantID] = l
+	}
+	tm.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// stop drains tc for up to the drain timeout and closes its channel.
+func (tm *TenantManager) stop(tc *TenantConsumer) {
+	tc.drain(time.Now().Add(tm.drainTimeout()))
+	tc.Channel.Close()
+}
+
+func (tm *TenantManager) drainTimeout() time.Duration {
+	return time.Duration(tm.cfg.DrainTimeoutSeconds) * time.Second
+}
+
+// TenantState returns the lifecycle state of a tenant's consumers, or an
+// empty string for an unknown tenant.
+func (tm *TenantManager) TenantState(tenantID string) string {
+	tm.stateMu.Lock()
+	defer tm.stateMu.Unlock()
+	return tm.states[tenantID]
+}
+
+// setState records the lifecycle state of a tenant's consumers.
+func (tm *TenantManager) setState(tenantID, state string) {
+	tm.stateMu.Lock()
+	defer tm.stateMu.Unlock()
+	tm.states[tenantID] = state
+	for _, s := range states {
+		metrics.TenantState.WithLabelValues(tenantID, s).Set(boolGauge(s == state))
+	}
+}
+
+// clearState forgets a removed tenant's state.
+func (tm *TenantManager) clearState(tenantID string) {
+	tm.stateMu.Lock()
+	defer tm.stateMu.Unlock()
+	delete(tm.states, tenantID)
+	for _, s := range states {
+		metrics.TenantState.DeleteLabelValues(tenantID, s)
+	}
+}
+
+// startedState is the state of a tenant once its consumer c has started:
+// stopped if it is paused, running otherwise.
+func startedState(c *TenantConsumer) string {
+	if c.paused {
+		return StateStopped
+	}
+	return StateRunning
+}
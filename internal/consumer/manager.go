@@ -1,40 +1,162 @@
 package consumer
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
 	"github.com/rabbitmq/amqp091-go"
 )
 
 // MessageHandler defines the interface for processing messages
 type MessageHandler interface {
-	ProcessMessage([]byte) error
+	ProcessMessage(ctx context.Context, msg *models.Message) error
 }
 
+// HandlerFunc adapts a function to the MessageHandler interface.
+type HandlerFunc func(ctx context.Context, msg *models.Message) error
+
+// ProcessMessage calls f(ctx, msg).
+func (f HandlerFunc) ProcessMessage(ctx context.Context, msg *models.Message) error {
+	return f(ctx, msg)
+}
+
+// LogHandler is the default handler; it logs each message and acks it.
+var LogHandler = HandlerFunc(func(ctx context.Context, msg *models.Message) error {
+	log.Printf("Tenant %s received message %s (%s)", msg.TenantID, msg.ID, msg.ContentType)
+	return nil
+})
+
+// ErrUnknownHandler is returned for a subscription naming a handler that is
+// not registered.
+var ErrUnknownHandler = errors.New("unknown handler")
+
 // TenantManager manages tenant consumers
 type TenantManager struct {
 	mu       sync.Mutex
 	tenants  map[string]*TenantConsumer
+	handlers map[string]MessageHandler
 	amqpConn *amqp091.Connection
+	inbox    Inbox
+	store    MessageStore
+	events   EventRecorder
+	cfg      config.ConsumerConfig
+	// pool is the shared worker pool, or nil if tenants have dedicated
+	// workers.
+	pool *pool
+	// closed is set once Close starts.
+	closed bool
+
+	// lifecycle holds the lock of each tenant, see lockTenant.
+	lifecycle map[string]*sync.Mutex
+
+	stateMu sync.Mutex
+	states  map[string]string
 }
 
-// TenantConsumer represents a consumer for a specific tenant
+// TenantConsumer represents a consumer for a specific tenant, of either the
+// tenant's own queue or one of its subscriptions.
 type TenantConsumer struct {
-	TenantID    string
-	Channel     *amqp091.Channel
-	Queue       string
-	StopChan    chan struct{}
-	WorkerCount int32
-	handler     MessageHandler
+	TenantID string
+	// Subscription is the name of the subscription consumed, or empty for
+	// the tenant's own queue.
+	Subscription string
+	Channel      *amqp091.Channel
+	Queue        string
+	StopChan     chan struct{}
+	WorkerCount  int32
+	handler      MessageHandler
+	inbox        Inbox
+	inboxPolicy  atomic.Pointer[models.InboxPolicy]
+	store        MessageStore
+	events       EventRecorder
+	maxAttempts  int
+	// queueArgs are the arguments the tenant's queues are declared with.
+	queueArgs amqp091.Table
+	// subscriptions are the running subscriptions of the tenant, kept on
+	// the consumer of its own queue.
+	subscriptions map[string]*TenantConsumer
+	patterns      []string
+	pipeline      atomic.Pointer[pipeline]
+	// ordered selects ordered processing, see startOrderedWorkers.
+	ordered bool
+	// batch selects batch handling, see startBatchWorkers.
+	batch       *models.BatchPolicy
+	qos         *models.QoSPolicy
+	openChannel func() (*amqp091.Channel, error)
+	// autoscale is the tenant's autoscale policy, guarded by the manager's
+	// mutex.
+	autoscale *models.AutoscalePolicy
+	stats     *consumerStats
+	pool      *pool
+	// paused consumers start no workers, see Pause.
+	paused bool
+	// consumers and channels are the consumers started for the workers
+	// and the channels opened for them, which drain cancels and closes.
+	consumers []consumerRef
+	channels  []*amqp091.Channel
+	inflight  inflight
+	// workers are the workers of consumers in plain or batch mode, and
+	// exclusive is the consumer they share if the queue is exclusive.
+	// Ordered and pooled consumers take their replacement consumers from
+	// successors when resized. See resize.
+	workers    []*worker
+	exclusive  <-chan amqp091.Delivery
+	successors *successors
 }
 
-// NewTenantManager creates a new tenant manager
-func NewTenantManager(conn *amqp091.Connection) (*TenantManager, error) {
+// consumerStats accumulates the time a consumer's workers spend handling
+// deliveries. It outlives restarts of the consumer.
+type consumerStats struct {
+	busy  atomic.Int64 // nanoseconds
+	calls atomic.Int64
+}
+
+// observe counts a handler call that started at start.
+func (s *consumerStats) observe(start time.Time) {
+	s.busy.Add(int64(time.Since(start)))
+	s.calls.Add(1)
+}
+
+// NewTenantManager creates a new tenant manager. inbox may be nil, which
+// disables deduplication for every tenant, and store may be nil, in which
+// case message statuses are not recorded and failed messages are retried
+// indefinitely. events may be nil to skip recording timelines. With
+// cfg.SharedPool, tenants share a pool of concurrency workers.
+func NewTenantManager(conn *amqp091.Connection, inbox Inbox, store MessageStore, events EventRecorder, cfg config.ConsumerConfig, concurrency int) (*TenantManager, error) {
+	if cfg.MaxDeliveryAttempts <= 0 {
+		cfg.MaxDeliveryAttempts = 5
+	}
+	if cfg.DrainTimeoutSeconds <= 0 {
+		cfg.DrainTimeoutSeconds = 30
+	}
 	tm := &TenantManager{
-		tenants:  make(map[string]*TenantConsumer),
-		amqpConn: conn,
+		tenants:   make(map[string]*TenantConsumer),
+		handlers:  map[string]MessageHandler{models.DefaultSubscriptionHandler: LogHandler},
+		amqpConn:  conn,
+		inbox:     inbox,
+		store:     store,
+		events:    events,
+		cfg:       cfg,
+		states:    make(map[string]string),
+		lifecycle: make(map[string]*sync.Mutex),
+	}
+	if cfg.SharedPool {
+		if concurrency <= 0 {
+			concurrency = 10
+		}
+		tm.pool = newPool(concurrency)
 	}
 
 	// Start connection monitoring
@@ -43,60 +165,116 @@ func NewTenantManager(conn *amqp091.Connection) (*TenantManager, error) {
 	return tm, nil
 }
 
-// AddTenant implementation with proper error handling
-func (tm *TenantManager) AddTenant(tenantID string, workerCount int32, handler MessageHandler) error {
+// RegisterHandler makes h available to subscriptions under name.
+func (tm *TenantManager) RegisterHandler(name string, h MessageHandler) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.handlers[name] = h
+}
+
+// HasHandler reports whether a handler is registered under name.
+func (tm *TenantManager) HasHandler(name string) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, ok := tm.handlers[name]
+	return ok
+}
+
+// AddTenant starts consuming the tenant's queue and its subscriptions as
+// configured by cfg. The tenant's queue receives every message published to
+// the tenant. A nil handler selects LogHandler.
+func (tm *TenantManager) AddTenant(tenantID string, cfg models.TenantConfig, handler MessageHandler) error {
+	if handler == nil {
+		handler = LogHandler
+	}
+
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
+	if tm.closed {
+		return errors.New("tenant manager is closed")
+	}
 	// Check if tenant already exists
 	if _, exists := tm.tenants[tenantID]; exists {
 		return fmt.Errorf("tenant %s already exists", tenantID)
 	}
+	if tm.TenantState(tenantID) == StateDraining {
+		return fmt.Errorf("tenant %s is being removed", tenantID)
+	}
 
-	// Create channel for tenant
-	ch, err := tm.amqpConn.Channel()
+	tm.setState(tenantID, StateStarting)
+	if err := tm.addTenant(tenantID, cfg, handler); err != nil {
+		tm.setState(tenantID, StateFailed)
+		return err
+	}
+	tm.setState(tenantID, startedState(tm.tenants[tenantID]))
+	return nil
+}
+
+// addTenant is AddTenant with tm.mu held.
+func (tm *TenantManager) addTenant(tenantID string, cfg models.TenantConfig, handler MessageHandler) error {
+	ch, err := tm.openChannel()
 	if err != nil {
-		return fmt.Errorf("failed to create channel: %w", err)
+		return err
 	}
 
-	// Set QoS
-	if err := ch.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
-	); err != nil {
+	if err := declareDeadLetterQueue(ch, tenantID); err != nil {
 		ch.Close()
-		return fmt.Errorf("failed to set QoS: %w", err)
+		return err
 	}
 
-	// Declare queue with dead letter exchange
+	// Declare queue with dead letter exchange and priority levels
 	args := amqp091.Table{
-		"x-dead-letter-exchange":    "dlx",
-		"x-dead-letter-routing-key": fmt.Sprintf("dl.%s", tenantID),
+		"x-dead-letter-exchange":    messaging.DeadLetterExchange,
+		"x-dead-letter-routing-key": messaging.DeadLetterRoutingKey(tenantID),
+		"x-max-priority":            int32(cfg.QueueMaxPriority()),
 	}
 
-	queueName := fmt.Sprintf("tenant_%s_queue", tenantID)
-	q, err := ch.QueueDeclare(
-		queueName, // name
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		args,      // arguments
-	)
+	ch, q, err := tm.declareQueue(ch, messaging.TenantQueueName(tenantID), args)
 	if err != nil {
+		return err
+	}
+	if err := messaging.DeclareTenantExchange(ch, tenantID); err != nil {
 		ch.Close()
-		return fmt.Errorf("failed to declare queue: %w", err)
+		return err
+	}
+	if err := ch.QueueBind(q.Name, "#", messaging.TenantExchangeName(tenantID), false, nil); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to bind queue: %w", err)
 	}
 
 	consumer := &TenantConsumer{
-		TenantID:    tenantID,
-		Channel:     ch,
-		Queue:       q.Name,
-		StopChan:    make(chan struct{}),
-		WorkerCount: workerCount,
-		handler:     handler,
+		TenantID:      tenantID,
+		Channel:       ch,
+		Queue:         q.Name,
+		StopChan:      make(chan struct{}),
+		WorkerCount:   cfg.WorkerCount,
+		handler:       handler,
+		inbox:         tm.inbox,
+		store:         tm.store,
+		events:        tm.events,
+		maxAttempts:   tm.cfg.MaxDeliveryAttempts,
+		queueArgs:     args,
+		subscriptions: make(map[string]*TenantConsumer),
+		ordered:       cfg.OrderedProcessing,
+		qos:           cfg.QoS,
+		openChannel:   tm.openChannel,
+		autoscale:     cfg.Autoscale,
+		stats:         &consumerStats{},
+		pool:          tm.pool,
+		paused:        cfg.Paused,
+	}
+	if tm.pool != nil {
+		tm.pool.setPolicy(tenantID, cfg.Scheduling)
+	}
+	if p := cfg.Autoscale; p != nil && p.Enabled {
+		min, max := p.Bounds()
+		consumer.WorkerCount = clamp(consumer.WorkerCount, min, max)
 	}
+	if cfg.BatchEnabled() && !cfg.OrderedProcessing {
+		consumer.batch = cfg.Batch
+	}
+	consumer.inboxPolicy.Store(cfg.Inbox)
 
 	// Start consumer workers
 	if err := consumer.startWorkers(); err != nil {
@@ -105,58 +283,480 @@ func (tm *TenantManager) AddTenant(tenantID string, workerCount int32, handler M
 	}
 
 	tm.tenants[tenantID] = consumer
+	metrics.WorkerCount.WithLabelValues(tenantID).Set(float64(consumer.WorkerCount))
+	metrics.TenantPaused.WithLabelValues(tenantID).Set(boolGauge(consumer.paused))
+
+	for _, sub := range cfg.Subscriptions {
+		p, err := compilePipeline(cfg.Pipelines[sub.Name])
+		if err == nil {
+			err = tm.startSubscription(consumer, sub, p)
+		}
+		if err != nil {
+			log.Printf("Could not start subscription %s of tenant %s: %v", sub.Name, tenantID, err)
+		}
+	}
 	return nil
 }
 
+// declareQueue declares a tenant queue on ch, returning the channel to keep
+// using.
+func (tm *TenantManager) declareQueue(ch *amqp091.Channel, name string, args amqp091.Table) (*amqp091.Channel, amqp091.Queue, error) {
+	q, err := ch.QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		args,  // arguments
+	)
+	if isPreconditionFailed(err) {
+		// Queue arguments are fixed once declared, so a queue from before
+		// priorities, or with another max priority, keeps its arguments
+		// until it is recreated. The failed declare closed the channel.
+		log.Printf("Queue %s exists with different arguments; keeping them until it is recreated", name)
+		if ch, err = tm.openChannel(); err != nil {
+			return nil, q, err
+		}
+		q, err = ch.QueueDeclarePassive(name, true, false, false, false, nil)
+	}
+	if err != nil {
+		ch.Close()
+		return nil, q, fmt.Errorf("failed to declare queue: %w", err)
+	}
+	return ch, q, nil
+}
+
+// declareDeadLetterQueue declares the dead-letter exchange and the tenant's
+// dead-letter queue bound to it.
+func declareDeadLetterQueue(ch *amqp091.Channel, tenantID string) error {
+	if err := ch.ExchangeDeclare(messaging.DeadLetterExchange, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	dlq := messaging.DeadLetterQueueName(tenantID)
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+	if err := ch.QueueBind(dlq, messaging.DeadLetterRoutingKey(tenantID), messaging.DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// openChannel opens a channel for a tenant consumer. Workers set the
+// channel's prefetch from the tenant's QoS policy when they start.
+func (tm *TenantManager) openChannel() (*amqp091.Channel, error) {
+	ch, err := tm.amqpConn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create channel: %w", err)
+	}
+	return ch, nil
+}
+
+// startWorkers starts tc's workers as its mode calls for. If they cannot
+// all start, the consumers started so far are cancelled.
 func (tc *TenantConsumer) startWorkers() error {
-	for i := int32(0); i < tc.WorkerCount; i++ {
-		msgs, err := tc.Channel.Consume(
-			tc.Queue, // queue
-			fmt.Sprintf("%s-worker-%d", tc.TenantID, i), // consumer
-			false, // auto-ack
-			false, // exclusive
-			false, // no-local
-			false, // no-wait
-			nil,   // args
-		)
+	err := tc.start()
+	if err != nil {
+		for _, c := range tc.consumers {
+			c.ch.Cancel(c.tag, false)
+		}
+		for _, ch := range tc.channels {
+			ch.Close()
+		}
+		tc.consumers, tc.channels = nil, nil
+	}
+	return err
+}
+
+func (tc *TenantConsumer) start() error {
+	if tc.paused || tc.WorkerCount < 1 {
+		return nil
+	}
+	if tc.pool != nil && !tc.ordered && tc.batch == nil {
+		return tc.startPooled()
+	}
+	if tc.ordered {
+		return tc.startOrderedWorkers()
+	}
+	if tc.batch != nil {
+		return tc.startBatchWorkers()
+	}
+	if err := tc.Channel.Qos(tc.qos.Prefetch(), 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+	if tc.qos != nil && tc.qos.Exclusive {
+		// The workers share the one consumer an exclusive queue allows
+		msgs, err := tc.consume(tc.Channel, tc.consumerTag(0))
 		if err != nil {
-			return fmt.Errorf("failed to start consumer: %w", err)
+			return err
+		}
+		tc.exclusive = msgs
+	}
+	for i := int32(0); i < tc.WorkerCount; i++ {
+		if err := tc.addWorker(); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		go func() {
-			for {
-				select {
-				case msg, ok := <-msgs:
-					if !ok {
-						return
-					}
-					if err := tc.handler.ProcessMessage(msg.Body); err != nil {
-						log.Printf("Failed to process message for tenant %s: %v", tc.TenantID, err)
-						msg.Nack(false, true) // Requeue the message
-						continue
-					}
-					msg.Ack(false)
-				case <-tc.StopChan:
-					return
-				}
+// openWorkerChannel opens a channel of tc's own for a worker, with the
+// given prefetch. drain closes it.
+func (tc *TenantConsumer) openWorkerChannel(prefetch int) (*amqp091.Channel, error) {
+	ch, err := tc.openChannel()
+	if err != nil {
+		return nil, err
+	}
+	tc.channels = append(tc.channels, ch)
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+	return ch, nil
+}
+
+// consume starts a consumer of the queue on ch with the tenant's consumer
+// priority and exclusivity.
+func (tc *TenantConsumer) consume(ch *amqp091.Channel, tag string) (<-chan amqp091.Delivery, error) {
+	var args amqp091.Table
+	exclusive := false
+	if tc.qos != nil {
+		exclusive = tc.qos.Exclusive
+		if tc.qos.ConsumerPriority != 0 {
+			args = amqp091.Table{"x-priority": tc.qos.ConsumerPriority}
+		}
+	}
+	msgs, err := ch.Consume(
+		tc.Queue,  // queue
+		tag,       // consumer
+		false,     // auto-ack
+		exclusive, // exclusive
+		false,     // no-local
+		false,     // no-wait
+		args,      // args
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start consumer: %w", err)
+	}
+	if ref := (consumerRef{ch: ch, tag: tag}); !slices.Contains(tc.consumers, ref) {
+		tc.consumers = append(tc.consumers, ref)
+	}
+	return msgs, nil
+}
+
+// runWorker handles deliveries one at a time until the consumer stops or
+// w is removed.
+func (tc *TenantConsumer) runWorker(msgs <-chan amqp091.Delivery, w *worker) {
+	defer close(w.done)
+	for {
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				return
 			}
-		}()
+			if !tc.settle(d) {
+				reject(msgs)
+				return
+			}
+		case <-w.quit:
+			return
+		case <-tc.StopChan:
+			reject(msgs)
+			return
+		}
 	}
+}
+
+// reject returns the deliveries left on msgs to the queue. Once a consumer
+// is cancelled, msgs is closed after the deliveries it buffered, so they
+// are not left unsettled on a channel that stays open.
+func reject(msgs <-chan amqp091.Delivery) {
+	for d := range msgs {
+		d.Nack(false, true)
+	}
+}
+
+// settle processes d unless the consumer is draining, in which case d is
+// returned to the queue untouched. It returns false once the consumer has
+// stopped.
+func (tc *TenantConsumer) settle(d amqp091.Delivery) bool {
+	if !tc.inflight.begin() {
+		d.Nack(false, true)
+		return false
+	}
+	defer tc.inflight.end()
+	return tc.process(d)
+}
+
+// handlerContext returns the context a handler call runs in, bounded by the
+// tenant's processing timeout.
+func (tc *TenantConsumer) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := tc.qos.ProcessingTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// restartTenant restarts the consumers of a running tenant's queue and
+// subscriptions, changed by update. Replacements are swapped in under tm.mu,
+// but the old consumers are drained without it, so a slow drain holds up
+// only this tenant. The replacements keep the queues, bindings and, unless
+// it was closed, the channel of the consumer they replace. tm.mu must not
+// be held.
+func (tm *TenantManager) restartTenant(tenantID string, update func(*TenantConsumer)) error {
+	unlock := tm.lockTenant(tenantID)
+	defer unlock()
+
+	tm.mu.Lock()
+	consumer, exists := tm.tenants[tenantID]
+	if !exists {
+		tm.mu.Unlock()
+		return fmt.Errorf("tenant %s not found", tenantID)
+	}
+	n := replacement(consumer, update)
+	n.subscriptions = make(map[string]*TenantConsumer, len(consumer.subscriptions))
+	for name, sc := range consumer.subscriptions {
+		n.subscriptions[name] = replacement(sc, update)
+	}
+	tm.tenants[tenantID] = n
+	tm.setState(tenantID, StateDraining)
+	tm.mu.Unlock()
+
+	consumer.drainAll(time.Now().Add(tm.drainTimeout()))
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.tenants[tenantID] != n {
+		// Removed or shut down while draining
+		return fmt.Errorf("tenant %s was stopped", tenantID)
+	}
+	tm.setState(tenantID, StateStarting)
+	var errs []error
+	for name, sc := range n.subscriptions {
+		if err := tm.startReplacement(sc); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", name, err))
+		}
+	}
+	if err := tm.startReplacement(n); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		tm.setState(tenantID, StateFailed)
+		return errors.Join(errs...)
+	}
+	tm.setState(tenantID, startedState(n))
 	return nil
 }
 
-// RemoveTenant removes a tenant consumer with cleanup
-func (tm *TenantManager) RemoveTenant(tenantID string) error {
+// replacement returns a copy of tc, changed by update, to consume tc's
+// queue on tc's channel once tc is drained.
+func replacement(tc *TenantConsumer, update func(*TenantConsumer)) *TenantConsumer {
+	n := &TenantConsumer{
+		TenantID:      tc.TenantID,
+		Subscription:  tc.Subscription,
+		Channel:       tc.Channel,
+		Queue:         tc.Queue,
+		StopChan:      make(chan struct{}),
+		WorkerCount:   tc.WorkerCount,
+		handler:       tc.handler,
+		inbox:         tc.inbox,
+		store:         tc.store,
+		events:        tc.events,
+		maxAttempts:   tc.maxAttempts,
+		queueArgs:     tc.queueArgs,
+		subscriptions: tc.subscriptions,
+		patterns:      tc.patterns,
+		ordered:       tc.ordered,
+		batch:         tc.batch,
+		qos:           tc.qos,
+		openChannel:   tc.openChannel,
+		autoscale:     tc.autoscale,
+		stats:         tc.stats,
+		pool:          tc.pool,
+		paused:        tc.paused,
+	}
+	n.inboxPolicy.Store(tc.inboxPolicy.Load())
+	n.pipeline.Store(tc.pipeline.Load())
+	if update != nil {
+		update(n)
+	}
+	return n
+}
+
+// startReplacement starts the workers of a replacement consumer, on a new
+// channel if the drained consumer's channel is gone. However the workers
+// fail to start, n is left with an open channel to manage its queues.
+// tm.mu must be held.
+func (tm *TenantManager) startReplacement(n *TenantConsumer) error {
+	if n.Channel.IsClosed() {
+		ch, err := tm.openChannel()
+		if err != nil {
+			return err
+		}
+		n.Channel = ch
+	}
+	if err := n.startWorkers(); err != nil {
+		// A refused consume closes the channel
+		if n.Channel.IsClosed() {
+			if ch, err := tm.openChannel(); err == nil {
+				n.Channel = ch
+			}
+		}
+		return fmt.Errorf("failed to start workers: %w", err)
+	}
+	return nil
+}
+
+func (tc *TenantConsumer) consumerTag(worker int32) string {
+	if tc.Subscription != "" {
+		return fmt.Sprintf("%s-%s-worker-%d", tc.TenantID, tc.Subscription, worker)
+	}
+	return fmt.Sprintf("%s-worker-%d", tc.TenantID, worker)
+}
+
+// handle processes one delivery, recording the message's status as it
+// goes. Expired messages are dead-lettered without calling the handler, as
+// are messages the subscription's pipeline cannot transform and messages
+// whose handler has failed maxAttempts times; earlier failures are requeued
+// for a retry. It reports whether an ordered consumer must retry the
+// delivery in place. With the inbox enabled the message is recorded
+// in the same transaction the handler runs in, and redeliveries of an
+// already recorded message are acked without calling the handler.
+func (tc *TenantConsumer) handle(d amqp091.Delivery) bool {
+	msg := messaging.FromDelivery(d)
+	if msg.Expired(time.Now()) {
+		tc.deadLetter(d, msg, reasonExpired, models.MessageExpired, nil)
+		return false
+	}
+	version, err := tc.transform(msg)
+	if err != nil {
+		tc.deadLetter(d, msg, reasonTransformFailed, models.MessageDeadLettered, err)
+		return false
+	}
+	ctx := context.Background()
+
+	var tx *sql.Tx
+	if policy := tc.inboxPolicy.Load(); tc.inbox != nil && policy != nil && policy.Enabled && d.MessageId != "" {
+		var first bool
+		var err error
+		tx, first, err = tc.inbox.Begin(ctx, tc.TenantID, tc.inboxKey(d.MessageId))
+		if err != nil {
+			log.Printf("Failed to check inbox for tenant %s: %v", tc.TenantID, err)
+			if tc.ordered {
+				return true
+			}
+			d.Nack(false, true)
+			return false
+		}
+		if !first {
+			metrics.InboxDuplicates.WithLabelValues(tc.TenantID).Inc()
+			log.Printf("Skipping duplicate message %s for tenant %s", d.MessageId, tc.TenantID)
+			tc.record(d, msg, models.EventDuplicateSkipped, nil)
+			d.Ack(false)
+			return false
+		}
+		ctx = contextWithTx(ctx, tx)
+	}
+
+	tc.startAttempt(d, msg)
+	details := models.EventDetails{"redelivered": strconv.FormatBool(d.Redelivered)}
+	if version > 0 {
+		details["pipeline_version"] = strconv.Itoa(version)
+	}
+	tc.record(d, msg, models.EventDeliveryAttempt, details)
+	hctx, cancel := tc.handlerContext(ctx)
+	err = tc.handler.ProcessMessage(hctx, msg)
+	cancel()
+	if err != nil {
+		if tx != nil {
+			tx.Rollback()
+		}
+		log.Printf("Failed to process message for tenant %s: %v", tc.TenantID, err)
+		tc.record(d, msg, models.EventHandlerFailed, models.EventDetails{"error": err.Error()})
+		if msg.DeliveryAttempts >= tc.maxAttempts {
+			tc.deadLetter(d, msg, reasonMaxAttempts, models.MessageDeadLettered, err)
+			return false
+		}
+		return tc.requeue(d, msg, nil)
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			log.Printf("Failed to commit inbox for tenant %s: %v", tc.TenantID, err)
+			return tc.requeue(d, msg, models.EventDetails{"error": fmt.Sprintf("failed to commit inbox: %v", err)})
+		}
+	}
+	tc.record(d, msg, models.EventHandlerSucceeded, nil)
+	tc.transition(context.Background(), msg, models.MessageDelivered)
+	metrics.MessageProcessed.WithLabelValues(tc.TenantID, models.MessageDelivered).Inc()
+	d.Ack(false)
+	return false
+}
+
+// requeue returns a delivery to the queue for another attempt. Ordered
+// consumers keep it instead, so that later messages with its ordering key
+// cannot overtake it, and requeue reports that it must be retried in place.
+func (tc *TenantConsumer) requeue(d amqp091.Delivery, msg *models.Message, details models.EventDetails) bool {
+	if tc.ordered {
+		if tc.Subscription != "" {
+			d.Headers[messaging.HeaderDeliveryAttempt] = int32(msg.DeliveryAttempts)
+		} else {
+			tc.transition(context.Background(), msg, models.MessageQueued)
+		}
+		tc.record(d, msg, models.EventRequeued, details)
+		return true
+	}
+	if tc.Subscription != "" {
+		tc.retrySubscription(d, msg, details)
+		return false
+	}
+	tc.transition(context.Background(), msg, models.MessageQueued)
+	tc.record(d, msg, models.EventRequeued, details)
+	d.Nack(false, true)
+	return false
+}
+
+// SetInboxPolicy changes a running tenant's inbox policy.
+func (tm *TenantManager) SetInboxPolicy(tenantID string, policy *models.InboxPolicy) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	if consumer, ok := tm.tenants[tenantID]; ok {
+		consumer.inboxPolicy.Store(policy)
+		for _, sc := range consumer.subscriptions {
+			sc.inboxPolicy.Store(policy)
+		}
+	}
+}
 
+// RemoveTenant drains a tenant's consumers, then deletes its queues and
+// exchange.
+func (tm *TenantManager) RemoveTenant(tenantID string) error {
+	unlock := tm.lockTenant(tenantID)
+	defer unlock()
+
+	tm.mu.Lock()
 	consumer, exists := tm.tenants[tenantID]
 	if !exists {
+		tm.mu.Unlock()
 		return fmt.Errorf("tenant %s not found", tenantID)
 	}
+	// Drain without holding tm.mu; the draining state keeps the tenant
+	// from being added again meanwhile
+	delete(tm.tenants, tenantID)
+	tm.setState(tenantID, StateDraining)
+	tm.mu.Unlock()
+
+	consumer.drainAll(time.Now().Add(tm.drainTimeout()))
+	if tm.pool != nil {
+		tm.pool.remove(tenantID)
+	}
+	defer tm.clearState(tenantID)
 
-	// Signal workers to stop
-	close(consumer.StopChan)
+	for name, sc := range consumer.subscriptions {
+		sc.Channel.Close()
+		if _, err := consumer.Channel.QueueDelete(sc.Queue, false, false, false); err != nil {
+			return fmt.Errorf("failed to delete queue of subscription %s: %w", name, err)
+		}
+		delete(consumer.subscriptions, name)
+	}
 
 	// Delete queue
 	if _, err := consumer.Channel.QueueDelete(
@@ -167,13 +767,17 @@ func (tm *TenantManager) RemoveTenant(tenantID string) error {
 	); err != nil {
 		return fmt.Errorf("failed to delete queue: %w", err)
 	}
+	if _, err := consumer.Channel.QueueDelete(messaging.DeadLetterQueueName(tenantID), false, false, false); err != nil {
+		return fmt.Errorf("failed to delete dead-letter queue: %w", err)
+	}
+	if err := consumer.Channel.ExchangeDelete(messaging.TenantExchangeName(tenantID), false, false); err != nil {
+		return fmt.Errorf("failed to delete tenant exchange: %w", err)
+	}
 
 	// Close channel
 	if err := consumer.Channel.Close(); err != nil {
 		return fmt.Errorf("failed to close channel: %w", err)
 	}
-
-	delete(tm.tenants, tenantID)
 	return nil
 }
 
@@ -192,20 +796,51 @@ func (tm *TenantManager) monitorConnection() {
 	go func() {
 		for err := range notifyClose {
 			log.Printf("RabbitMQ connection closed: %v", err)
+			tm.mu.Lock()
+			for tenantID := range tm.tenants {
+				tm.setState(tenantID, StateFailed)
+			}
+			tm.mu.Unlock()
 			// TODO: Implement reconnection logic with exponential backoff
 		}
 	}()
 }
 
-// Close cleanly shuts down the TenantManager and all consumers
+// Close drains the consumers of every tenant together, for up to the drain
+// timeout, and closes the connection. Unlike RemoveTenant it keeps the
+// tenants' queues, so messages not handled yet are consumed after a
+// restart.
 func (tm *TenantManager) Close() error {
 	tm.mu.Lock()
-	defer tm.mu.Unlock()
+	tm.closed = true
+	tenants := tm.tenants
+	tm.tenants = make(map[string]*TenantConsumer)
+	for tenantID := range tenants {
+		tm.setState(tenantID, StateDraining)
+	}
+	tm.mu.Unlock()
 
-	for tenantID := range tm.tenants {
-		if err := tm.RemoveTenant(tenantID); err != nil {
-			log.Printf("Error removing tenant %s during shutdown: %v", tenantID, err)
-		}
+	deadline := time.Now().Add(tm.drainTimeout())
+	var wg sync.WaitGroup
+	for tenantID, consumer := range tenants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Wait for a restart in progress to give up on the tenant
+			unlock := tm.lockTenant(tenantID)
+			defer unlock()
+			consumer.drainAll(deadline)
+			for _, sc := range consumer.subscriptions {
+				sc.Channel.Close()
+			}
+			consumer.Channel.Close()
+			tm.setState(tenantID, StateStopped)
+		}()
+	}
+	wg.Wait()
+
+	if tm.pool != nil {
+		tm.pool.close()
 	}
 
 	if err := tm.amqpConn.Close(); err != nil {
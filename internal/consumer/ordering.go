@@ -0,0 +1,178 @@
+package consumer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// maxRetryBackoff caps the pause before an ordered worker retries a failed
+// message in place.
+const maxRetryBackoff = 5 * time.Second
+
+// startOrderedWorkers consumes the queue through a single dispatcher that
+// hands each delivery to one of WorkerCount lanes: by the consistent hash
+// of its ordering key, so a key always lands on the same lane, or round
+// robin for messages without a key. Each lane handles its deliveries one at
+// a time, retrying failures in place so later messages with the same key
+// cannot overtake them. With the shared pool, lanes handle each delivery
+// on one of its workers.
+func (tc *TenantConsumer) startOrderedWorkers() error {
+	workers := int(tc.WorkerCount)
+	// Lanes buffer as many deliveries as the prefetch allows in total, so
+	// a busy lane never blocks the dispatcher
+	if err := tc.Channel.Qos(tc.sharedPrefetch(workers), 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+	msgs, err := tc.consume(tc.Channel, tc.dispatcherTag())
+	if err != nil {
+		return err
+	}
+	tc.successors = newSuccessors()
+	go tc.dispatch(msgs, workers)
+	return nil
+}
+
+// dispatch hands the deliveries of msgs to workers lanes until the
+// consumer stops. When the consumer is resized, msgs is cancelled and
+// dispatch moves on to its successor with a new set of lanes, once the old
+// lanes have handled every delivery of msgs, so no key is overtaken.
+func (tc *TenantConsumer) dispatch(msgs <-chan amqp091.Delivery, workers int) {
+	lanes, running := tc.startLanes(workers)
+	next := 0
+	for {
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				s, ok := tc.successors.pop(tc.StopChan)
+				tc.stopLanes(lanes, running)
+				if !ok {
+					tc.successors.reject()
+					return
+				}
+				msgs, workers, next = s.msgs, s.workers, 0
+				lanes, running = tc.startLanes(workers)
+				continue
+			}
+			var lane int
+			if key, _ := d.Headers[messaging.HeaderOrderingKey].(string); key != "" {
+				lane = laneFor(key, workers)
+			} else {
+				lane = next
+				next = (next + 1) % workers
+			}
+			lanes[lane] <- d
+		case <-tc.StopChan:
+			reject(msgs)
+			tc.successors.reject()
+			tc.stopLanes(lanes, running)
+			return
+		}
+	}
+}
+
+// startLanes starts workers lanes.
+func (tc *TenantConsumer) startLanes(workers int) ([]chan amqp091.Delivery, *sync.WaitGroup) {
+	lanes := make([]chan amqp091.Delivery, workers)
+	running := &sync.WaitGroup{}
+	for i := range lanes {
+		lanes[i] = make(chan amqp091.Delivery, tc.sharedPrefetch(workers))
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			tc.runLane(lanes[i])
+		}()
+	}
+	return lanes, running
+}
+
+// stopLanes waits for lanes to handle what they hold, or for the consumer
+// to stop, and returns what they did not get to to the queue.
+func (tc *TenantConsumer) stopLanes(lanes []chan amqp091.Delivery, running *sync.WaitGroup) {
+	for _, lane := range lanes {
+		close(lane)
+	}
+	running.Wait()
+	for _, lane := range lanes {
+		reject(lane)
+	}
+}
+
+// runLane handles a lane's deliveries in order until the lane is closed or
+// the consumer stops.
+func (tc *TenantConsumer) runLane(lane <-chan amqp091.Delivery) {
+	for {
+		select {
+		case d, ok := <-lane:
+			if !ok || !tc.settleShared(d) {
+				return
+			}
+		case <-tc.StopChan:
+			return
+		}
+	}
+}
+
+// process handles d, retrying it in place while handle asks to, up to
+// maxAttempts times in all; handle counts failed attempts against the
+// message too, but not those that fail before the handler runs, such as an
+// unreachable inbox. d is then dead-lettered so later messages with its key
+// can proceed. It returns false if the consumer stopped in the meantime, in
+// which case d is returned to the queue.
+func (tc *TenantConsumer) process(d amqp091.Delivery) bool {
+	if d.Headers == nil {
+		d.Headers = amqp091.Table{}
+	}
+	backoff := 100 * time.Millisecond
+	for attempts := 1; ; attempts++ {
+		start := time.Now()
+		retry := tc.handle(d)
+		tc.stats.observe(start)
+		if !retry {
+			return true
+		}
+		if attempts >= tc.maxAttempts {
+			err := fmt.Errorf("gave up after %d attempts in place", attempts)
+			tc.deadLetter(d, messaging.FromDelivery(d), reasonMaxAttempts, models.MessageDeadLettered, err)
+			return true
+		}
+		select {
+		case <-time.After(backoff):
+		case <-tc.StopChan:
+			d.Nack(false, true)
+			return false
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (tc *TenantConsumer) dispatcherTag() string {
+	if tc.Subscription != "" {
+		return fmt.Sprintf("%s-%s-dispatcher", tc.TenantID, tc.Subscription)
+	}
+	return fmt.Sprintf("%s-dispatcher", tc.TenantID)
+}
+
+// laneFor maps an ordering key to one of n lanes with Lamping and Veach's
+// jump consistent hash, which moves only about 1/n of the keys when a lane
+// is added.
+func laneFor(key string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	k := h.Sum64()
+
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		k = k*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((k>>33)+1)))
+	}
+	return int(b)
+}
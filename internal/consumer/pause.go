@@ -0,0 +1,36 @@
+package consumer
+
+import "github.com/abiewardani/go-messaging-system/pkg/metrics"
+
+// Pause cancels the consumers of a running tenant's queue and
+// subscriptions. The queues and their bindings are kept, so messages keep
+// accumulating until Resume. Deliveries being handled are drained first.
+func (tm *TenantManager) Pause(tenantID string) error {
+	return tm.setPaused(tenantID, true)
+}
+
+// Resume restores the consumers of a paused tenant.
+func (tm *TenantManager) Resume(tenantID string) error {
+	return tm.setPaused(tenantID, false)
+}
+
+func (tm *TenantManager) setPaused(tenantID string, paused bool) error {
+	tm.mu.Lock()
+	consumer, exists := tm.tenants[tenantID]
+	tm.mu.Unlock()
+	if exists && consumer.paused == paused {
+		return nil
+	}
+	err := tm.restartTenant(tenantID, func(tc *TenantConsumer) { tc.paused = paused })
+	if err == nil {
+		metrics.TenantPaused.WithLabelValues(tenantID).Set(boolGauge(paused))
+	}
+	return err
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
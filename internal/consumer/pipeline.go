@@ -0,0 +1,65 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/transform"
+)
+
+// reasonTransformFailed dead-letters messages a subscription's pipeline
+// cannot transform. Pipelines are deterministic, so they are not retried.
+const reasonTransformFailed = "transform_failed"
+
+// pipeline is the compiled active version of a subscription's pipeline.
+type pipeline struct {
+	version int
+	*transform.Pipeline
+}
+
+// compilePipeline compiles p's active version, returning nil if it has
+// none.
+func compilePipeline(p *models.Pipeline) (*pipeline, error) {
+	active := p.ActiveVersion()
+	if active == nil {
+		return nil, nil
+	}
+	compiled, err := transform.Compile(active.Steps)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline version %d: %w", active.Version, err)
+	}
+	return &pipeline{version: active.Version, Pipeline: compiled}, nil
+}
+
+// SetPipeline applies a changed pipeline to a running subscription.
+// Deliveries already being handled finish with the previous version.
+func (tm *TenantManager) SetPipeline(tenantID, subscription string, p *models.Pipeline) error {
+	compiled, err := compilePipeline(p)
+	if err != nil {
+		return err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	consumer, exists := tm.tenants[tenantID]
+	if !exists {
+		return fmt.Errorf("tenant %s not found", tenantID)
+	}
+	if sc, running := consumer.subscriptions[subscription]; running {
+		sc.pipeline.Store(compiled)
+	}
+	return nil
+}
+
+// transform applies the consumer's pipeline to msg, returning the version
+// applied, or 0 if there is none.
+func (tc *TenantConsumer) transform(msg *models.Message) (int, error) {
+	p := tc.pipeline.Load()
+	if p == nil {
+		return 0, nil
+	}
+	if err := p.Apply(msg); err != nil {
+		return p.version, fmt.Errorf("pipeline version %d: %w", p.version, err)
+	}
+	return p.version, nil
+}
@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/transform"
+)
+
+func setStep(value string) []models.TransformStep {
+	return []models.TransformStep{{Type: models.StepSet, Field: "version", Value: value}}
+}
+
+func TestCompilePipeline(t *testing.T) {
+	versions := []models.PipelineVersion{
+		{Version: 1, Steps: setStep("one")},
+		{Version: 2, Steps: setStep("two")},
+		{Version: 4, Steps: []models.TransformStep{{Type: "upper"}}},
+	}
+	tests := []struct {
+		name        string
+		pipeline    *models.Pipeline
+		wantVersion int
+		wantValue   string
+		wantErr     string
+	}{
+		{name: "no pipeline"},
+		{name: "disabled", pipeline: &models.Pipeline{Versions: versions}},
+		{name: "active version", pipeline: &models.Pipeline{Active: 2, Versions: versions}, wantVersion: 2, wantValue: "two"},
+		{name: "older version", pipeline: &models.Pipeline{Active: 1, Versions: versions}, wantVersion: 1, wantValue: "one"},
+		{name: "missing version", pipeline: &models.Pipeline{Active: 3, Versions: versions}},
+		{name: "invalid version", pipeline: &models.Pipeline{Active: 4, Versions: versions}, wantErr: "invalid pipeline version 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := compilePipeline(tt.pipeline)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("compilePipeline error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compilePipeline: %v", err)
+			}
+			if tt.wantVersion == 0 {
+				if p != nil {
+					t.Fatalf("compilePipeline = version %d, want none", p.version)
+				}
+				return
+			}
+			if p == nil || p.version != tt.wantVersion {
+				t.Fatalf("compilePipeline = %+v, want version %d", p, tt.wantVersion)
+			}
+
+			tc := &TenantConsumer{}
+			tc.pipeline.Store(p)
+			msg := &models.Message{Payload: []byte(`{}`), ContentType: "application/json"}
+			version, err := tc.transform(msg)
+			if err != nil {
+				t.Fatalf("transform: %v", err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("transform version = %d, want %d", version, tt.wantVersion)
+			}
+			if want := `{"version":"` + tt.wantValue + `"}`; string(msg.Payload) != want {
+				t.Errorf("payload = %s, want %s", msg.Payload, want)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	p, err := compilePipeline(&models.Pipeline{Active: 3, Versions: []models.PipelineVersion{{Version: 3, Steps: setStep("three")}}})
+	if err != nil {
+		t.Fatalf("compilePipeline: %v", err)
+	}
+
+	t.Run("without pipeline", func(t *testing.T) {
+		tc := &TenantConsumer{}
+		msg := &models.Message{Payload: []byte("plain"), ContentType: "text/plain"}
+		version, err := tc.transform(msg)
+		if err != nil || version != 0 {
+			t.Fatalf("transform = %d, %v, want 0, nil", version, err)
+		}
+		if string(msg.Payload) != "plain" {
+			t.Errorf("payload = %s, want it unchanged", msg.Payload)
+		}
+	})
+
+	t.Run("unsupported payload", func(t *testing.T) {
+		tc := &TenantConsumer{}
+		tc.pipeline.Store(p)
+		msg := &models.Message{Payload: []byte("plain"), ContentType: "text/plain"}
+		version, err := tc.transform(msg)
+		if !errors.Is(err, transform.ErrUnsupportedPayload) {
+			t.Fatalf("transform error = %v, want %v", err, transform.ErrUnsupportedPayload)
+		}
+		if version != 3 || !strings.Contains(err.Error(), "pipeline version 3") {
+			t.Errorf("transform = %d, %v, want the failing version 3", version, err)
+		}
+	})
+}
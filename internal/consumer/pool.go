@@ -0,0 +1,270 @@
+package consumer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// pool is a fixed set of workers shared by every tenant. Each tenant's
+// consumers hand their deliveries, lanes or batches to the pool as jobs,
+// and free workers take the next one by start-time fair queueing: every
+// tenant has a virtual time that advances by 1/weight per message
+// dispatched, and the tenant with the lowest virtual time goes next.
+// Tenants running fewer jobs than their minimum go first.
+type pool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tenants map[string]*poolTenant
+	// vtime is the virtual time of the last dispatch. A tenant that was
+	// idle starts from it, so idling earns no credit.
+	vtime  float64
+	busy   int
+	closed bool
+}
+
+// poolTenant is a tenant's state in the pool.
+type poolTenant struct {
+	id         string
+	weight     float64
+	minWorkers int
+	running    int
+	vtime      float64
+	pending    []poolJob
+}
+
+// poolJob is work of a tenant waiting for a worker.
+type poolJob struct {
+	// cost is the number of messages the job handles.
+	cost int
+	run  func()
+	// drop returns the job's deliveries to the queue when the job is
+	// discarded instead of run.
+	drop func()
+}
+
+// newPool starts a pool of size workers.
+func newPool(size int) *pool {
+	p := &pool{tenants: make(map[string]*poolTenant)}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// setPolicy sets a tenant's share of the pool.
+func (p *pool) setPolicy(tenantID string, policy *models.SchedulingPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pt := p.tenant(tenantID)
+	weight, minWorkers := policy.Share()
+	pt.weight, pt.minWorkers = float64(weight), minWorkers
+	p.cond.Broadcast()
+}
+
+// remove forgets a tenant, returning the deliveries of its waiting jobs to
+// the queue.
+func (p *pool) remove(tenantID string) {
+	p.mu.Lock()
+	var pending []poolJob
+	if pt := p.tenants[tenantID]; pt != nil {
+		pending = pt.pending
+	}
+	delete(p.tenants, tenantID)
+	p.mu.Unlock()
+	for _, job := range pending {
+		job.drop()
+	}
+}
+
+// tenant returns the pool state of a tenant, creating it if needed. p.mu
+// must be held.
+func (p *pool) tenant(tenantID string) *poolTenant {
+	pt := p.tenants[tenantID]
+	if pt == nil {
+		pt = &poolTenant{id: tenantID, weight: 1, vtime: p.vtime}
+		p.tenants[tenantID] = pt
+	}
+	return pt
+}
+
+// submit queues a job of a tenant for the pool's workers. Once the pool is
+// closed the job is dropped.
+func (p *pool) submit(tenantID string, job poolJob) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		job.drop()
+		return
+	}
+	defer p.mu.Unlock()
+	pt := p.tenant(tenantID)
+	if pt.running == 0 && len(pt.pending) == 0 && pt.vtime < p.vtime {
+		pt.vtime = p.vtime
+	}
+	pt.pending = append(pt.pending, job)
+	p.cond.Signal()
+}
+
+// run submits a job of cost messages of a tenant and waits for it to be
+// run, or dropped in its place, reporting whether it ran.
+func (p *pool) run(tenantID string, cost int, run, drop func()) bool {
+	ran := make(chan bool, 1)
+	p.submit(tenantID, poolJob{
+		cost: cost,
+		run:  func() { run(); ran <- true },
+		drop: func() { drop(); ran <- false },
+	})
+	return <-ran
+}
+
+// next waits for the next job to run, returning false once the
+// pool is closed.
+func (p *pool) next() (*poolTenant, poolJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if p.closed {
+			return nil, poolJob{}, false
+		}
+		if pt := p.pick(); pt != nil {
+			job := pt.pending[0]
+			pt.pending[0] = poolJob{}
+			pt.pending = pt.pending[1:]
+			pt.running++
+			p.vtime = pt.vtime
+			pt.vtime += float64(job.cost) / pt.weight
+			p.busy++
+			metrics.PoolBusyWorkers.Set(float64(p.busy))
+			return pt, job, true
+		}
+		p.cond.Wait()
+	}
+}
+
+// pick returns the tenant to serve next: of the tenants with jobs
+// waiting, the one with the lowest virtual time, preferring tenants below
+// their minimum. p.mu must be held.
+func (p *pool) pick() *poolTenant {
+	var best *poolTenant
+	bestBelow := false
+	for _, pt := range p.tenants {
+		if len(pt.pending) == 0 {
+			continue
+		}
+		below := pt.running < pt.minWorkers
+		switch {
+		case best == nil,
+			below && !bestBelow,
+			below == bestBelow && pt.vtime < best.vtime:
+			best, bestBelow = pt, below
+		}
+	}
+	return best
+}
+
+// done releases the worker of a job of pt.
+func (p *pool) done(pt *poolTenant) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pt.running--
+	p.busy--
+	metrics.PoolBusyWorkers.Set(float64(p.busy))
+}
+
+// work runs jobs until the pool is closed.
+func (p *pool) work() {
+	for {
+		pt, job, ok := p.next()
+		if !ok {
+			return
+		}
+		metrics.PoolDispatched.WithLabelValues(pt.id).Add(float64(job.cost))
+		job.run()
+		p.done(pt)
+	}
+}
+
+// close stops the pool's workers once they finish their jobs, and returns
+// the deliveries of the jobs still waiting to the queue.
+func (p *pool) close() {
+	p.mu.Lock()
+	p.closed = true
+	var pending []poolJob
+	for _, pt := range p.tenants {
+		pending = append(pending, pt.pending...)
+		pt.pending = nil
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	for _, job := range pending {
+		job.drop()
+	}
+}
+
+// startPooled consumes the queue into the manager's shared pool. The
+// prefetch, the larger of WorkerCount and the QoS prefetch, bounds how many
+// of the queue's messages are in the pool at once.
+func (tc *TenantConsumer) startPooled() error {
+	if err := tc.Channel.Qos(tc.sharedPrefetch(int(tc.WorkerCount)), 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+	msgs, err := tc.consume(tc.Channel, tc.consumerTag(0))
+	if err != nil {
+		return err
+	}
+	tc.successors = newSuccessors()
+	go func() {
+		for {
+			select {
+			case d, ok := <-msgs:
+				if !ok {
+					// Resized, or stopping
+					s, ok := tc.successors.pop(tc.StopChan)
+					if !ok {
+						tc.successors.reject()
+						return
+					}
+					msgs = s.msgs
+					continue
+				}
+				tc.pool.submit(tc.TenantID, poolJob{
+					cost: 1,
+					run:  func() { tc.settle(d) },
+					drop: func() { d.Nack(false, true) },
+				})
+			case <-tc.StopChan:
+				reject(msgs)
+				tc.successors.reject()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// settleShared settles d like settle, on a worker of the shared pool if
+// there is one, and waits for it. Deliveries dropped by the pool are
+// returned to the queue, and false is returned.
+func (tc *TenantConsumer) settleShared(d amqp091.Delivery) bool {
+	if tc.pool == nil {
+		return tc.settle(d)
+	}
+	settled := false
+	ran := tc.pool.run(tc.TenantID, 1,
+		func() { settled = tc.settle(d) },
+		func() { d.Nack(false, true) })
+	return ran && settled
+}
+
+// SetSchedulingPolicy changes a tenant's share of the shared worker pool.
+// It has no effect without the pool.
+func (tm *TenantManager) SetSchedulingPolicy(tenantID string, policy *models.SchedulingPolicy) {
+	if tm.pool != nil {
+		tm.pool.setPolicy(tenantID, policy)
+	}
+}
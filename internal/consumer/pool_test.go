@@ -0,0 +1,179 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// dispatch takes n jobs off a pool without workers and returns how many
+// each tenant got. With release set every job finishes before the next is
+// picked; otherwise all of them keep running.
+func dispatch(t *testing.T, p *pool, n int, release bool) map[string]int {
+	t.Helper()
+	got := make(map[string]int)
+	for i := 0; i < n; i++ {
+		pt, _, ok := p.next()
+		if !ok {
+			t.Fatalf("next returned no job after %d dispatches", i)
+		}
+		got[pt.id]++
+		if release {
+			p.done(pt)
+		}
+	}
+	return got
+}
+
+// fill queues n jobs of cost for a tenant, counting those dropped.
+func fill(p *pool, tenantID string, n, cost int, dropped *int) {
+	for i := 0; i < n; i++ {
+		p.submit(tenantID, poolJob{cost: cost, run: func() {}, drop: func() { *dropped++ }})
+	}
+}
+
+func TestPoolWeights(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  map[string]int
+		costs    map[string]int
+		dispatch int
+		want     map[string]int
+	}{
+		{
+			name:     "equal weights",
+			weights:  map[string]int{"a": 1, "b": 1},
+			dispatch: 20,
+			want:     map[string]int{"a": 10, "b": 10},
+		},
+		{
+			name:     "three to one",
+			weights:  map[string]int{"a": 3, "b": 1},
+			dispatch: 40,
+			want:     map[string]int{"a": 30, "b": 10},
+		},
+		{
+			name:     "three tenants",
+			weights:  map[string]int{"a": 4, "b": 2, "c": 1},
+			dispatch: 70,
+			want:     map[string]int{"a": 40, "b": 20, "c": 10},
+		},
+		{
+			name:     "batches cost their size",
+			weights:  map[string]int{"a": 1, "b": 1},
+			costs:    map[string]int{"b": 4},
+			dispatch: 25,
+			want:     map[string]int{"a": 20, "b": 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPool(0)
+			var dropped int
+			for id, weight := range tt.weights {
+				p.setPolicy(id, &models.SchedulingPolicy{Weight: weight})
+				cost := tt.costs[id]
+				if cost == 0 {
+					cost = 1
+				}
+				fill(p, id, tt.dispatch, cost, &dropped)
+			}
+			got := dispatch(t, p, tt.dispatch, true)
+			for id, want := range tt.want {
+				// Ties between equal virtual times go either way
+				if diff := got[id] - want; diff < -1 || diff > 1 {
+					t.Errorf("tenant %s got %d jobs, want %d (all: %v)", id, got[id], want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolIdleTenantEarnsNoCredit(t *testing.T) {
+	p := newPool(0)
+	var dropped int
+	fill(p, "a", 20, 1, &dropped)
+	dispatch(t, p, 10, true)
+
+	// b arrives late; it shares from now on instead of catching up
+	fill(p, "b", 20, 1, &dropped)
+	got := dispatch(t, p, 10, true)
+	if got["a"] < 4 || got["b"] > 6 {
+		t.Errorf("after b arrived got %v, want an even split", got)
+	}
+}
+
+func TestPoolMinWorkers(t *testing.T) {
+	p := newPool(0)
+	p.setPolicy("a", &models.SchedulingPolicy{Weight: 1, MinWorkers: 2})
+	p.setPolicy("b", &models.SchedulingPolicy{Weight: 100})
+	var dropped int
+	fill(p, "a", 10, 1, &dropped)
+	fill(p, "b", 10, 1, &dropped)
+
+	// a runs its minimum first despite b's far larger weight
+	var running []*poolTenant
+	for i := 0; i < 2; i++ {
+		pt, _, _ := p.next()
+		if pt.id != "a" {
+			t.Fatalf("dispatch %d went to %s, want a below its minimum", i, pt.id)
+		}
+		running = append(running, pt)
+	}
+	// At its minimum a falls back to fair queueing behind b
+	if pt, _, _ := p.next(); pt.id != "b" {
+		t.Fatalf("dispatch went to %s, want b once a reached its minimum", pt.id)
+	}
+
+	// A job of a finishing puts it below its minimum again
+	p.done(running[0])
+	if pt, _, _ := p.next(); pt.id != "a" {
+		t.Fatalf("dispatch went to %s, want a back below its minimum", pt.id)
+	}
+}
+
+func TestPoolRemove(t *testing.T) {
+	p := newPool(0)
+	var droppedA, droppedB int
+	fill(p, "a", 3, 1, &droppedA)
+	fill(p, "b", 1, 1, &droppedB)
+
+	p.remove("a")
+	if droppedA != 3 || droppedB != 0 {
+		t.Fatalf("remove dropped %d jobs of a and %d of b, want 3 and 0", droppedA, droppedB)
+	}
+	if pt, _, _ := p.next(); pt.id != "b" {
+		t.Fatalf("next job is of %s, want b", pt.id)
+	}
+	if _, ok := p.tenants["a"]; ok {
+		t.Error("removed tenant is still known to the pool")
+	}
+
+	// A removed tenant that submits again starts from a fresh state
+	fill(p, "a", 1, 1, &droppedA)
+	if pt := p.tenants["a"]; pt == nil || pt.running != 0 || pt.weight != 1 {
+		t.Errorf("resubmitting tenant gave state %+v", pt)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p := newPool(1)
+	if !p.run("a", 1, func() {}, func() {}) {
+		t.Fatal("run on an open pool was dropped")
+	}
+
+	var dropped int
+	closed := newPool(0)
+	fill(closed, "a", 2, 1, &dropped)
+	closed.close()
+	if dropped != 2 {
+		t.Fatalf("close dropped %d waiting jobs, want 2", dropped)
+	}
+	if closed.run("a", 1, func() { t.Error("job ran on a closed pool") }, func() {}) {
+		t.Error("run on a closed pool reported the job as run")
+	}
+	if _, _, ok := closed.next(); ok {
+		t.Error("next returned a job from a closed pool")
+	}
+	p.close()
+}
@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// PurgeQueue removes every ready message from the tenant's queues, including
+// its dead-letter and subscription queues, and returns how many were
+// removed. A missing queue counts as empty.
+func (tm *TenantManager) PurgeQueue(tenantID string) (int, error) {
+	removed := 0
+	for _, queue := range tm.tenantQueues(tenantID) {
+		n, err := tm.purgeQueue(queue)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (tm *TenantManager) purgeQueue(queue string) (int, error) {
+	ch, err := tm.amqpConn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create channel: %w", err)
+	}
+	defer ch.Close()
+
+	n, err := ch.QueuePurge(queue, false)
+	if isNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge queue: %w", err)
+	}
+	return n, nil
+}
+
+// PurgeQueueMatching removes ready messages whose AMQP header equals value
+// from the tenant's queues and returns how many were removed. RabbitMQ
+// cannot delete individual messages, so every ready message is fetched
+// once: matches are acked, the rest republished to the queue and then
+// acked. Messages already delivered to a worker are not affected, and
+// republished messages move to the back of the queue.
+func (tm *TenantManager) PurgeQueueMatching(tenantID, header, value string) (int, error) {
+	removed := 0
+	for _, queue := range tm.tenantQueues(tenantID) {
+		n, err := tm.purgeQueueMatching(queue, header, value)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (tm *TenantManager) purgeQueueMatching(queue, header, value string) (int, error) {
+	ch, err := tm.amqpConn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create channel: %w", err)
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(queue, true, false, false, false, nil)
+	if isNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		return 0, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	removed := 0
+	for i := 0; i < q.Messages; i++ {
+		d, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return removed, fmt.Errorf("failed to get message: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		if v, exists := d.Headers[header]; exists && fmt.Sprint(v) == value {
+			if err := d.Ack(false); err != nil {
+				return removed, err
+			}
+			removed++
+			continue
+		}
+
+		confirm, err := ch.PublishWithDeferredConfirmWithContext(context.Background(), "", queue, false, false, publishingFromDelivery(d))
+		if err != nil {
+			d.Nack(false, true)
+			return removed, fmt.Errorf("failed to requeue message: %w", err)
+		}
+		if !confirm.Wait() {
+			d.Nack(false, true)
+			return removed, errors.New("broker rejected requeued message")
+		}
+		if err := d.Ack(false); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// tenantQueues returns the names of the queues holding a tenant's messages:
+// its own queue, its dead-letter queue and the queues of its running
+// subscriptions.
+func (tm *TenantManager) tenantQueues(tenantID string) []string {
+	queues := []string{messaging.TenantQueueName(tenantID), messaging.DeadLetterQueueName(tenantID)}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if consumer, ok := tm.tenants[tenantID]; ok {
+		for _, sc := range consumer.subscriptions {
+			queues = append(queues, sc.Queue)
+		}
+	}
+	return queues
+}
+
+// publishingFromDelivery copies a delivered message's body and properties
+// so it can be published again unchanged.
+func publishingFromDelivery(d amqp091.Delivery) amqp091.Publishing {
+	return amqp091.Publishing{
+		Headers:         d.Headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    d.DeliveryMode,
+		Priority:        d.Priority,
+		CorrelationId:   d.CorrelationId,
+		ReplyTo:         d.ReplyTo,
+		Expiration:      d.Expiration,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		UserId:          d.UserId,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	}
+}
+
+// isPreconditionFailed reports whether err is an AMQP 406, as returned when
+// a queue is redeclared with different arguments.
+func isPreconditionFailed(err error) bool {
+	var amqpErr *amqp091.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == amqp091.PreconditionFailed
+}
+
+// isNotFound reports whether err is an AMQP 404 (e.g. a missing queue).
+func isNotFound(err error) bool {
+	var amqpErr *amqp091.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == amqp091.NotFound
+}
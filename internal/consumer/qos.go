@@ -0,0 +1,11 @@
+package consumer
+
+import "github.com/abiewardani/go-messaging-system/internal/models"
+
+// SetQoS applies a changed QoS policy to a running tenant by restarting the
+// workers of its queue and subscriptions. The old workers are drained
+// first, and deliveries they did not start on are redelivered to the new
+// ones.
+func (tm *TenantManager) SetQoS(tenantID string, qos *models.QoSPolicy) error {
+	return tm.restartTenant(tenantID, func(tc *TenantConsumer) { tc.qos = qos })
+}
@@ -0,0 +1,206 @@
+package consumer
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// worker is a worker of a consumer in plain or batch mode.
+type worker struct {
+	// consumer is the worker's own consumer, or the zero value for workers
+	// sharing the exclusive consumer, which are stopped by closing quit.
+	consumer consumerRef
+	quit     chan struct{}
+	// own is the channel opened for the worker, if any.
+	own  *amqp091.Channel
+	done chan struct{}
+}
+
+// addWorker starts another worker of a consumer in plain or batch mode.
+func (tc *TenantConsumer) addWorker() error {
+	w := &worker{done: make(chan struct{})}
+	if tc.exclusive != nil {
+		w.quit = make(chan struct{})
+		tc.workers = append(tc.workers, w)
+		go tc.runWorker(tc.exclusive, w)
+		return nil
+	}
+
+	ch := tc.Channel
+	if tc.batch != nil || (tc.qos != nil && tc.qos.ChannelPerWorker) {
+		prefetch := tc.qos.Prefetch()
+		if tc.batch != nil {
+			prefetch = tc.batch.PrefetchCount()
+		}
+		var err error
+		if ch, err = tc.openWorkerChannel(prefetch); err != nil {
+			return err
+		}
+		w.own = ch
+	}
+	tag := tc.consumerTag(int32(len(tc.workers)))
+	msgs, err := tc.consume(ch, tag)
+	if err != nil {
+		return err
+	}
+	w.consumer = consumerRef{ch: ch, tag: tag}
+	tc.workers = append(tc.workers, w)
+	if tc.batch != nil {
+		go tc.runBatches(msgs, w)
+	} else {
+		go tc.runWorker(msgs, w)
+	}
+	return nil
+}
+
+// removeWorker stops the last worker of a consumer in plain or batch mode.
+// It takes no new deliveries and exits once it has handled the ones it
+// holds, after which its own channel is closed.
+func (tc *TenantConsumer) removeWorker() error {
+	w := tc.workers[len(tc.workers)-1]
+	if w.quit != nil {
+		close(w.quit)
+	} else {
+		if err := w.consumer.ch.Cancel(w.consumer.tag, false); err != nil {
+			return fmt.Errorf("failed to cancel consumer: %w", err)
+		}
+		tc.consumers = slices.DeleteFunc(tc.consumers, func(c consumerRef) bool { return c == w.consumer })
+	}
+	tc.workers = tc.workers[:len(tc.workers)-1]
+	if w.own != nil {
+		tc.channels = slices.DeleteFunc(tc.channels, func(ch *amqp091.Channel) bool { return ch == w.own })
+		go func() {
+			<-w.done
+			w.own.Close()
+		}()
+	}
+	return nil
+}
+
+// resize changes the number of tc's workers to n in place, without
+// draining the consumer: workers in plain or batch mode are added or
+// removed one by one, while ordered and pooled consumers re-consume with
+// the prefetch for n workers and hand the new consumer to their dispatcher.
+// On failure the consumer may be left with fewer workers than asked for.
+func (tc *TenantConsumer) resize(n int32) error {
+	switch {
+	case tc.paused:
+		tc.WorkerCount = n
+		return nil
+	case len(tc.consumers) == 0:
+		// No workers to resize
+		tc.WorkerCount = n
+		return tc.startWorkers()
+	case tc.successors != nil:
+		if err := tc.reconsume(int(n)); err != nil {
+			return err
+		}
+		tc.WorkerCount = n
+		return nil
+	}
+
+	defer func() { tc.WorkerCount = int32(len(tc.workers)) }()
+	for int32(len(tc.workers)) < n {
+		if err := tc.addWorker(); err != nil {
+			return err
+		}
+	}
+	for int32(len(tc.workers)) > n {
+		if err := tc.removeWorker(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconsume replaces the single consumer of an ordered or pooled consumer
+// with one whose prefetch suits workers, and queues it for the dispatcher,
+// which switches over once it has the deliveries of the old one.
+func (tc *TenantConsumer) reconsume(workers int) error {
+	ref := tc.consumers[0]
+	if err := ref.ch.Qos(tc.sharedPrefetch(workers), 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+	if err := ref.ch.Cancel(ref.tag, false); err != nil {
+		return fmt.Errorf("failed to cancel consumer: %w", err)
+	}
+	msgs, err := tc.consume(ref.ch, ref.tag)
+	if err != nil {
+		return err
+	}
+	tc.successors.push(successor{msgs: msgs, workers: workers})
+	return nil
+}
+
+// sharedPrefetch is the prefetch of a single consumer feeding workers: the
+// larger of workers and the QoS prefetch.
+func (tc *TenantConsumer) sharedPrefetch(workers int) int {
+	if p := tc.qos.Prefetch(); p > workers {
+		return p
+	}
+	return workers
+}
+
+// successor is a consumer that replaces the single consumer of an ordered
+// or pooled consumer, with the number of workers it feeds.
+type successor struct {
+	msgs    <-chan amqp091.Delivery
+	workers int
+}
+
+// successors queues the successors of a single consumer in the order they
+// were started. Each must be read in turn, since the deliveries a cancelled
+// consumer buffered are still handed out.
+type successors struct {
+	mu     sync.Mutex
+	queue  []successor
+	signal chan struct{}
+}
+
+func newSuccessors() *successors {
+	return &successors{signal: make(chan struct{}, 1)}
+}
+
+func (s *successors) push(x successor) {
+	s.mu.Lock()
+	s.queue = append(s.queue, x)
+	s.mu.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next successor, returning false once stop is closed.
+func (s *successors) pop(stop <-chan struct{}) (successor, bool) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			x := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			return x, true
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.signal:
+		case <-stop:
+			return successor{}, false
+		}
+	}
+}
+
+// reject returns the deliveries of the successors not taken over to the
+// queue.
+func (s *successors) reject() {
+	s.mu.Lock()
+	queue := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+	for _, x := range queue {
+		reject(x.msgs)
+	}
+}
@@ -0,0 +1,210 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// AddSubscription starts a subscription of a running tenant.
+func (tm *TenantManager) AddSubscription(tenantID string, sub models.Subscription) error {
+	unlock := tm.lockTenant(tenantID)
+	defer unlock()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	consumer, exists := tm.tenants[tenantID]
+	if !exists {
+		return fmt.Errorf("tenant %s not found", tenantID)
+	}
+	if _, exists := consumer.subscriptions[sub.Name]; exists {
+		return fmt.Errorf("subscription %s of tenant %s already exists", sub.Name, tenantID)
+	}
+	return tm.startSubscription(consumer, sub, nil)
+}
+
+// UpdateSubscription applies a changed subscription of a running tenant:
+// its workers are restarted with the new handler and worker count, and
+// patterns no longer listed are unbound. Queued messages are kept, and so
+// is the running pipeline.
+func (tm *TenantManager) UpdateSubscription(tenantID string, sub models.Subscription) error {
+	unlock := tm.lockTenant(tenantID)
+	defer unlock()
+
+	tm.mu.Lock()
+	if _, ok := tm.handlers[sub.Handler]; !ok {
+		tm.mu.Unlock()
+		return fmt.Errorf("%w %q", ErrUnknownHandler, sub.Handler)
+	}
+	consumer, sc, err := tm.detachSubscription(tenantID, sub.Name)
+	tm.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var p *pipeline
+	if sc != nil {
+		p = sc.pipeline.Load()
+		tm.stop(sc)
+
+		keep := make(map[string]bool, len(sub.Patterns))
+		for _, p := range sub.Patterns {
+			keep[p] = true
+		}
+		exchange := messaging.TenantExchangeName(tenantID)
+		for _, p := range sc.patterns {
+			if keep[p] {
+				continue
+			}
+			if err := consumer.Channel.QueueUnbind(sc.Queue, p, exchange, nil); err != nil {
+				return fmt.Errorf("failed to unbind pattern %q: %w", p, err)
+			}
+		}
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.tenants[tenantID] != consumer {
+		// Removed or shut down while the subscription drained
+		return fmt.Errorf("tenant %s was stopped", tenantID)
+	}
+	return tm.startSubscription(consumer, sub, p)
+}
+
+// RemoveSubscription stops a subscription of a running tenant and deletes
+// its queue.
+func (tm *TenantManager) RemoveSubscription(tenantID, name string) error {
+	unlock := tm.lockTenant(tenantID)
+	defer unlock()
+
+	tm.mu.Lock()
+	consumer, sc, err := tm.detachSubscription(tenantID, name)
+	tm.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if sc != nil {
+		tm.stop(sc)
+	}
+	queue := messaging.SubscriptionQueueName(tenantID, name)
+	if _, err := consumer.Channel.QueueDelete(queue, false, false, false); err != nil {
+		return fmt.Errorf("failed to delete queue of subscription %s: %w", name, err)
+	}
+	return nil
+}
+
+// detachSubscription takes a subscription's consumer, if running, out of
+// its tenant's consumer so the caller can stop it without holding tm.mu.
+// tm.mu must be held, and so must the tenant's lifecycle lock, which keeps
+// the tenant's consumer from being replaced until the caller is done.
+func (tm *TenantManager) detachSubscription(tenantID, name string) (*TenantConsumer, *TenantConsumer, error) {
+	consumer, exists := tm.tenants[tenantID]
+	if !exists {
+		return nil, nil, fmt.Errorf("tenant %s not found", tenantID)
+	}
+	sc := consumer.subscriptions[name]
+	delete(consumer.subscriptions, name)
+	return consumer, sc, nil
+}
+
+// startSubscription declares a subscription's queue, binds its patterns to
+// the tenant's exchange and starts its workers, which apply p if it is not
+// nil. tm.mu must be held.
+func (tm *TenantManager) startSubscription(consumer *TenantConsumer, sub models.Subscription, p *pipeline) error {
+	handler, ok := tm.handlers[sub.Handler]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownHandler, sub.Handler)
+	}
+
+	ch, err := tm.openChannel()
+	if err != nil {
+		return err
+	}
+	ch, q, err := tm.declareQueue(ch, messaging.SubscriptionQueueName(consumer.TenantID, sub.Name), consumer.queueArgs)
+	if err != nil {
+		return err
+	}
+	exchange := messaging.TenantExchangeName(consumer.TenantID)
+	for _, p := range sub.Patterns {
+		if err := ch.QueueBind(q.Name, p, exchange, false, nil); err != nil {
+			ch.Close()
+			return fmt.Errorf("failed to bind pattern %q: %w", p, err)
+		}
+	}
+
+	sc := &TenantConsumer{
+		TenantID:     consumer.TenantID,
+		Subscription: sub.Name,
+		Channel:      ch,
+		Queue:        q.Name,
+		StopChan:     make(chan struct{}),
+		WorkerCount:  sub.WorkerCount,
+		handler:      handler,
+		inbox:        consumer.inbox,
+		events:       consumer.events,
+		maxAttempts:  consumer.maxAttempts,
+		queueArgs:    consumer.queueArgs,
+		patterns:     sub.Patterns,
+		ordered:      consumer.ordered,
+		batch:        consumer.batch,
+		qos:          consumer.qos,
+		openChannel:  consumer.openChannel,
+		stats:        &consumerStats{},
+		pool:         consumer.pool,
+		paused:       consumer.paused,
+	}
+	sc.inboxPolicy.Store(consumer.inboxPolicy.Load())
+	sc.pipeline.Store(p)
+	if err := sc.startWorkers(); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to start workers: %w", err)
+	}
+	consumer.subscriptions[sub.Name] = sc
+	return nil
+}
+
+// startAttempt counts a delivery attempt of msg. On the tenant's own queue
+// it is recorded as the message's processing status. Subscriptions share
+// the message with that queue and with each other, so they leave its status
+// alone and count their attempts in the x-delivery-attempt header.
+func (tc *TenantConsumer) startAttempt(d amqp091.Delivery, msg *models.Message) {
+	if tc.Subscription == "" {
+		tc.transition(context.Background(), msg, models.MessageProcessing)
+		return
+	}
+	attempt, _ := d.Headers[messaging.HeaderDeliveryAttempt].(int32)
+	msg.DeliveryAttempts = int(attempt) + 1
+}
+
+// retrySubscription sends a failed delivery back to the subscription's
+// queue with its attempt count, then acks the original.
+func (tc *TenantConsumer) retrySubscription(d amqp091.Delivery, msg *models.Message, details models.EventDetails) {
+	publishing := publishingFromDelivery(d)
+	publishing.Headers = amqp091.Table{}
+	for k, v := range d.Headers {
+		publishing.Headers[k] = v
+	}
+	publishing.Headers[messaging.HeaderDeliveryAttempt] = int32(msg.DeliveryAttempts)
+
+	if err := tc.Channel.PublishWithContext(context.Background(), "", tc.Queue, false, false, publishing); err != nil {
+		log.Printf("Failed to retry message %s in subscription %s of tenant %s: %v", msg.ID, tc.Subscription, tc.TenantID, err)
+		d.Nack(false, true)
+		return
+	}
+	tc.record(d, msg, models.EventRequeued, details)
+	d.Ack(false)
+}
+
+// inboxKey returns the inbox key of a message ID. Each subscription keeps
+// its own record of processed messages.
+func (tc *TenantConsumer) inboxKey(messageID string) string {
+	if tc.Subscription != "" {
+		return tc.Subscription + ":" + messageID
+	}
+	return messageID
+}
@@ -0,0 +1,158 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "must have 5 fields"},
+		{"* * * *", "must have 5 fields"},
+		{"* * * * * *", "must have 5 fields"},
+		{"@fortnightly", "must have 5 fields"},
+		{"60 * * * *", `value "60" out of range 0-59`},
+		{"* 24 * * *", `value "24" out of range 0-23`},
+		{"* * 0 * *", `value "0" out of range 1-31`},
+		{"* * * 13 *", `value "13" out of range 1-12`},
+		{"* * * * 8", `value "8" out of range 0-7`},
+		{"* * * foo *", `value "foo" out of range 1-12`},
+		{"*/0 * * * *", `invalid step in "*/0"`},
+		{"*/x * * * *", `invalid step in "*/x"`},
+		{"30-10 * * * *", `invalid range "30-10"`},
+		{"1-x * * * *", `value "x" out of range 0-59`},
+		{"1,,2 * * * *", `value "" out of range 0-59`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want error containing %q", tt.expr, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", utc(2024, 5, 10, 10, 0), utc(2024, 5, 10, 10, 1)},
+		{"strictly after", "0 10 * * *", utc(2024, 5, 10, 10, 0), utc(2024, 5, 11, 10, 0)},
+		{"seconds are dropped", "* * * * *", time.Date(2024, 5, 10, 10, 0, 59, 999, time.UTC), utc(2024, 5, 10, 10, 1)},
+		{"later today", "30 14 * * *", utc(2024, 5, 10, 10, 0), utc(2024, 5, 10, 14, 30)},
+		{"step", "*/15 * * * *", utc(2024, 5, 10, 10, 16), utc(2024, 5, 10, 10, 30)},
+		{"step wraps the hour", "*/15 * * * *", utc(2024, 5, 10, 10, 50), utc(2024, 5, 10, 11, 0)},
+		{"value with step", "5/20 * * * *", utc(2024, 5, 10, 10, 30), utc(2024, 5, 10, 10, 45)},
+		{"range with step", "0 9-17/4 * * *", utc(2024, 5, 10, 13, 0), utc(2024, 5, 10, 17, 0)},
+		{"list", "0 8,12,18 * * *", utc(2024, 5, 10, 12, 0), utc(2024, 5, 10, 18, 0)},
+		{"next day", "0 8 * * *", utc(2024, 5, 10, 23, 59), utc(2024, 5, 11, 8, 0)},
+		{"next year", "0 0 1 1 *", utc(2024, 12, 31, 23, 59), utc(2025, 1, 1, 0, 0)},
+		{"month names", "0 0 1 jan,JUL *", utc(2024, 2, 1, 0, 0), utc(2024, 7, 1, 0, 0)},
+		{"weekday", "0 9 * * mon-fri", utc(2024, 5, 10, 9, 0), utc(2024, 5, 13, 9, 0)},
+		{"sunday as 0", "0 0 * * 0", utc(2024, 5, 10, 0, 0), utc(2024, 5, 12, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", utc(2024, 5, 10, 0, 0), utc(2024, 5, 12, 0, 0)},
+		{"weekday names", "0 0 * * SAT", utc(2024, 5, 10, 0, 0), utc(2024, 5, 11, 0, 0)},
+		{"day of month or weekday", "0 0 13 * fri", utc(2024, 9, 1, 0, 0), utc(2024, 9, 6, 0, 0)},
+		{"day of month or weekday, day of month first", "0 0 13 * fri", utc(2024, 9, 7, 0, 0), utc(2024, 9, 13, 0, 0)},
+		{"day of month and any weekday", "0 0 13 * *", utc(2024, 9, 1, 0, 0), utc(2024, 9, 13, 0, 0)},
+		{"question mark", "0 0 ? * fri", utc(2024, 9, 1, 0, 0), utc(2024, 9, 6, 0, 0)},
+		{"hourly macro", "@hourly", utc(2024, 5, 10, 10, 5), utc(2024, 5, 10, 11, 0)},
+		{"daily macro", "@daily", utc(2024, 5, 10, 10, 5), utc(2024, 5, 11, 0, 0)},
+		{"weekly macro", "@weekly", utc(2024, 5, 10, 10, 5), utc(2024, 5, 12, 0, 0)},
+		{"monthly macro", "@monthly", utc(2024, 5, 10, 10, 5), utc(2024, 6, 1, 0, 0)},
+		{"yearly macro", "@Yearly", utc(2024, 5, 10, 10, 5), utc(2025, 1, 1, 0, 0)},
+
+		// End of month
+		{"31st skips short months", "0 0 31 * *", utc(2024, 1, 31, 0, 0), utc(2024, 3, 31, 0, 0)},
+		{"31st skips to next year", "0 12 31 * *", utc(2024, 12, 31, 12, 0), utc(2025, 1, 31, 12, 0)},
+		{"30th skips february", "0 0 30 * *", utc(2024, 1, 30, 0, 0), utc(2024, 3, 30, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2023, 3, 1, 0, 0), utc(2024, 2, 29, 0, 0)},
+		{"leap day after a leap year", "0 0 29 2 *", utc(2024, 2, 29, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"end of month rollover", "0 0 * * *", utc(2024, 4, 30, 0, 0), utc(2024, 5, 1, 0, 0)},
+		{"last minute of the month", "59 23 * * *", utc(2024, 2, 28, 23, 59), utc(2024, 2, 29, 23, 59)},
+		{"never", "0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+		{"no 31st of april", "0 0 31 4 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+		{"leap day beyond five years", "0 0 29 2 *", utc(2096, 3, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	local := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, ny)
+	}
+	// On 2024-03-10 New York skips from 02:00 EST to 03:00 EDT, and on
+	// 2024-11-03 it repeats 01:00-02:00, first in EDT, then in EST.
+	edtFold := local(2024, 11, 3, 1, 30)
+	estFold := edtFold.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"skipped hour is skipped", "30 2 * * *", local(2024, 3, 10, 0, 0), local(2024, 3, 11, 2, 30)},
+		{"hour after the gap", "30 3 * * *", local(2024, 3, 10, 0, 0), local(2024, 3, 10, 3, 30)},
+		{"hourly across the gap", "0 * * * *", local(2024, 3, 10, 1, 30), local(2024, 3, 10, 3, 0)},
+		{"every minute across the gap", "* * * * *", local(2024, 3, 10, 1, 59), local(2024, 3, 10, 3, 0)},
+		{"midnight after the gap", "0 0 * * *", local(2024, 3, 10, 0, 0), local(2024, 3, 11, 0, 0)},
+		{"repeated hour, first pass", "30 1 * * *", local(2024, 11, 3, 0, 0), edtFold},
+		{"repeated hour, second pass", "30 1 * * *", edtFold, estFold},
+		{"after the repeated hour", "30 1 * * *", estFold, local(2024, 11, 4, 1, 30)},
+		{"hourly across the fold", "0 * * * *", edtFold, edtFold.Add(30 * time.Minute)},
+		{"hour after the fold", "0 2 * * *", edtFold, local(2024, 11, 3, 2, 0)},
+		{"hour after the fold, second pass", "0 2 * * *", estFold, local(2024, 11, 3, 2, 0)},
+		{"day after the fold", "0 0 * * *", edtFold, local(2024, 11, 4, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			got := s.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+			if got.Location() != ny {
+				t.Errorf("Next(%v) is in %v, want %v", tt.from, got.Location(), ny)
+			}
+		})
+	}
+}
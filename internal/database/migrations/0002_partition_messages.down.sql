@@ -0,0 +1,17 @@
+ALTER TABLE messages RENAME TO messages_partitioned;
+ALTER INDEX messages_tenant_id_idx RENAME TO messages_partitioned_tenant_id_idx;
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX messages_tenant_id_idx ON messages (tenant_id, id);
+
+INSERT INTO messages (id, tenant_id, payload, created_at, updated_at)
+SELECT id, tenant_id, payload, created_at, updated_at FROM messages_partitioned;
+
+DROP TABLE messages_partitioned;
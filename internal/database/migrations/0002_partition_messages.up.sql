@@ -0,0 +1,25 @@
+-- Recreate messages as a range-partitioned table keyed on created_at. The
+-- primary key has to contain every partitioning column, including tenant_id
+-- for partitions that are further hash-partitioned by tenant.
+ALTER TABLE messages RENAME TO messages_unpartitioned;
+ALTER INDEX messages_tenant_id_idx RENAME TO messages_unpartitioned_tenant_id_idx;
+
+CREATE TABLE messages (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    tenant_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, created_at, id)
+) PARTITION BY RANGE (created_at);
+
+CREATE INDEX messages_tenant_id_idx ON messages (tenant_id, id);
+
+-- Catches rows outside any range partition; the partition maintainer moves
+-- them into the matching range partition when it creates one.
+CREATE TABLE messages_default PARTITION OF messages DEFAULT;
+
+INSERT INTO messages (id, tenant_id, payload, created_at, updated_at)
+SELECT id, tenant_id, payload, created_at, updated_at FROM messages_unpartitioned;
+
+DROP TABLE messages_unpartitioned;
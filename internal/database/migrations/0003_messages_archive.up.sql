@@ -0,0 +1,11 @@
+-- Destination for messages removed by a retention policy with the "archive"
+-- action.
+CREATE TABLE messages_archive (
+    id UUID NOT NULL,
+    tenant_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, created_at, id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS archived_messages;
+DROP TABLE IF EXISTS archive_segments;
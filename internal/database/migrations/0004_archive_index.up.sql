@@ -0,0 +1,24 @@
+-- Index of messages moved to cold archive segments in object storage.
+CREATE TABLE archive_segments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id TEXT NOT NULL,
+    object_key TEXT NOT NULL UNIQUE,
+    min_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    max_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    message_count INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX archive_segments_tenant_idx ON archive_segments (tenant_id, min_created_at);
+
+CREATE TABLE archived_messages (
+    tenant_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    message_id UUID NOT NULL,
+    segment_id UUID NOT NULL REFERENCES archive_segments (id) ON DELETE CASCADE,
+    PRIMARY KEY (tenant_id, created_at, message_id)
+);
+
+CREATE INDEX archived_messages_id_idx ON archived_messages (tenant_id, message_id);
+CREATE INDEX archived_messages_segment_idx ON archived_messages (segment_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS erasure_jobs;
+ALTER TABLE messages_archive DROP COLUMN IF EXISTS headers;
+ALTER TABLE messages DROP COLUMN IF EXISTS headers;
@@ -0,0 +1,19 @@
+-- Message headers let erasure target a data subject identified by a header.
+ALTER TABLE messages ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE messages_archive ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE erasure_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id TEXT NOT NULL,
+    subject_header TEXT NOT NULL DEFAULT '',
+    subject_value TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    result JSONB NOT NULL DEFAULT '{}',
+    certificate JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX erasure_jobs_pending_idx ON erasure_jobs (created_at) WHERE status = 'pending';
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS messages_correlation_id_idx;
+
+ALTER TABLE messages_archive
+    DROP COLUMN IF EXISTS attributes,
+    DROP COLUMN IF EXISTS routing_key,
+    DROP COLUMN IF EXISTS causation_id,
+    DROP COLUMN IF EXISTS correlation_id,
+    DROP COLUMN IF EXISTS content_type;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS attributes,
+    DROP COLUMN IF EXISTS routing_key,
+    DROP COLUMN IF EXISTS causation_id,
+    DROP COLUMN IF EXISTS correlation_id,
+    DROP COLUMN IF EXISTS content_type;
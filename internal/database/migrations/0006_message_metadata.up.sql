@@ -0,0 +1,15 @@
+ALTER TABLE messages
+    ADD COLUMN content_type TEXT NOT NULL DEFAULT 'text/plain',
+    ADD COLUMN correlation_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN causation_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN routing_key TEXT NOT NULL DEFAULT '',
+    ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE messages_archive
+    ADD COLUMN content_type TEXT NOT NULL DEFAULT 'text/plain',
+    ADD COLUMN correlation_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN causation_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN routing_key TEXT NOT NULL DEFAULT '',
+    ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX messages_correlation_id_idx ON messages (tenant_id, correlation_id) WHERE correlation_id <> '';
@@ -0,0 +1,9 @@
+-- Payloads that are not valid UTF-8 cannot be converted back and make this
+-- rollback fail.
+ALTER TABLE messages_archive
+    DROP COLUMN IF EXISTS content_encoding,
+    ALTER COLUMN payload TYPE TEXT USING convert_from(payload, 'UTF8');
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS content_encoding,
+    ALTER COLUMN payload TYPE TEXT USING convert_from(payload, 'UTF8');
@@ -0,0 +1,7 @@
+ALTER TABLE messages
+    ALTER COLUMN payload TYPE BYTEA USING convert_to(payload, 'UTF8'),
+    ADD COLUMN content_encoding TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE messages_archive
+    ALTER COLUMN payload TYPE BYTEA USING convert_to(payload, 'UTF8'),
+    ADD COLUMN content_encoding TEXT NOT NULL DEFAULT '';
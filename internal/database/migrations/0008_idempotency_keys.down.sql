@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS idempotency_keys;
+
+ALTER TABLE messages_archive DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE messages DROP COLUMN IF EXISTS idempotency_key;
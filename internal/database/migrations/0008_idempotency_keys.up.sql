@@ -0,0 +1,17 @@
+ALTER TABLE messages ADD COLUMN idempotency_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages_archive ADD COLUMN idempotency_key TEXT NOT NULL DEFAULT '';
+
+-- A row with status_code 0 is a reservation held by a publish in progress.
+CREATE TABLE idempotency_keys (
+    tenant_id TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    message_id TEXT NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (tenant_id, idempotency_key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
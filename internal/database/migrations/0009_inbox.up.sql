@@ -0,0 +1,10 @@
+-- Message IDs processed by tenants with the inbox enabled, used to skip
+-- redelivered messages.
+CREATE TABLE inbox_messages (
+    tenant_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, message_id)
+);
+
+CREATE INDEX inbox_messages_processed_at_idx ON inbox_messages (tenant_id, processed_at);
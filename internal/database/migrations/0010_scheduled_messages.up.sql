@@ -0,0 +1,19 @@
+-- Messages published with a future delivery time. The message itself is
+-- stored in messages; this row tracks when, and whether, it is enqueued.
+CREATE TABLE scheduled_messages (
+    tenant_id TEXT NOT NULL,
+    message_id UUID NOT NULL,
+    deliver_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    claimed_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (tenant_id, message_id)
+);
+
+CREATE INDEX scheduled_messages_due_idx ON scheduled_messages (deliver_at)
+    WHERE status IN ('pending', 'dispatching');
+CREATE INDEX scheduled_messages_tenant_idx ON scheduled_messages (tenant_id, status, deliver_at, message_id);
@@ -0,0 +1,2 @@
+ALTER TABLE messages_archive DROP COLUMN IF EXISTS priority;
+ALTER TABLE messages DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,2 @@
+ALTER TABLE messages ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE messages_archive ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE messages_archive DROP COLUMN IF EXISTS status, DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS status, DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,7 @@
+ALTER TABLE messages
+    ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'accepted';
+
+ALTER TABLE messages_archive
+    ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'accepted';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS messages_queued_expiry_idx;
+DROP INDEX IF EXISTS messages_status_idx;
+
+ALTER TABLE messages_archive DROP COLUMN IF EXISTS delivery_attempts, DROP COLUMN IF EXISTS status_timestamps;
+ALTER TABLE messages DROP COLUMN IF EXISTS delivery_attempts, DROP COLUMN IF EXISTS status_timestamps;
@@ -0,0 +1,16 @@
+ALTER TABLE messages
+    ADD COLUMN status_timestamps JSONB NOT NULL DEFAULT jsonb_build_object('accepted', CURRENT_TIMESTAMP),
+    ADD COLUMN delivery_attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE messages_archive
+    ADD COLUMN status_timestamps JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN delivery_attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Earlier messages only tracked acceptance and expiry
+UPDATE messages SET status_timestamps = jsonb_build_object('accepted', created_at)
+    || CASE WHEN status <> 'accepted' THEN jsonb_build_object(status, updated_at) ELSE '{}' END;
+UPDATE messages_archive SET status_timestamps = jsonb_build_object('accepted', created_at)
+    || CASE WHEN status <> 'accepted' THEN jsonb_build_object(status, updated_at) ELSE '{}' END;
+
+CREATE INDEX messages_status_idx ON messages (tenant_id, status, created_at, id);
+CREATE INDEX messages_queued_expiry_idx ON messages (expires_at) WHERE status = 'queued' AND expires_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Lifecycle events of messages, shown as each message's timeline. Rows are
+-- kept apart from messages so archiving a message keeps its history.
+CREATE TABLE message_events (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id TEXT NOT NULL,
+    message_id UUID NOT NULL,
+    type TEXT NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    consumer_tag TEXT NOT NULL DEFAULT '',
+    node_id TEXT NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX message_events_message_idx ON message_events (tenant_id, message_id, occurred_at, id);
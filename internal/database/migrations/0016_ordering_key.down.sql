@@ -0,0 +1,2 @@
+ALTER TABLE messages_archive DROP COLUMN IF EXISTS ordering_key;
+ALTER TABLE messages DROP COLUMN IF EXISTS ordering_key;
@@ -0,0 +1,2 @@
+ALTER TABLE messages ADD COLUMN ordering_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages_archive ADD COLUMN ordering_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS archive_segments_tenant_max_idx;
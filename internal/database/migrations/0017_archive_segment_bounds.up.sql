@@ -0,0 +1,2 @@
+-- Lets listings find the segment holding a cursor by its upper bound.
+CREATE INDEX archive_segments_tenant_max_idx ON archive_segments (tenant_id, max_created_at);
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+)
+
+// partitionLockID guards partition maintenance across server instances.
+const partitionLockID int64 = 7_240_031_027
+
+const partitionPrefix = "messages_p"
+
+// PartitionMaintainer keeps range partitions of the messages table created
+// ahead of time and detaches (or drops) partitions past retention.
+type PartitionMaintainer struct {
+	db  *sql.DB
+	cfg config.PartitionConfig
+}
+
+// NewPartitionMaintainer creates a maintainer, filling in defaults for any
+// unset options.
+func NewPartitionMaintainer(db *sql.DB, cfg config.PartitionConfig) (*PartitionMaintainer, error) {
+	switch cfg.Interval {
+	case "":
+		cfg.Interval = "month"
+	case "day", "week", "month":
+	default:
+		return nil, fmt.Errorf("invalid partition interval %q", cfg.Interval)
+	}
+	if cfg.Premake <= 0 {
+		cfg.Premake = 3
+	}
+	if cfg.CheckIntervalSeconds <= 0 {
+		cfg.CheckIntervalSeconds = 3600
+	}
+	return &PartitionMaintainer{db: db, cfg: cfg}, nil
+}
+
+// Run performs maintenance immediately and then on every check interval
+// until ctx is cancelled.
+func (m *PartitionMaintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(m.cfg.CheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := m.RunOnce(ctx); err != nil {
+			log.Printf("Partition maintenance failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates missing partitions from the current period up to Premake
+// periods ahead and retires partitions older than RetentionPeriods. It is a
+// no-op if another instance is already running maintenance.
+func (m *PartitionMaintainer) RunOnce(ctx context.Context) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", partitionLockID).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire partition lock: %w", err)
+	}
+	if !locked {
+		return nil
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", partitionLockID); err != nil {
+			log.Printf("Failed to release partition lock: %v", err)
+		}
+	}()
+
+	existing, err := listPartitions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	start := m.truncate(time.Now().UTC())
+	for i := 0; i <= m.cfg.Premake; i++ {
+		end := m.next(start)
+		name := partitionName(start)
+		if !existing[name] {
+			if err := m.createPartition(ctx, conn, name, start, end); err != nil {
+				return err
+			}
+			log.Printf("Created partition %s [%s, %s)", name, start.Format(time.DateOnly), end.Format(time.DateOnly))
+		}
+		start = end
+	}
+
+	if m.cfg.RetentionPeriods <= 0 {
+		return nil
+	}
+	cutoff := m.truncate(time.Now().UTC())
+	for i := 0; i < m.cfg.RetentionPeriods; i++ {
+		cutoff = m.prev(cutoff)
+	}
+	for name := range existing {
+		start, ok := partitionStart(name)
+		if !ok || m.next(start).After(cutoff) {
+			continue
+		}
+		if err := m.retirePartition(ctx, conn, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createPartition builds the partition as a standalone table, moves any rows
+// for its range out of the default partition, and only then attaches it;
+// attaching directly would fail if the default partition held such rows.
+func (m *PartitionMaintainer) createPartition(ctx context.Context, conn *sql.Conn, name string, start, end time.Time) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	create := fmt.Sprintf("CREATE TABLE %s (LIKE messages INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", name)
+	if m.cfg.TenantHashPartitions > 1 {
+		create += " PARTITION BY HASH (tenant_id)"
+	}
+	if _, err := tx.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", name, err)
+	}
+	for r := 0; r < m.cfg.TenantHashPartitions && m.cfg.TenantHashPartitions > 1; r++ {
+		sub := fmt.Sprintf(
+			"CREATE TABLE %s_h%d PARTITION OF %s FOR VALUES WITH (MODULUS %d, REMAINDER %d)",
+			name, r, name, m.cfg.TenantHashPartitions, r)
+		if _, err := tx.ExecContext(ctx, sub); err != nil {
+			return fmt.Errorf("failed to create hash partition %s_h%d: %w", name, r, err)
+		}
+	}
+
+	move := fmt.Sprintf(`
+        WITH moved AS (
+            DELETE FROM messages_default
+            WHERE created_at >= $1 AND created_at < $2
+            RETURNING *
+        )
+        INSERT INTO %s SELECT * FROM moved
+    `, name)
+	if _, err := tx.ExecContext(ctx, move, start, end); err != nil {
+		return fmt.Errorf("failed to move default rows into %s: %w", name, err)
+	}
+
+	attach := fmt.Sprintf(
+		"ALTER TABLE messages ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')",
+		name, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	if _, err := tx.ExecContext(ctx, attach); err != nil {
+		return fmt.Errorf("failed to attach partition %s: %w", name, err)
+	}
+	return tx.Commit()
+}
+
+func (m *PartitionMaintainer) retirePartition(ctx context.Context, conn *sql.Conn, name string) error {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE messages DETACH PARTITION %s", name)); err != nil {
+		return fmt.Errorf("failed to detach partition %s: %w", name, err)
+	}
+	if !m.cfg.DropExpired {
+		log.Printf("Detached expired partition %s", name)
+		return nil
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", name)); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", name, err)
+	}
+	log.Printf("Dropped expired partition %s", name)
+	return nil
+}
+
+// listPartitions returns the names of range partitions attached to messages.
+func listPartitions(ctx context.Context, conn *sql.Conn) (map[string]bool, error) {
+	query := `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'messages' AND c.relname LIKE 'messages\_p%'
+    `
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan partition row: %w", err)
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
+
+func partitionName(start time.Time) string {
+	return partitionPrefix + start.Format("20060102")
+}
+
+func partitionStart(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	start, err := time.Parse("20060102", suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+func (m *PartitionMaintainer) truncate(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch m.cfg.Interval {
+	case "day":
+		return day
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7 // weeks start on Monday
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func (m *PartitionMaintainer) next(start time.Time) time.Time {
+	switch m.cfg.Interval {
+	case "day":
+		return start.AddDate(0, 0, 1)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+func (m *PartitionMaintainer) prev(start time.Time) time.Time {
+	switch m.cfg.Interval {
+	case "day":
+		return start.AddDate(0, 0, -1)
+	case "week":
+		return start.AddDate(0, 0, -7)
+	default:
+		return start.AddDate(0, -1, 0)
+	}
+}
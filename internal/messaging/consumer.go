@@ -1,39 +1,41 @@
 package messaging
 
 import (
-    "log"
-    "github.com/streadway/amqp"
+	"log"
+
+	"github.com/rabbitmq/amqp091-go"
 )
 
 type Consumer struct {
-    Channel *amqp.Channel
-    Queue   string
+	Channel *amqp091.Channel
+	Queue   string
 }
 
-func NewConsumer(channel *amqp.Channel, queue string) *Consumer {
-    return &Consumer{
-        Channel: channel,
-        Queue:   queue,
-    }
+func NewConsumer(channel *amqp091.Channel, queue string) *Consumer {
+	return &Consumer{
+		Channel: channel,
+		Queue:   queue,
+	}
 }
 
 func (c *Consumer) StartConsuming() {
-    msgs, err := c.Channel.Consume(
-        c.Queue,
-        "",    // consumer
-        false, // auto-ack
-        false, // exclusive
-        false, // no-local
-        false, // no-wait
-        nil,   // args
-    )
-    if err != nil {
-        log.Fatalf("Failed to register a consumer: %s", err)
-    }
+	msgs, err := c.Channel.Consume(
+		c.Queue,
+		"",    // consumer
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register a consumer: %s", err)
+	}
 
-    for msg := range msgs {
-        log.Printf("Received a message: %s", msg.Body)
-        // Process the message here
-        msg.Ack(false) // Acknowledge the message
-    }
-}
\ No newline at end of file
+	for d := range msgs {
+		msg := FromDelivery(d)
+		log.Printf("Received message %s (%s, %d bytes, correlation %q)", msg.ID, msg.ContentType, len(msg.Payload), msg.CorrelationID)
+		// Process the message here
+		d.Ack(false) // Acknowledge the message
+	}
+}
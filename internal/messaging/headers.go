@@ -0,0 +1,279 @@
+package messaging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP headers used to carry message fields that have no standard AMQP
+// property. User headers travel as top-level headers alongside them.
+const (
+	HeaderTenantID    = "x-tenant-id"
+	HeaderCausationID = "x-causation-id"
+	HeaderRoutingKey  = "x-routing-key"
+	// HeaderOrderingKey carries the key ordered consumers dispatch by.
+	HeaderOrderingKey = "x-ordering-key"
+	HeaderAttributes  = "x-attributes"
+	// HeaderMessageID carries the message ID when the AMQP message_id is
+	// taken by the message's idempotency key.
+	HeaderMessageID = "x-message-id"
+	// HeaderExpiresAt carries the absolute expiry time; the AMQP expiration
+	// property only holds the TTL remaining at publish.
+	HeaderExpiresAt = "x-expires-at"
+	// HeaderDeadLetterReason says why the system dead-lettered a message.
+	// Messages dead-lettered by RabbitMQ itself carry x-first-death-reason.
+	HeaderDeadLetterReason = "x-dead-letter-reason"
+	// HeaderDeadLetterError carries the handler's last error when a message
+	// is dead-lettered after too many failed attempts.
+	HeaderDeadLetterError = "x-dead-letter-error"
+	// HeaderDeliveryAttempt counts the failed attempts of a message retried
+	// through a subscription queue.
+	HeaderDeliveryAttempt = "x-delivery-attempt"
+)
+
+// DeadLetterExchange receives the messages tenant queues dead-letter.
+const DeadLetterExchange = "dlx"
+
+var reservedHeaders = map[string]bool{
+	HeaderTenantID:    true,
+	HeaderCausationID: true,
+	HeaderRoutingKey:  true,
+	HeaderOrderingKey: true,
+	HeaderAttributes:  true,
+	HeaderMessageID:   true,
+	HeaderExpiresAt:   true,
+	// Dead-letter bookkeeping is not part of the message
+	HeaderDeadLetterReason:   true,
+	HeaderDeadLetterError:    true,
+	HeaderDeliveryAttempt:    true,
+	"x-death":                true,
+	"x-first-death-reason":   true,
+	"x-first-death-queue":    true,
+	"x-first-death-exchange": true,
+	"x-last-death-reason":    true,
+	"x-last-death-queue":     true,
+	"x-last-death-exchange":  true,
+}
+
+// TenantQueueName returns the name of a tenant's queue.
+func TenantQueueName(tenantID string) string {
+	return fmt.Sprintf("tenant_%s_queue", tenantID)
+}
+
+// TenantExchangeName returns the name of the topic exchange a tenant's
+// messages are published to, routed by their routing key.
+func TenantExchangeName(tenantID string) string {
+	return fmt.Sprintf("tenant_%s_topic", tenantID)
+}
+
+// SubscriptionQueueName returns the name of a tenant subscription's queue.
+func SubscriptionQueueName(tenantID, subscription string) string {
+	return fmt.Sprintf("tenant_%s_sub_%s", tenantID, subscription)
+}
+
+// DeclareTenantExchange declares a tenant's topic exchange.
+func DeclareTenantExchange(ch *amqp091.Channel, tenantID string) error {
+	if err := ch.ExchangeDeclare(TenantExchangeName(tenantID), "topic", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare tenant exchange: %w", err)
+	}
+	return nil
+}
+
+// TopicMatches reports whether a topic binding pattern matches a routing
+// key the way RabbitMQ's topic exchanges do: "*" matches exactly one word
+// and "#" zero or more.
+func TopicMatches(pattern, key string) bool {
+	var words []string
+	if key != "" {
+		words = strings.Split(key, ".")
+	}
+	// match[j] reports whether the pattern words seen so far match the
+	// first j words of the key
+	match := make([]bool, len(words)+1)
+	match[0] = true
+	for _, p := range strings.Split(pattern, ".") {
+		next := make([]bool, len(words)+1)
+		for j := range next {
+			switch {
+			case p == "#":
+				next[j] = match[j] || (j > 0 && next[j-1])
+			case j > 0:
+				next[j] = match[j-1] && (p == "*" || p == words[j-1])
+			}
+		}
+		match = next
+	}
+	return match[len(words)]
+}
+
+// DeadLetterQueueName returns the name of a tenant's dead-letter queue.
+func DeadLetterQueueName(tenantID string) string {
+	return fmt.Sprintf("tenant_%s_dlq", tenantID)
+}
+
+// DeadLetterRoutingKey returns the key that routes a tenant's dead letters
+// from DeadLetterExchange to its dead-letter queue.
+func DeadLetterRoutingKey(tenantID string) string {
+	return fmt.Sprintf("dl.%s", tenantID)
+}
+
+// ToPublishing maps a message onto an AMQP publishing. A message published
+// with an idempotency key carries the key as its AMQP message_id, so that
+// retried publishes share it and consumers can dedupe on it. A message with
+// an expiry gets the remaining time as its AMQP expiration, so RabbitMQ
+// dead-letters it if it is still queued then.
+func ToPublishing(msg *models.Message) amqp091.Publishing {
+	headers := amqp091.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderTenantID] = msg.TenantID
+	if msg.CausationID != "" {
+		headers[HeaderCausationID] = msg.CausationID
+	}
+	if msg.RoutingKey != "" {
+		headers[HeaderRoutingKey] = msg.RoutingKey
+	}
+	if msg.OrderingKey != "" {
+		headers[HeaderOrderingKey] = msg.OrderingKey
+	}
+	if len(msg.Attributes) > 0 {
+		headers[HeaderAttributes] = toTable(msg.Attributes)
+	}
+	var expiration string
+	if msg.ExpiresAt != nil {
+		headers[HeaderExpiresAt] = msg.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		ttl := time.Until(*msg.ExpiresAt).Milliseconds()
+		if ttl < 0 {
+			ttl = 0
+		}
+		expiration = strconv.FormatInt(ttl, 10)
+	}
+	messageID := msg.ID
+	if msg.IdempotencyKey != "" {
+		messageID = msg.IdempotencyKey
+		headers[HeaderMessageID] = msg.ID
+	}
+
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	return amqp091.Publishing{
+		Headers:         headers,
+		ContentType:     contentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    amqp091.Persistent,
+		Priority:        msg.Priority,
+		Expiration:      expiration,
+		CorrelationId:   msg.CorrelationID,
+		MessageId:       messageID,
+		Timestamp:       msg.CreatedAt,
+		Body:            msg.Payload,
+	}
+}
+
+// FromDelivery rebuilds a message from an AMQP delivery produced by
+// ToPublishing.
+func FromDelivery(d amqp091.Delivery) *models.Message {
+	msg := &models.Message{
+		ID:              d.MessageId,
+		Payload:         d.Body,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		CorrelationID:   d.CorrelationId,
+		Priority:        d.Priority,
+		CreatedAt:       d.Timestamp,
+	}
+
+	for k, v := range d.Headers {
+		switch k {
+		case HeaderTenantID:
+			msg.TenantID, _ = v.(string)
+		case HeaderCausationID:
+			msg.CausationID, _ = v.(string)
+		case HeaderRoutingKey:
+			msg.RoutingKey, _ = v.(string)
+		case HeaderOrderingKey:
+			msg.OrderingKey, _ = v.(string)
+		case HeaderExpiresAt:
+			if s, ok := v.(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					msg.ExpiresAt = &t
+				}
+			}
+		case HeaderMessageID:
+			if id, ok := v.(string); ok {
+				msg.ID, msg.IdempotencyKey = id, d.MessageId
+			}
+		case HeaderAttributes:
+			if t, ok := v.(amqp091.Table); ok {
+				msg.Attributes = fromTable(t)
+			}
+		default:
+			if reservedHeaders[k] {
+				continue
+			}
+			if s, ok := v.(string); ok {
+				if msg.Headers == nil {
+					msg.Headers = models.Headers{}
+				}
+				msg.Headers[k] = s
+			}
+		}
+	}
+	return msg
+}
+
+// toTable converts JSON-decoded values into types an AMQP table accepts.
+func toTable(m map[string]interface{}) amqp091.Table {
+	t := amqp091.Table{}
+	for k, v := range m {
+		t[k] = toFieldValue(v)
+	}
+	return t
+}
+
+func toFieldValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return toTable(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = toFieldValue(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+func fromTable(t amqp091.Table) map[string]interface{} {
+	m := make(map[string]interface{}, len(t))
+	for k, v := range t {
+		m[k] = fromFieldValue(v)
+	}
+	return m
+}
+
+func fromFieldValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case amqp091.Table:
+		return fromTable(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = fromFieldValue(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
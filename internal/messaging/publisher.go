@@ -1,33 +1,92 @@
 package messaging
 
 import (
-    "github.com/streadway/amqp"
-    "log"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/rabbitmq/amqp091-go"
 )
 
+// ErrNotConfirmed is returned when the broker nacks a published message.
+var ErrNotConfirmed = errors.New("message not confirmed by broker")
+
 type Publisher struct {
-    Channel *amqp.Channel
+	Channel *amqp091.Channel
+	mu      sync.Mutex
+}
+
+// NewPublisher puts channel into confirm mode, so every publish waits for
+// the broker to take responsibility for the message.
+func NewPublisher(channel *amqp091.Channel) (*Publisher, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return &Publisher{Channel: channel}, nil
+}
+
+// Publish sends msg to its tenant's topic exchange under its routing key,
+// carrying its metadata in AMQP properties and headers, and waits for the
+// broker's confirm.
+func (p *Publisher) Publish(ctx context.Context, msg *models.Message) error {
+	// Publishing to a missing exchange closes the channel, so the exchange
+	// is declared every time rather than trusting that the tenant's
+	// consumers, perhaps on another node, have declared it
+	p.mu.Lock()
+	err := DeclareTenantExchange(p.Channel, msg.TenantID)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	exchange := TenantExchangeName(msg.TenantID)
+	if err := p.publish(ctx, exchange, msg.RoutingKey, ToPublishing(msg)); err != nil {
+		log.Printf("Failed to publish message: %s", err)
+		return err
+	}
+	log.Printf("Message published to exchange: %s", exchange)
+	return nil
 }
 
-func NewPublisher(channel *amqp.Channel) *Publisher {
-    return &Publisher{Channel: channel}
+// PublishToQueue sends msg straight to the named queue through the default
+// exchange. The broker drops it if the queue does not exist.
+func (p *Publisher) PublishToQueue(ctx context.Context, queue string, msg *models.Message) error {
+	if err := p.publish(ctx, "", queue, ToPublishing(msg)); err != nil {
+		return fmt.Errorf("failed to publish message to queue %s: %w", queue, err)
+	}
+	return nil
 }
 
-func (p *Publisher) Publish(queueName string, message []byte) error {
-    err := p.Channel.Publish(
-        "",         // exchange
-        queueName, // routing key
-        false,     // mandatory
-        false,     // immediate
-        amqp.Publishing{
-            ContentType: "text/plain",
-            Body:        message,
-        },
-    )
-    if err != nil {
-        log.Printf("Failed to publish message: %s", err)
-        return err
-    }
-    log.Printf("Message published to queue: %s", queueName)
-    return nil
-}
\ No newline at end of file
+// DeadLetter sends msg to its tenant's dead-letter queue, recording reason
+// in the x-dead-letter-reason header.
+func (p *Publisher) DeadLetter(ctx context.Context, msg *models.Message, reason string) error {
+	publishing := ToPublishing(msg)
+	publishing.Expiration = ""
+	publishing.Headers[HeaderDeadLetterReason] = reason
+	if err := p.publish(ctx, DeadLetterExchange, DeadLetterRoutingKey(msg.TenantID), publishing); err != nil {
+		return fmt.Errorf("failed to dead-letter message: %w", err)
+	}
+	return nil
+}
+
+// publish sends publishing and waits for its confirm. Only sending holds
+// the lock, so concurrent publishes wait for their confirms together.
+func (p *Publisher) publish(ctx context.Context, exchange, key string, publishing amqp091.Publishing) error {
+	p.mu.Lock()
+	confirm, err := p.Channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, publishing)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNotConfirmed
+	}
+	return nil
+}
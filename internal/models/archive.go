@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ArchiveSegment is one compressed file of archived messages.
+type ArchiveSegment struct {
+	ID           string    `json:"id"`
+	TenantID     string    `json:"tenant_id"`
+	ObjectKey    string    `json:"object_key"`
+	MinCreatedAt time.Time `json:"min_created_at"`
+	MaxCreatedAt time.Time `json:"max_created_at"`
+	MessageCount int       `json:"message_count"`
+	SizeBytes    int64     `json:"size_bytes"`
+}
+
+// ArchivedMessageRef locates an archived message within its segment.
+type ArchivedMessageRef struct {
+	MessageID string
+	CreatedAt time.Time
+	SegmentID string
+	ObjectKey string
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Erasure job statuses.
+const (
+	ErasurePending   = "pending"
+	ErasureRunning   = "running"
+	ErasureCompleted = "completed"
+	ErasureFailed    = "failed"
+)
+
+// ErasureJob removes a tenant's data, or only the data of one subject
+// identified by a message header.
+type ErasureJob struct {
+	ID            string              `json:"id"`
+	TenantID      string              `json:"tenant_id"`
+	SubjectHeader string              `json:"subject_header,omitempty"`
+	SubjectValue  string              `json:"-"`
+	Status        string              `json:"status"`
+	Result        ErasureResult       `json:"result"`
+	Certificate   *ErasureCertificate `json:"certificate,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	StartedAt     *time.Time          `json:"started_at,omitempty"`
+	FinishedAt    *time.Time          `json:"finished_at,omitempty"`
+}
+
+// ErasureResult counts what an erasure job removed.
+type ErasureResult struct {
+	Messages           int64 `json:"messages"`
+	RetainedArchive    int64 `json:"retained_archive_messages"`
+	ArchivedMessages   int64 `json:"archived_messages"`
+	SegmentsDeleted    int64 `json:"segments_deleted"`
+	SegmentsRewritten  int64 `json:"segments_rewritten"`
+	QueueMessages      int64 `json:"queue_messages"`
+	IdempotencyRecords int64 `json:"idempotency_records"`
+	MessageEvents      int64 `json:"message_events"`
+	// Counts added after certificates were first issued are omitted when
+	// zero, so the signatures of earlier certificates still verify.
+	RecurringSchedules int64 `json:"recurring_schedules,omitempty"`
+	RecurringRuns      int64 `json:"recurring_runs,omitempty"`
+	ScheduledMessages  int64 `json:"scheduled_messages,omitempty"`
+	InboxRecords       int64 `json:"inbox_records,omitempty"`
+}
+
+// ErasureCertificate is a signed statement of what an erasure job removed.
+// The subject value itself is not retained; only its SHA-256 digest is.
+type ErasureCertificate struct {
+	JobID         string        `json:"job_id"`
+	TenantID      string        `json:"tenant_id"`
+	Scope         string        `json:"scope"`
+	SubjectHeader string        `json:"subject_header,omitempty"`
+	SubjectDigest string        `json:"subject_sha256,omitempty"`
+	Removed       ErasureResult `json:"removed"`
+	IssuedAt      time.Time     `json:"issued_at"`
+	Algorithm     string        `json:"algorithm"`
+	Signature     string        `json:"signature"`
+}
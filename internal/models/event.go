@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Message event types. Events that record a status change are named after
+// the status.
+const (
+	// EventAccepted is recorded when a message is submitted for publishing,
+	// and EventStored once it is written to the messages table.
+	EventAccepted  = "accepted"
+	EventStored    = "stored"
+	EventScheduled = "scheduled"
+	// EventPublished is recorded once the broker confirmed the message.
+	EventPublished     = "published"
+	EventPublishFailed = "publish_failed"
+	// EventRouted is recorded when a routing rule sends the message to a
+	// subscription.
+	EventRouted = "routed"
+	// EventDeliveryAttempt is recorded each time a worker receives the
+	// message, followed by the handler's result.
+	EventDeliveryAttempt  = "delivery_attempt"
+	EventHandlerSucceeded = "handler_succeeded"
+	EventHandlerFailed    = "handler_failed"
+	EventDuplicateSkipped = "duplicate_skipped"
+	// EventRequeued is recorded when a failed attempt is sent back to the
+	// queue for a retry.
+	EventRequeued     = "requeued"
+	EventDeadLettered = MessageDeadLettered
+	EventExpired      = MessageExpired
+	// EventRestored is recorded when an archived message is restored to the
+	// messages table.
+	EventRestored = "restored"
+)
+
+// MessageEvent is one entry in a message's timeline.
+type MessageEvent struct {
+	ID        int64  `json:"id"`
+	TenantID  string `json:"-"`
+	MessageID string `json:"-"`
+	Type      string `json:"type"`
+	// Attempt is the delivery attempt the event belongs to, if any.
+	Attempt     int    `json:"attempt,omitempty"`
+	ConsumerTag string `json:"consumer_tag,omitempty"`
+	// NodeID identifies the server instance that recorded the event.
+	NodeID     string       `json:"node_id"`
+	Details    EventDetails `json:"details,omitempty"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// EventDetails are string key/value pairs describing an event, such as an
+// error or a dead-letter reason, stored as JSONB.
+type EventDetails map[string]string
+
+// Value implements driver.Valuer.
+func (d EventDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan implements sql.Scanner.
+func (d *EventDetails) Scan(src interface{}) error {
+	return scanJSON(src, d)
+}
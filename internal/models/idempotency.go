@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the outcome of a publish made with an
+// Idempotency-Key so that retries of it get the same response.
+type IdempotencyRecord struct {
+	TenantID string
+	Key      string
+	// RequestHash fingerprints the request, to detect a key being reused
+	// for a different message.
+	RequestHash string
+	MessageID   string
+	// StatusCode is 0 while the original request is still in progress.
+	StatusCode int
+	Response   []byte
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// Completed reports whether the original request has finished.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -1,10 +1,100 @@
 package models
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // Message represents a message in the messaging system.
 type Message struct {
-	ID        string `json:"id"`
-	TenantID  string `json:"tenant_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	// Payload is the message body. Its format is described by ContentType
+	// and, when the bytes are themselves encoded (e.g. gzip), by
+	// ContentEncoding.
+	Payload         []byte `json:"payload"`
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	CorrelationID   string `json:"correlation_id,omitempty"`
+	CausationID     string `json:"causation_id,omitempty"`
+	RoutingKey      string `json:"routing_key,omitempty"`
+	// OrderingKey groups messages that tenants with ordered processing
+	// handle strictly one at a time, in order.
+	OrderingKey string `json:"ordering_key,omitempty"`
+	// Priority orders the message in its tenant's queue: higher values are
+	// delivered first, up to the tenant's max priority.
+	Priority uint8 `json:"priority,omitempty"`
+	// IdempotencyKey is the client-supplied key the message was published
+	// with, if any.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// DeliverAt, when set on publish, defers enqueueing the message until
+	// then. It is tracked in scheduled_messages rather than on the message.
+	DeliverAt *time.Time `json:"deliver_at,omitempty"`
+	// ExpiresAt, if set, is when the message becomes worthless; it is
+	// dead-lettered instead of being handled after that.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Status is where the message is in its lifecycle, one of the Message*
+	// statuses.
+	Status           string           `json:"status,omitempty"`
+	StatusTimestamps StatusTimestamps `json:"status_timestamps,omitempty"`
+	// DeliveryAttempts counts the times a worker started handling it.
+	DeliveryAttempts int        `json:"delivery_attempts,omitempty"`
+	Headers          Headers    `json:"headers,omitempty"`
+	Attributes       Attributes `json:"attributes,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Expired reports whether the message has expired at now.
+func (m *Message) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// Headers are string key/value pairs attached to a message, stored as JSONB
+// and carried as top-level AMQP headers.
+type Headers map[string]string
+
+// Value implements driver.Valuer.
+func (h Headers) Value() (driver.Value, error) {
+	if h == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(h)
+}
+
+// Scan implements sql.Scanner.
+func (h *Headers) Scan(src interface{}) error {
+	return scanJSON(src, h)
+}
+
+// Attributes are arbitrary JSON values attached to a message, stored as
+// JSONB.
+type Attributes map[string]interface{}
+
+// Value implements driver.Valuer.
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements sql.Scanner.
+func (a *Attributes) Scan(src interface{}) error {
+	return scanJSON(src, a)
+}
+
+func scanJSON(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
 }
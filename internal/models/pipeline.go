@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPipelineVersionNotFound is returned when activating a pipeline version
+// that does not exist.
+var ErrPipelineVersionNotFound = errors.New("pipeline version not found")
+
+// Transformation step types.
+const (
+	// StepRename moves the field From to To.
+	StepRename = "rename"
+	// StepDrop removes Fields.
+	StepDrop = "drop"
+	// StepSet sets Field to Value.
+	StepSet = "set"
+	// StepLookup sets To to the Table entry for the value of From, or to
+	// Default if there is none.
+	StepLookup = "lookup"
+	// StepFormat converts the payload to Format. It must be the last step.
+	StepFormat = "format"
+)
+
+// Payload formats a pipeline reads and writes.
+const (
+	FormatJSON = "json"
+	FormatForm = "form"
+)
+
+// MaxPipelineVersions bounds the versions kept per pipeline; the oldest
+// inactive versions are dropped first.
+const MaxPipelineVersions = 20
+
+// TransformStep is one declarative step of a transformation pipeline.
+// Fields are dot-separated paths into the payload's JSON object.
+type TransformStep struct {
+	Type    string                 `json:"type"`
+	From    string                 `json:"from,omitempty"`
+	To      string                 `json:"to,omitempty"`
+	Fields  []string               `json:"fields,omitempty"`
+	Field   string                 `json:"field,omitempty"`
+	Value   interface{}            `json:"value,omitempty"`
+	Table   map[string]interface{} `json:"table,omitempty"`
+	Default interface{}            `json:"default,omitempty"`
+	Format  string                 `json:"format,omitempty"`
+}
+
+// PipelineVersion is one immutable definition of a pipeline.
+type PipelineVersion struct {
+	Version   int             `json:"version"`
+	Steps     []TransformStep `json:"steps"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Pipeline is a subscription's transformation pipeline, applied to each
+// message before the subscription's handler runs.
+type Pipeline struct {
+	// Active is the version applied to deliveries, or 0 for none.
+	Active   int               `json:"active"`
+	Versions []PipelineVersion `json:"versions"`
+}
+
+// Version returns the given version, or nil.
+func (p *Pipeline) Version(v int) *PipelineVersion {
+	for i := range p.Versions {
+		if p.Versions[i].Version == v {
+			return &p.Versions[i]
+		}
+	}
+	return nil
+}
+
+// ActiveVersion returns the active version, or nil.
+func (p *Pipeline) ActiveVersion() *PipelineVersion {
+	if p == nil || p.Active == 0 {
+		return nil
+	}
+	return p.Version(p.Active)
+}
+
+// AddVersion appends a new version with steps, dropping the oldest inactive
+// versions beyond MaxPipelineVersions.
+func (p *Pipeline) AddVersion(steps []TransformStep, now time.Time) PipelineVersion {
+	next := 1
+	if n := len(p.Versions); n > 0 {
+		next = p.Versions[n-1].Version + 1
+	}
+	v := PipelineVersion{Version: next, Steps: steps, CreatedAt: now}
+	p.Versions = append(p.Versions, v)
+	for i := 0; len(p.Versions) > MaxPipelineVersions && i < len(p.Versions); {
+		if p.Versions[i].Version == p.Active || p.Versions[i].Version == next {
+			i++
+			continue
+		}
+		p.Versions = append(p.Versions[:i], p.Versions[i+1:]...)
+	}
+	return v
+}
+
+// Activate makes version v active; 0 disables the pipeline.
+func (p *Pipeline) Activate(v int) error {
+	if v != 0 && p.Version(v) == nil {
+		return fmt.Errorf("%w: %d", ErrPipelineVersionNotFound, v)
+	}
+	p.Active = v
+	return nil
+}
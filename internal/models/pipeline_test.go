@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPipelineAddVersion(t *testing.T) {
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	p := &Pipeline{}
+	for i := 1; i <= MaxPipelineVersions; i++ {
+		if v := p.AddVersion(nil, now); v.Version != i {
+			t.Fatalf("AddVersion = version %d, want %d", v.Version, i)
+		}
+	}
+	if err := p.Activate(1); err != nil {
+		t.Fatalf("Activate(1): %v", err)
+	}
+
+	// The oldest inactive version makes room; the active one is kept
+	v := p.AddVersion(nil, now)
+	if v.Version != MaxPipelineVersions+1 {
+		t.Fatalf("AddVersion = version %d, want %d", v.Version, MaxPipelineVersions+1)
+	}
+	if len(p.Versions) != MaxPipelineVersions {
+		t.Fatalf("kept %d versions, want %d", len(p.Versions), MaxPipelineVersions)
+	}
+	if p.Version(1) == nil {
+		t.Error("active version 1 was dropped")
+	}
+	if p.Version(2) != nil {
+		t.Error("oldest inactive version 2 was kept")
+	}
+	if got := p.ActiveVersion(); got == nil || got.Version != 1 {
+		t.Errorf("ActiveVersion = %+v, want version 1", got)
+	}
+
+	// Numbers are not reused after a version is dropped
+	if v := p.AddVersion(nil, now); v.Version != MaxPipelineVersions+2 {
+		t.Errorf("AddVersion = version %d, want %d", v.Version, MaxPipelineVersions+2)
+	}
+}
+
+func TestPipelineActivate(t *testing.T) {
+	p := &Pipeline{Versions: []PipelineVersion{{Version: 1}, {Version: 3}}}
+	tests := []struct {
+		version int
+		wantErr bool
+		want    int
+	}{
+		{version: 3, want: 3},
+		{version: 2, wantErr: true, want: 3},
+		{version: 0, want: 0},
+		{version: 1, want: 1},
+	}
+	for _, tt := range tests {
+		err := p.Activate(tt.version)
+		if tt.wantErr != errors.Is(err, ErrPipelineVersionNotFound) {
+			t.Errorf("Activate(%d) error = %v, want error %v", tt.version, err, tt.wantErr)
+		}
+		if p.Active != tt.want {
+			t.Errorf("after Activate(%d), Active = %d, want %d", tt.version, p.Active, tt.want)
+		}
+		if active := p.ActiveVersion(); (active == nil) != (tt.want == 0) {
+			t.Errorf("after Activate(%d), ActiveVersion = %+v", tt.version, active)
+		}
+	}
+	if (*Pipeline)(nil).ActiveVersion() != nil {
+		t.Error("ActiveVersion of a nil pipeline is not nil")
+	}
+}
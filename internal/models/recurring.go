@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Misfire policies decide what happens to occurrences missed while no
+// server was running.
+const (
+	// MisfireSkip records missed occurrences as skipped.
+	MisfireSkip = "skip"
+	// MisfireCatchUp publishes every missed occurrence.
+	MisfireCatchUp = "catch_up"
+)
+
+// Recurring run statuses.
+const (
+	RunPublished = "published"
+	RunSkipped   = "skipped"
+	RunFailed    = "failed"
+)
+
+// RecurringSchedule publishes a message built from Template at every
+// activation of a cron expression, evaluated in Timezone.
+type RecurringSchedule struct {
+	ID       string          `json:"id"`
+	TenantID string          `json:"tenant_id"`
+	Name     string          `json:"name"`
+	Cron     string          `json:"cron"`
+	Timezone string          `json:"timezone"`
+	Template MessageTemplate `json:"template"`
+	// StartAt and EndAt bound the occurrences; either may be unset.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	EndAt   *time.Time `json:"end_at,omitempty"`
+	// MaxOccurrences stops the schedule after that many published
+	// occurrences; 0 means no limit.
+	MaxOccurrences int    `json:"max_occurrences"`
+	Occurrences    int    `json:"occurrences"`
+	MisfirePolicy  string `json:"misfire_policy"`
+	Enabled        bool   `json:"enabled"`
+	// NextRunAt is nil once the schedule has no further occurrences.
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// MessageTemplate is the message a recurring schedule publishes. Text and
+// JSON payloads may use text/template actions, see RecurringScheduler.
+type MessageTemplate struct {
+	Payload         []byte `json:"payload"`
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	CorrelationID   string `json:"correlation_id,omitempty"`
+	CausationID     string `json:"causation_id,omitempty"`
+	RoutingKey      string `json:"routing_key,omitempty"`
+	OrderingKey     string `json:"ordering_key,omitempty"`
+	Priority        uint8  `json:"priority,omitempty"`
+	// TTL, a duration, expires each occurrence's message that long after
+	// the time it was scheduled for.
+	TTL        string     `json:"ttl,omitempty"`
+	Headers    Headers    `json:"headers,omitempty"`
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// Value implements driver.Valuer.
+func (t MessageTemplate) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner.
+func (t *MessageTemplate) Scan(src interface{}) error {
+	return scanJSON(src, t)
+}
+
+// RecurringRun records what happened to one occurrence of a schedule.
+type RecurringRun struct {
+	ID           string    `json:"id"`
+	ScheduleID   string    `json:"schedule_id"`
+	TenantID     string    `json:"tenant_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Status       string    `json:"status"`
+	MessageID    string    `json:"message_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	RanAt        time.Time `json:"ran_at"`
+}
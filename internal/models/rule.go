@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RoutingRule sends the messages matching Condition to Subscription, in
+// addition to the subscriptions whose patterns match their routing key.
+type RoutingRule struct {
+	Name string `json:"name"`
+	// Priority orders evaluation, highest first. Rules of equal priority
+	// are evaluated in the order they are listed.
+	Priority int `json:"priority"`
+	// Condition is an expression over the message's attributes, content,
+	// headers and metadata, e.g. attributes.region == 'EU'.
+	Condition    string `json:"condition"`
+	Subscription string `json:"subscription"`
+	// Stop ends evaluation when the rule matches.
+	Stop bool `json:"stop,omitempty"`
+}
+
+// Validate checks the rule's fields, but not its condition.
+func (r *RoutingRule) Validate() error {
+	if !subscriptionNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("rule name %q must be 1-64 lowercase letters, digits, '-' or '_'", r.Name)
+	}
+	if r.Condition == "" {
+		return fmt.Errorf("rule %q needs a condition", r.Name)
+	}
+	if r.Subscription == "" {
+		return fmt.Errorf("rule %q needs a subscription", r.Name)
+	}
+	return nil
+}
+
+// SortRules returns a copy of rules in evaluation order.
+func SortRules(rules []RoutingRule) []RoutingRule {
+	sorted := append([]RoutingRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return sorted
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Scheduled message statuses.
+const (
+	SchedulePending     = "pending"
+	ScheduleDispatching = "dispatching"
+	ScheduleDelivered   = "delivered"
+	ScheduleCancelled   = "cancelled"
+	ScheduleFailed      = "failed"
+)
+
+// ScheduledMessage tracks the delayed delivery of a stored message.
+type ScheduledMessage struct {
+	MessageID   string     `json:"message_id"`
+	TenantID    string     `json:"tenant_id"`
+	DeliverAt   time.Time  `json:"deliver_at"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
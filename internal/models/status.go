@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a message is missing or cannot move
+// from its current status to the requested one.
+var ErrInvalidTransition = errors.New("invalid message status transition")
+
+// Message statuses. A message is accepted when stored, queued when sent to
+// the broker and processing while a worker handles it. It ends delivered,
+// dead-lettered or expired, or failed if it could not be queued.
+const (
+	MessageAccepted     = "accepted"
+	MessageQueued       = "queued"
+	MessageProcessing   = "processing"
+	MessageDelivered    = "delivered"
+	MessageFailed       = "failed"
+	MessageDeadLettered = "dead_lettered"
+	MessageExpired      = "expired"
+)
+
+// statusTransitions lists the statuses each status may move to. Queued and
+// processing may repeat: a message is re-queued by a retried enqueue or
+// after a failed attempt, and redelivered after a worker crash.
+var statusTransitions = map[string][]string{
+	MessageAccepted:   {MessageQueued, MessageFailed, MessageExpired},
+	MessageQueued:     {MessageQueued, MessageProcessing, MessageFailed, MessageDeadLettered, MessageExpired},
+	MessageProcessing: {MessageProcessing, MessageQueued, MessageDelivered, MessageDeadLettered, MessageExpired},
+	MessageFailed:     {MessageQueued},
+}
+
+// IsMessageStatus reports whether s is a known message status.
+func IsMessageStatus(s string) bool {
+	switch s {
+	case MessageAccepted, MessageQueued, MessageProcessing, MessageDelivered,
+		MessageFailed, MessageDeadLettered, MessageExpired:
+		return true
+	}
+	return false
+}
+
+// StatusSources returns the statuses a message may move to status from.
+func StatusSources(status string) []string {
+	var sources []string
+	for from, targets := range statusTransitions {
+		for _, to := range targets {
+			if to == status {
+				sources = append(sources, from)
+			}
+		}
+	}
+	return sources
+}
+
+// StatusTimestamps records when a message last entered each status, stored
+// as JSONB.
+type StatusTimestamps map[string]time.Time
+
+// Value implements driver.Valuer.
+func (t StatusTimestamps) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner.
+func (t *StatusTimestamps) Scan(src interface{}) error {
+	return scanJSON(src, t)
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrSubscriptionExists   = errors.New("subscription already exists")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionInUse is returned when removing a subscription that
+	// routing rules send messages to.
+	ErrSubscriptionInUse = errors.New("subscription is used by routing rules")
+)
+
+// DefaultSubscriptionHandler is the handler of subscriptions that do not
+// name one.
+const DefaultSubscriptionHandler = "log"
+
+var subscriptionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Subscription receives the messages published to its tenant whose routing
+// key matches one of Patterns, through its own queue, handler and workers.
+type Subscription struct {
+	Name string `json:"name"`
+	// Patterns are topic binding keys: dot-separated words, where "*"
+	// matches exactly one word and "#" zero or more.
+	Patterns []string `json:"patterns"`
+	// Handler names a handler registered with the consumer's
+	// TenantManager (default DefaultSubscriptionHandler).
+	Handler     string `json:"handler,omitempty"`
+	WorkerCount int32  `json:"worker_count,omitempty"`
+}
+
+// Validate checks the subscription and fills in defaults.
+func (s *Subscription) Validate() error {
+	if !subscriptionNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("subscription name %q must be 1-64 lowercase letters, digits, '-' or '_'", s.Name)
+	}
+	if len(s.Patterns) == 0 {
+		return errors.New("subscription needs at least one pattern")
+	}
+	for _, p := range s.Patterns {
+		if err := validateTopicPattern(p); err != nil {
+			return err
+		}
+	}
+	if s.Handler == "" {
+		s.Handler = DefaultSubscriptionHandler
+	}
+	if s.WorkerCount == 0 {
+		s.WorkerCount = 1
+	}
+	if s.WorkerCount < 1 || s.WorkerCount > 10 {
+		return errors.New("worker count must be between 1 and 10")
+	}
+	return nil
+}
+
+func validateTopicPattern(p string) error {
+	if p == "" || len(p) > 255 {
+		return fmt.Errorf("pattern %q must be 1-255 bytes", p)
+	}
+	for _, word := range strings.Split(p, ".") {
+		if word == "" {
+			return fmt.Errorf("pattern %q has an empty word", p)
+		}
+		if strings.ContainsAny(word, "*#") && word != "*" && word != "#" {
+			return fmt.Errorf("pattern %q: wildcards must be whole words", p)
+		}
+	}
+	return nil
+}
+
+// FindSubscription returns the index of the named subscription in
+// c.Subscriptions, or -1.
+func (c TenantConfig) FindSubscription(name string) int {
+	for i, s := range c.Subscriptions {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
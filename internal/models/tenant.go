@@ -1,10 +1,374 @@
 package models
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
 type Tenant struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Config      string `json:"config"`
-	Description string `json:"description"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Config      TenantConfig `json:"config"`
+	Description string       `json:"description"`
+	CreatedAt   string       `json:"created_at"`
+	UpdatedAt   string       `json:"updated_at"`
+}
+
+// DefaultMaxPriority is the number of priority levels of a tenant queue
+// whose config does not set MaxPriority.
+const DefaultMaxPriority = 10
+
+// TenantConfig is the per-tenant configuration stored in tenants.config.
+type TenantConfig struct {
+	WorkerCount int32 `json:"worker_count,omitempty"`
+	// Paused stops the tenant's messages from being consumed; they keep
+	// accumulating in its queues until it is resumed.
+	Paused bool `json:"paused,omitempty"`
+	// MaxPriority is the highest message priority the tenant's queue
+	// distinguishes (1-255, default DefaultMaxPriority). Messages published
+	// with a higher priority are treated as MaxPriority.
+	MaxPriority int              `json:"max_priority,omitempty"`
+	Retention   *RetentionPolicy `json:"retention,omitempty"`
+	Inbox       *InboxPolicy     `json:"inbox,omitempty"`
+	// OrderedProcessing dispatches each message to a worker chosen by its
+	// ordering key, so messages with the same key are handled one at a
+	// time in queue order.
+	OrderedProcessing bool `json:"ordered_processing,omitempty"`
+	// Batch hands messages to handlers in batches instead of one at a
+	// time. It cannot be combined with OrderedProcessing.
+	Batch *BatchPolicy `json:"batch,omitempty"`
+	// QoS tunes how the tenant's consumers take deliveries.
+	QoS *QoSPolicy `json:"qos,omitempty"`
+	// Autoscale adjusts WorkerCount to the tenant's load.
+	Autoscale *AutoscalePolicy `json:"autoscale,omitempty"`
+	// Scheduling sets the tenant's share of the shared worker pool.
+	Scheduling *SchedulingPolicy `json:"scheduling,omitempty"`
+	// Subscriptions consume the messages published to the tenant by
+	// routing key, alongside the tenant's own queue.
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+	// Rules route messages to subscriptions by their content.
+	Rules []RoutingRule `json:"rules,omitempty"`
+	// Pipelines are the transformation pipelines of subscriptions, by
+	// subscription name.
+	Pipelines map[string]*Pipeline `json:"pipelines,omitempty"`
+}
+
+// QueueMaxPriority returns the x-max-priority the tenant's queue is
+// declared with.
+func (c TenantConfig) QueueMaxPriority() int {
+	if c.MaxPriority <= 0 {
+		return DefaultMaxPriority
+	}
+	return c.MaxPriority
+}
+
+// ValidateMaxPriority checks a max priority setting; 0 selects the default.
+func ValidateMaxPriority(n int) error {
+	if n < 0 || n > 255 {
+		return fmt.Errorf("max priority must be between 1 and 255")
+	}
+	return nil
+}
+
+// InboxPolicy enables consumer-side deduplication for a tenant. Processed
+// message IDs are remembered for WindowHours (default 168), and a
+// redelivery within that window is acked without being handled again.
+type InboxPolicy struct {
+	Enabled     bool `json:"enabled"`
+	WindowHours int  `json:"window_hours,omitempty"`
+}
+
+// Validate checks the policy for unsupported values.
+func (p *InboxPolicy) Validate() error {
+	if p.WindowHours < 0 {
+		return fmt.Errorf("inbox window must not be negative")
+	}
+	return nil
+}
+
+// Batch handling limits.
+const (
+	DefaultBatchSize   = 100
+	MaxBatchSize       = 1000
+	DefaultBatchWaitMs = 1000
+	MaxBatchWaitMs     = 60000
+	MaxBatchPrefetch   = 65535
+)
+
+// ErrConflictingModes is returned for a config enabling consumer modes that
+// cannot be combined.
+var ErrConflictingModes = errors.New("conflicting consumer modes")
+
+// BatchPolicy enables batch handling for a tenant. Each worker collects up
+// to MaxSize messages (default 100), or whatever arrived within
+// MaxWaitMillis of the first one (default 1000), and hands them to the
+// handler together. Prefetch is the number of unacknowledged messages each
+// worker may hold; it defaults to MaxSize and cannot be lower.
+type BatchPolicy struct {
+	Enabled       bool `json:"enabled"`
+	MaxSize       int  `json:"max_size,omitempty"`
+	MaxWaitMillis int  `json:"max_wait_millis,omitempty"`
+	Prefetch      int  `json:"prefetch,omitempty"`
+}
+
+// Validate checks the policy for unsupported values.
+func (p *BatchPolicy) Validate() error {
+	if p.MaxSize < 0 || p.MaxSize > MaxBatchSize {
+		return fmt.Errorf("batch size must be between 1 and %d", MaxBatchSize)
+	}
+	if p.MaxWaitMillis < 0 || p.MaxWaitMillis > MaxBatchWaitMs {
+		return fmt.Errorf("batch wait must be between 1 and %d milliseconds", MaxBatchWaitMs)
+	}
+	if p.Prefetch < 0 || p.Prefetch > MaxBatchPrefetch {
+		return fmt.Errorf("prefetch must be between 1 and %d", MaxBatchPrefetch)
+	}
+	if p.Prefetch != 0 && p.Prefetch < p.Size() {
+		return fmt.Errorf("prefetch must be at least the batch size")
+	}
+	return nil
+}
+
+// Size returns the most messages handed to the handler at once.
+func (p *BatchPolicy) Size() int {
+	if p.MaxSize == 0 {
+		return DefaultBatchSize
+	}
+	return p.MaxSize
+}
+
+// Wait returns how long a batch waits to fill after its first message.
+func (p *BatchPolicy) Wait() time.Duration {
+	if p.MaxWaitMillis == 0 {
+		return DefaultBatchWaitMs * time.Millisecond
+	}
+	return time.Duration(p.MaxWaitMillis) * time.Millisecond
+}
+
+// PrefetchCount returns the prefetch each worker consumes with.
+func (p *BatchPolicy) PrefetchCount() int {
+	if p.Prefetch == 0 {
+		return p.Size()
+	}
+	return p.Prefetch
+}
+
+// BatchEnabled reports whether the tenant's messages are handled in
+// batches.
+func (c TenantConfig) BatchEnabled() bool {
+	return c.Batch != nil && c.Batch.Enabled
+}
+
+// CheckModes returns ErrConflictingModes if c enables consumer modes that
+// cannot be combined.
+func (c TenantConfig) CheckModes() error {
+	if c.BatchEnabled() && c.OrderedProcessing {
+		return fmt.Errorf("%w: batch handling cannot be combined with ordered processing", ErrConflictingModes)
+	}
+	if c.BatchEnabled() && c.QoS != nil && c.QoS.Exclusive {
+		return fmt.Errorf("%w: batch handling cannot use an exclusive consumer", ErrConflictingModes)
+	}
+	return nil
+}
+
+// MaxProcessingTimeoutMs bounds QoSPolicy.ProcessingTimeoutMillis.
+const MaxProcessingTimeoutMs = 3600000
+
+// QoSPolicy tunes how a tenant's consumers take deliveries from RabbitMQ.
+// It applies to the tenant's queue and to each of its subscriptions.
+type QoSPolicy struct {
+	// PrefetchCount is the number of unacknowledged messages each worker
+	// may hold (1-65535, default 1).
+	PrefetchCount int `json:"prefetch_count,omitempty"`
+	// ChannelPerWorker gives each worker a channel of its own instead of
+	// sharing the consumer's.
+	ChannelPerWorker bool `json:"channel_per_worker,omitempty"`
+	// ConsumerPriority is the x-priority of the tenant's consumers.
+	// RabbitMQ delivers to higher priority consumers while they have
+	// capacity.
+	ConsumerPriority int32 `json:"consumer_priority,omitempty"`
+	// Exclusive registers a single exclusive consumer per queue, shared by
+	// the workers, so no other connection can consume the tenant's queues.
+	Exclusive bool `json:"exclusive,omitempty"`
+	// ProcessingTimeoutMillis is the deadline of the context each handler
+	// call runs with; 0 sets none.
+	ProcessingTimeoutMillis int `json:"processing_timeout_millis,omitempty"`
+}
+
+// Validate checks the policy for unsupported values.
+func (p *QoSPolicy) Validate() error {
+	if p.PrefetchCount < 0 || p.PrefetchCount > MaxBatchPrefetch {
+		return fmt.Errorf("prefetch must be between 1 and %d", MaxBatchPrefetch)
+	}
+	if p.ProcessingTimeoutMillis < 0 || p.ProcessingTimeoutMillis > MaxProcessingTimeoutMs {
+		return fmt.Errorf("processing timeout must be between 1 and %d milliseconds", MaxProcessingTimeoutMs)
+	}
+	if p.Exclusive && p.ChannelPerWorker {
+		return fmt.Errorf("an exclusive consumer cannot use a channel per worker")
+	}
+	return nil
+}
+
+// Prefetch returns the prefetch each worker consumes with.
+func (p *QoSPolicy) Prefetch() int {
+	if p == nil || p.PrefetchCount == 0 {
+		return 1
+	}
+	return p.PrefetchCount
+}
+
+// ProcessingTimeout returns the deadline of a handler call, or 0 for none.
+func (p *QoSPolicy) ProcessingTimeout() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return time.Duration(p.ProcessingTimeoutMillis) * time.Millisecond
+}
+
+// MaxWorkerCount bounds the workers of a tenant's queue.
+const MaxWorkerCount = 10
+
+// AutoscalePolicy lets the autoscaler adjust the workers of a tenant's queue
+// between MinWorkers (default 1) and MaxWorkers (default MaxWorkerCount).
+// It aims for TargetBacklog queued messages per worker (default 100) and
+// busy but not saturated workers, and with TargetLatencyMillis set it adds
+// a worker while handling takes longer than that and the workers are busy.
+// After a change, it waits ScaleUpCooldownSeconds (default 60) before
+// adding workers and ScaleDownCooldownSeconds (default 300) before removing
+// one.
+type AutoscalePolicy struct {
+	Enabled                  bool  `json:"enabled"`
+	MinWorkers               int32 `json:"min_workers,omitempty"`
+	MaxWorkers               int32 `json:"max_workers,omitempty"`
+	TargetBacklog            int   `json:"target_backlog,omitempty"`
+	TargetLatencyMillis      int   `json:"target_latency_millis,omitempty"`
+	ScaleUpCooldownSeconds   int   `json:"scale_up_cooldown_seconds,omitempty"`
+	ScaleDownCooldownSeconds int   `json:"scale_down_cooldown_seconds,omitempty"`
+}
+
+// Validate checks the policy for unsupported values.
+func (p *AutoscalePolicy) Validate() error {
+	if p.MinWorkers < 0 || p.MinWorkers > MaxWorkerCount || p.MaxWorkers < 0 || p.MaxWorkers > MaxWorkerCount {
+		return fmt.Errorf("worker bounds must be between 1 and %d", MaxWorkerCount)
+	}
+	if min, max := p.Bounds(); min > max {
+		return fmt.Errorf("min workers must not exceed max workers")
+	}
+	if p.TargetBacklog < 0 || p.TargetLatencyMillis < 0 || p.ScaleUpCooldownSeconds < 0 || p.ScaleDownCooldownSeconds < 0 {
+		return fmt.Errorf("autoscale targets and cooldowns must not be negative")
+	}
+	return nil
+}
+
+// Bounds returns the fewest and most workers the autoscaler may run.
+func (p *AutoscalePolicy) Bounds() (int32, int32) {
+	min, max := p.MinWorkers, p.MaxWorkers
+	if min == 0 {
+		min = 1
+	}
+	if max == 0 {
+		max = MaxWorkerCount
+	}
+	return min, max
+}
+
+// Backlog returns the queued messages per worker the autoscaler aims for.
+func (p *AutoscalePolicy) Backlog() int {
+	if p.TargetBacklog == 0 {
+		return 100
+	}
+	return p.TargetBacklog
+}
+
+// Cooldowns returns how long after a change the autoscaler waits before
+// adding and before removing workers.
+func (p *AutoscalePolicy) Cooldowns() (up, down time.Duration) {
+	up, down = 60*time.Second, 300*time.Second
+	if p.ScaleUpCooldownSeconds > 0 {
+		up = time.Duration(p.ScaleUpCooldownSeconds) * time.Second
+	}
+	if p.ScaleDownCooldownSeconds > 0 {
+		down = time.Duration(p.ScaleDownCooldownSeconds) * time.Second
+	}
+	return up, down
+}
+
+// MaxSchedulingWeight bounds SchedulingPolicy.Weight.
+const MaxSchedulingWeight = 1000
+
+// SchedulingPolicy is a tenant's share of the shared worker pool. Tenants
+// with messages waiting are served in proportion to their Weight (default
+// 1), and a tenant running fewer than MinWorkers messages has the first
+// claim on each worker that becomes free.
+type SchedulingPolicy struct {
+	Weight     int `json:"weight,omitempty"`
+	MinWorkers int `json:"min_workers,omitempty"`
+}
+
+// Validate checks the policy for unsupported values.
+func (p *SchedulingPolicy) Validate() error {
+	if p.Weight < 0 || p.Weight > MaxSchedulingWeight {
+		return fmt.Errorf("weight must be between 1 and %d", MaxSchedulingWeight)
+	}
+	if p.MinWorkers < 0 {
+		return fmt.Errorf("min workers must not be negative")
+	}
+	return nil
+}
+
+// Share returns the policy's weight and minimum workers.
+func (p *SchedulingPolicy) Share() (weight, minWorkers int) {
+	if p == nil {
+		return 1, 0
+	}
+	weight = p.Weight
+	if weight == 0 {
+		weight = 1
+	}
+	return weight, p.MinWorkers
+}
+
+// RetentionPolicy limits how long, and how many, messages a tenant keeps.
+// Zero values disable the corresponding limit.
+type RetentionPolicy struct {
+	MaxAgeDays int   `json:"max_age_days,omitempty"`
+	MaxCount   int64 `json:"max_count,omitempty"`
+	// Action is "delete" (default) or "archive", which moves expired
+	// messages into the cold archive instead of discarding them.
+	Action string `json:"action,omitempty"`
+}
+
+// Validate checks the policy for unsupported values.
+func (p *RetentionPolicy) Validate() error {
+	if p.MaxAgeDays < 0 || p.MaxCount < 0 {
+		return fmt.Errorf("retention limits must not be negative")
+	}
+	switch p.Action {
+	case "", "delete", "archive":
+	default:
+		return fmt.Errorf("invalid retention action %q", p.Action)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer so TenantConfig can be written to JSONB.
+func (c TenantConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner so TenantConfig can be read from JSONB.
+func (c *TenantConfig) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = TenantConfig{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("cannot scan %T into TenantConfig", src)
+	}
 }
@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/lib/pq"
+)
+
+// ArchiveRepository maintains the Postgres index of archived messages and
+// moves rows between the messages table and that index.
+type ArchiveRepository struct {
+	db *sql.DB
+}
+
+func NewArchiveRepository(db *sql.DB) *ArchiveRepository {
+	return &ArchiveRepository{db: db}
+}
+
+// archiveLockID guards archive runs across server instances.
+const archiveLockID int64 = 7_240_031_029
+
+// TryLock takes the archive advisory lock on a dedicated connection, so that
+// only one server instance archives at a time. It returns false if another
+// instance holds the lock; otherwise the returned function releases it.
+func (r *ArchiveRepository) TryLock(ctx context.Context) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", archiveLockID).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire archive lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", archiveLockID); err != nil {
+			log.Printf("Failed to release archive lock: %v", err)
+		}
+		conn.Close()
+	}, true, nil
+}
+
+// ArchivableMessages returns up to limit of the tenant's oldest messages
+// ordered before the key before.
+func (r *ArchiveRepository) ArchivableMessages(ctx context.Context, tenantID string, before MessageKey, limit int) ([]models.Message, error) {
+	query := `
+        SELECT ` + messageColumns + `
+        FROM messages
+        WHERE tenant_id = $1 AND (created_at, id) < ($2, $3)
+        ORDER BY created_at, id
+        LIMIT $4
+    `
+	rows, err := r.db.QueryContext(ctx, query, tenantID, before.CreatedAt, before.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archivable messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		var msg models.Message
+		if err := scanMessage(rows, &msg); err != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// CommitSegment records a segment that has already been written to object
+// storage, indexes its messages and deletes them from the messages table,
+// all in one transaction.
+func (r *ArchiveRepository) CommitSegment(ctx context.Context, seg *models.ArchiveSegment, messages []models.Message) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO archive_segments (id, tenant_id, object_key, min_created_at, max_created_at, message_count, size_bytes)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, seg.ID, seg.TenantID, seg.ObjectKey, seg.MinCreatedAt, seg.MaxCreatedAt, seg.MessageCount, seg.SizeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to insert segment: %w", err)
+	}
+
+	ids := make([]string, len(messages))
+	createdAt := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+		createdAt[i] = msg.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO archived_messages (tenant_id, created_at, message_id, segment_id)
+        SELECT $1, t.created_at, t.id, $4
+        FROM unnest($2::timestamptz[], $3::uuid[]) AS t(created_at, id)
+    `, seg.TenantID, pq.Array(createdAt), pq.Array(ids), seg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to index archived messages: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM messages
+        WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3 AND id = ANY($4::uuid[])
+    `, seg.TenantID, seg.MinCreatedAt, seg.MaxCreatedAt, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to delete archived messages: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// FindArchivedMessage locates a single archived message.
+func (r *ArchiveRepository) FindArchivedMessage(ctx context.Context, tenantID, messageID string) (*models.ArchivedMessageRef, error) {
+	query := `
+        SELECT a.message_id, a.created_at, s.id, s.object_key
+        FROM archived_messages a
+        JOIN archive_segments s ON s.id = a.segment_id
+        WHERE a.tenant_id = $1 AND a.message_id = $2
+    `
+	ref := &models.ArchivedMessageRef{}
+	err := r.db.QueryRowContext(ctx, query, tenantID, messageID).
+		Scan(&ref.MessageID, &ref.CreatedAt, &ref.SegmentID, &ref.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// SegmentsAfter returns up to limit of the tenant's segments holding
+// messages created at or after after, ordered by their oldest message.
+func (r *ArchiveRepository) SegmentsAfter(ctx context.Context, tenantID string, after time.Time, limit int) ([]models.ArchiveSegment, error) {
+	query := `
+        SELECT id, tenant_id, object_key, min_created_at, max_created_at, message_count, size_bytes
+        FROM archive_segments
+        WHERE tenant_id = $1 AND max_created_at >= $2
+        ORDER BY min_created_at, id
+        LIMIT $3
+    `
+	return r.querySegments(ctx, query, tenantID, after, limit)
+}
+
+// SegmentMessagesAfter returns the IDs of up to limit messages still indexed
+// in the segment and positioned after (after, afterID), in (created_at, id)
+// order.
+func (r *ArchiveRepository) SegmentMessagesAfter(ctx context.Context, tenantID, segmentID string, after time.Time, afterID string, limit int) ([]string, error) {
+	query := `
+        SELECT message_id
+        FROM archived_messages
+        WHERE tenant_id = $1 AND segment_id = $2 AND (created_at, message_id) > ($3, $4)
+        ORDER BY created_at, message_id
+        LIMIT $5
+    `
+	rows, err := r.db.QueryContext(ctx, query, tenantID, segmentID, after, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archive index: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan archive index row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ArchivedInRange returns up to limit of the archived messages created in
+// [from, to), oldest first.
+func (r *ArchiveRepository) ArchivedInRange(ctx context.Context, tenantID string, from, to time.Time, limit int) ([]models.ArchivedMessageRef, error) {
+	query := `
+        SELECT a.message_id, a.created_at, s.id, s.object_key
+        FROM archived_messages a
+        JOIN archive_segments s ON s.id = a.segment_id
+        WHERE a.tenant_id = $1 AND a.created_at >= $2 AND a.created_at < $3
+        ORDER BY a.created_at, a.message_id
+        LIMIT $4
+    `
+	return r.queryRefs(ctx, query, tenantID, from, to, limit)
+}
+
+func (r *ArchiveRepository) queryRefs(ctx context.Context, query string, args ...interface{}) ([]models.ArchivedMessageRef, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archive index: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []models.ArchivedMessageRef
+	for rows.Next() {
+		var ref models.ArchivedMessageRef
+		if err := rows.Scan(&ref.MessageID, &ref.CreatedAt, &ref.SegmentID, &ref.ObjectKey); err != nil {
+			return nil, fmt.Errorf("failed to scan archive index row: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// RestoreMessages copies archived messages back into the messages table and
+// drops them from the index. It returns the object keys of segments left
+// with no indexed messages, which the caller should delete from storage.
+func (r *ArchiveRepository) RestoreMessages(ctx context.Context, tenantID string, messages []models.Message) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	values := make([]string, strings.Count(messageColumns, ",")+1)
+	for i := range values {
+		values[i] = fmt.Sprintf("$%d", i+1)
+	}
+	insert := "INSERT INTO messages (" + messageColumns + ") VALUES (" + strings.Join(values, ", ") + ") ON CONFLICT DO NOTHING"
+
+	ids := make([]string, len(messages))
+	for i := range messages {
+		msg := &messages[i]
+		ids[i] = msg.ID
+		if msg.Status == "" {
+			// Segments written before statuses were tracked
+			msg.Status = models.MessageAccepted
+		}
+		_, err := tx.ExecContext(ctx, insert, messageValues(msg)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore message %s: %w", msg.ID, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM archived_messages WHERE tenant_id = $1 AND message_id = ANY($2::uuid[])",
+		tenantID, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unindex restored messages: %w", err)
+	}
+
+	emptied, err := deleteEmptySegments(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return emptied, tx.Commit()
+}
+
+// deleteEmptySegments removes segment rows that no longer index any message
+// and returns their object keys.
+func deleteEmptySegments(ctx context.Context, tx *sql.Tx, tenantID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+        DELETE FROM archive_segments s
+        WHERE s.tenant_id = $1
+        AND NOT EXISTS (SELECT 1 FROM archived_messages a WHERE a.segment_id = s.id)
+        RETURNING s.object_key
+    `, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete empty segments: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TenantSegments returns all of the tenant's segments, oldest first.
+func (r *ArchiveRepository) TenantSegments(ctx context.Context, tenantID string) ([]models.ArchiveSegment, error) {
+	query := `
+        SELECT id, tenant_id, object_key, min_created_at, max_created_at, message_count, size_bytes
+        FROM archive_segments
+        WHERE tenant_id = $1
+        ORDER BY min_created_at, id
+    `
+	return r.querySegments(ctx, query, tenantID)
+}
+
+func (r *ArchiveRepository) querySegments(ctx context.Context, query string, args ...interface{}) ([]models.ArchiveSegment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query segments: %w", err)
+	}
+	defer rows.Close()
+
+	var segments []models.ArchiveSegment
+	for rows.Next() {
+		var seg models.ArchiveSegment
+		if err := rows.Scan(&seg.ID, &seg.TenantID, &seg.ObjectKey, &seg.MinCreatedAt, &seg.MaxCreatedAt, &seg.MessageCount, &seg.SizeBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan segment row: %w", err)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, rows.Err()
+}
+
+// DeleteSegment removes a segment and its index entries.
+func (r *ArchiveRepository) DeleteSegment(ctx context.Context, segmentID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM archive_segments WHERE id = $1", segmentID)
+	return err
+}
+
+// ReplaceSegmentObject points a segment at a rewritten object that no
+// longer contains removedIDs, updating its stats and index.
+func (r *ArchiveRepository) ReplaceSegmentObject(ctx context.Context, seg *models.ArchiveSegment, removedIDs []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE archive_segments
+        SET object_key = $2, message_count = $3, size_bytes = $4
+        WHERE id = $1
+    `, seg.ID, seg.ObjectKey, seg.MessageCount, seg.SizeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update segment: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM archived_messages WHERE segment_id = $1 AND message_id = ANY($2::uuid[])",
+		seg.ID, pq.Array(removedIDs))
+	if err != nil {
+		return fmt.Errorf("failed to unindex removed messages: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/lib/pq"
+)
+
+// ErasureRepository stores erasure jobs and deletes the rows they target.
+type ErasureRepository struct {
+	db *sql.DB
+}
+
+func NewErasureRepository(db *sql.DB) *ErasureRepository {
+	return &ErasureRepository{db: db}
+}
+
+const erasureJobColumns = `id, tenant_id, subject_header, subject_value, status, result,
+        certificate, error, created_at, started_at, finished_at`
+
+func scanErasureJob(row rowScanner) (*models.ErasureJob, error) {
+	job := &models.ErasureJob{}
+	var result []byte
+	var certificate []byte
+	err := row.Scan(&job.ID, &job.TenantID, &job.SubjectHeader, &job.SubjectValue, &job.Status, &result,
+		&certificate, &job.Error, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(result, &job.Result); err != nil {
+		return nil, fmt.Errorf("failed to decode erasure result: %w", err)
+	}
+	if certificate != nil {
+		job.Certificate = &models.ErasureCertificate{}
+		if err := json.Unmarshal(certificate, job.Certificate); err != nil {
+			return nil, fmt.Errorf("failed to decode erasure certificate: %w", err)
+		}
+	}
+	return job, nil
+}
+
+// CreateJob inserts a pending job.
+func (r *ErasureRepository) CreateJob(ctx context.Context, job *models.ErasureJob) error {
+	query := `
+        INSERT INTO erasure_jobs (tenant_id, subject_header, subject_value)
+        VALUES ($1, $2, $3)
+        RETURNING id, status, created_at
+    `
+	return r.db.QueryRowContext(ctx, query, job.TenantID, job.SubjectHeader, job.SubjectValue).
+		Scan(&job.ID, &job.Status, &job.CreatedAt)
+}
+
+// GetJob returns a job of the tenant.
+func (r *ErasureRepository) GetJob(ctx context.Context, tenantID, id string) (*models.ErasureJob, error) {
+	query := "SELECT " + erasureJobColumns + " FROM erasure_jobs WHERE tenant_id = $1 AND id = $2"
+	return scanErasureJob(r.db.QueryRowContext(ctx, query, tenantID, id))
+}
+
+// ClaimNextJob marks the oldest pending job as running and returns it, or
+// sql.ErrNoRows if there is none. SKIP LOCKED lets several server instances
+// claim jobs concurrently without picking the same one.
+func (r *ErasureRepository) ClaimNextJob(ctx context.Context) (*models.ErasureJob, error) {
+	query := `
+        UPDATE erasure_jobs SET status = 'running', started_at = CURRENT_TIMESTAMP
+        WHERE id = (
+            SELECT id FROM erasure_jobs
+            WHERE status = 'pending'
+            ORDER BY created_at
+            FOR UPDATE SKIP LOCKED
+            LIMIT 1
+        )
+        RETURNING ` + erasureJobColumns
+	return scanErasureJob(r.db.QueryRowContext(ctx, query))
+}
+
+// FinishJob records the final state of a job. The subject value is cleared
+// so the job record does not itself retain the erased identifier.
+func (r *ErasureRepository) FinishJob(ctx context.Context, job *models.ErasureJob) error {
+	result, err := json.Marshal(job.Result)
+	if err != nil {
+		return err
+	}
+	var certificate []byte
+	if job.Certificate != nil {
+		if certificate, err = json.Marshal(job.Certificate); err != nil {
+			return err
+		}
+	}
+	query := `
+        UPDATE erasure_jobs
+        SET status = $2, result = $3, certificate = $4, error = $5,
+            subject_value = '', finished_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING finished_at
+    `
+	return r.db.QueryRowContext(ctx, query, job.ID, job.Status, result, certificate, job.Error).
+		Scan(&job.FinishedAt)
+}
+
+// DeleteMessages removes up to limit of the tenant's rows from table
+// ("messages" or "messages_archive") and returns their IDs. If header is set
+// only rows whose header matches value are removed.
+func (r *ErasureRepository) DeleteMessages(ctx context.Context, table, tenantID, header, value string, limit int) ([]string, error) {
+	if table != "messages" && table != "messages_archive" {
+		return nil, fmt.Errorf("unsupported table %q", table)
+	}
+	query := fmt.Sprintf(`
+        DELETE FROM %[1]s
+        WHERE (tenant_id, created_at, id) IN (
+            SELECT tenant_id, created_at, id FROM %[1]s
+            WHERE tenant_id = $1 AND ($2 = '' OR headers ->> $2 = $3)
+            LIMIT $4
+        )
+        RETURNING id
+    `, table)
+	rows, err := r.db.QueryContext(ctx, query, tenantID, header, value, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase from %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan erased message id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteIdempotencyRecords removes the tenant's stored publish responses,
+// which embed the published messages. If header is set only responses for
+// messages whose header matches value are removed.
+func (r *ErasureRepository) DeleteIdempotencyRecords(ctx context.Context, tenantID, header, value string) (int64, error) {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE tenant_id = $1
+        AND ($2 = '' OR convert_from(response, 'UTF8')::jsonb -> 'headers' ->> $2 = $3)
+    `
+	res, err := r.db.ExecContext(ctx, query, tenantID, header, value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to erase idempotency records: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteRecurringSchedules removes the tenant's recurring schedules and
+// their run history, returning how many of each were removed. If header is
+// set only schedules whose message template has the header set to value
+// are removed.
+func (r *ErasureRepository) DeleteRecurringSchedules(ctx context.Context, tenantID, header, value string) (int64, int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const match = `tenant_id = $1 AND ($2 = '' OR template -> 'headers' ->> $2 = $3)`
+	// Runs would go with their schedules by cascade, but are removed first
+	// so they can be counted
+	res, err := tx.ExecContext(ctx, `
+        DELETE FROM recurring_runs
+        WHERE tenant_id = $1 AND schedule_id IN (SELECT id FROM recurring_schedules WHERE `+match+`)
+    `, tenantID, header, value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to erase recurring runs: %w", err)
+	}
+	runs, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	res, err = tx.ExecContext(ctx, "DELETE FROM recurring_schedules WHERE "+match, tenantID, header, value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to erase recurring schedules: %w", err)
+	}
+	schedules, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit erasure of recurring schedules: %w", err)
+	}
+	return schedules, runs, nil
+}
+
+// DeleteScheduledMessages removes the tenant's pending and past delivery
+// schedules. If header is set only the schedules of messages whose header
+// matches value are removed, so it must run before those messages are
+// erased.
+func (r *ErasureRepository) DeleteScheduledMessages(ctx context.Context, tenantID, header, value string) (int64, error) {
+	query := `
+        DELETE FROM scheduled_messages s
+        WHERE s.tenant_id = $1
+        AND ($2 = '' OR EXISTS (
+            SELECT 1 FROM messages m
+            WHERE m.tenant_id = s.tenant_id AND m.id = s.message_id AND m.headers ->> $2 = $3
+        ))
+    `
+	res, err := r.db.ExecContext(ctx, query, tenantID, header, value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to erase scheduled messages: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteInboxRecords removes up to limit of the tenant's inbox records.
+func (r *ErasureRepository) DeleteInboxRecords(ctx context.Context, tenantID string, limit int) (int64, error) {
+	query := `
+        DELETE FROM inbox_messages
+        WHERE (tenant_id, message_id) IN (
+            SELECT tenant_id, message_id FROM inbox_messages WHERE tenant_id = $1 LIMIT $2
+        )
+    `
+	res, err := r.db.ExecContext(ctx, query, tenantID, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to erase inbox records: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteMessageEvents removes all of the tenant's message timelines.
+func (r *ErasureRepository) DeleteMessageEvents(ctx context.Context, tenantID string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM message_events WHERE tenant_id = $1", tenantID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to erase message events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteEventsOfMessages removes the timelines of the given messages of the
+// tenant.
+func (r *ErasureRepository) DeleteEventsOfMessages(ctx context.Context, tenantID string, ids []string) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		"DELETE FROM message_events WHERE tenant_id = $1 AND message_id = ANY($2::uuid[])",
+		tenantID, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to erase message events: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// EventRepository stores message lifecycle events.
+type EventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// Append inserts events in one statement.
+func (r *EventRepository) Append(ctx context.Context, events []models.MessageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	const columns = 8
+	rows := make([]string, len(events))
+	args := make([]interface{}, 0, len(events)*columns)
+	for i, e := range events {
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%d", i*columns+j+1)
+		}
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args, e.TenantID, e.MessageID, e.Type, e.Attempt, e.ConsumerTag, e.NodeID, e.Details, e.OccurredAt)
+	}
+	query := `
+        INSERT INTO message_events (tenant_id, message_id, type, attempt, consumer_tag, node_id, details, occurred_at)
+        VALUES ` + strings.Join(rows, ", ")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to record message events: %w", err)
+	}
+	return nil
+}
+
+// ListForMessage returns a message's events in the order they occurred.
+func (r *EventRepository) ListForMessage(ctx context.Context, tenantID, messageID string) ([]models.MessageEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, tenant_id, message_id, type, attempt, consumer_tag, node_id, details, occurred_at
+        FROM message_events
+        WHERE tenant_id = $1 AND message_id = $2
+        ORDER BY occurred_at, id
+    `, tenantID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.MessageEvent
+	for rows.Next() {
+		var e models.MessageEvent
+		err := rows.Scan(&e.ID, &e.TenantID, &e.MessageID, &e.Type, &e.Attempt, &e.ConsumerTag, &e.NodeID,
+			&e.Details, &e.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
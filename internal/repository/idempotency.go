@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// IdempotencyRepository stores the idempotency keys of publish requests.
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve claims the key for a new request. It returns false without
+// claiming if the key is held by an unexpired record, unless that record is
+// a reservation older than staleBefore, left by a request that never
+// finished.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, rec *models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	query := `
+        INSERT INTO idempotency_keys (tenant_id, idempotency_key, request_hash, expires_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (tenant_id, idempotency_key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash, message_id = '', status_code = 0, response = NULL,
+            created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+            OR (idempotency_keys.status_code = 0 AND idempotency_keys.created_at < $5)
+        RETURNING created_at
+    `
+	err := r.db.QueryRowContext(ctx, query, rec.TenantID, rec.Key, rec.RequestHash, rec.ExpiresAt, staleBefore).
+		Scan(&rec.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	return true, nil
+}
+
+// Get returns the tenant's record for key.
+func (r *IdempotencyRepository) Get(ctx context.Context, tenantID, key string) (*models.IdempotencyRecord, error) {
+	query := `
+        SELECT tenant_id, idempotency_key, request_hash, message_id, status_code, response, created_at, expires_at
+        FROM idempotency_keys
+        WHERE tenant_id = $1 AND idempotency_key = $2
+    `
+	rec := &models.IdempotencyRecord{}
+	err := r.db.QueryRowContext(ctx, query, tenantID, key).Scan(&rec.TenantID, &rec.Key, &rec.RequestHash,
+		&rec.MessageID, &rec.StatusCode, &rec.Response, &rec.CreatedAt, &rec.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Complete stores the response of a reserved key's request.
+func (r *IdempotencyRepository) Complete(ctx context.Context, rec *models.IdempotencyRecord) error {
+	query := `
+        UPDATE idempotency_keys SET message_id = $3, status_code = $4, response = $5
+        WHERE tenant_id = $1 AND idempotency_key = $2
+    `
+	_, err := r.db.ExecContext(ctx, query, rec.TenantID, rec.Key, rec.MessageID, rec.StatusCode, rec.Response)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release drops a reservation whose request failed, so it can be retried.
+func (r *IdempotencyRepository) Release(ctx context.Context, tenantID, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE tenant_id = $1 AND idempotency_key = $2 AND status_code = 0"
+	if _, err := r.db.ExecContext(ctx, query, tenantID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes up to limit expired records.
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, limit int) (int64, error) {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE (tenant_id, idempotency_key) IN (
+            SELECT tenant_id, idempotency_key FROM idempotency_keys
+            WHERE expires_at <= CURRENT_TIMESTAMP
+            LIMIT $1
+        )
+    `
+	res, err := r.db.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// InboxRepository records the messages each tenant's consumers processed.
+type InboxRepository struct {
+	db *sql.DB
+}
+
+func NewInboxRepository(db *sql.DB) *InboxRepository {
+	return &InboxRepository{db: db}
+}
+
+// Begin starts a transaction that records messageID as processed by the
+// tenant. It returns a nil transaction and false if the message was already
+// recorded. A concurrent Begin for the same message blocks until the first
+// transaction ends, so only one of them proceeds.
+func (r *InboxRepository) Begin(ctx context.Context, tenantID, messageID string) (*sql.Tx, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin inbox transaction: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `
+        INSERT INTO inbox_messages (tenant_id, message_id) VALUES ($1, $2)
+        ON CONFLICT (tenant_id, message_id) DO NOTHING
+    `, tenantID, messageID)
+	if err != nil {
+		tx.Rollback()
+		return nil, false, fmt.Errorf("failed to record inbox message: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		return nil, false, err
+	}
+	return tx, true, nil
+}
+
+// BeginBatch starts a transaction that records messageIDs as processed by
+// the tenant with a single insert, and returns the ones that were not
+// recorded before. Like Begin, it blocks on messages recorded by concurrent
+// transactions until they end. The IDs are inserted in sorted order, so
+// concurrent batches cannot deadlock on each other.
+func (r *InboxRepository) BeginBatch(ctx context.Context, tenantID string, messageIDs []string) (*sql.Tx, map[string]bool, error) {
+	ids := slices.Clone(messageIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin inbox transaction: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, `
+        INSERT INTO inbox_messages (tenant_id, message_id)
+        SELECT $1, id FROM unnest($2::text[]) WITH ORDINALITY AS t(id, n) ORDER BY n
+        ON CONFLICT (tenant_id, message_id) DO NOTHING
+        RETURNING message_id
+    `, tenantID, pq.Array(ids))
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to record inbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	recorded := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to scan inbox message: %w", err)
+		}
+		recorded[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to record inbox messages: %w", err)
+	}
+	return tx, recorded, nil
+}
+
+// Release removes the tenant's records of messageIDs made in tx.
+func (r *InboxRepository) Release(ctx context.Context, tx *sql.Tx, tenantID string, messageIDs []string) error {
+	_, err := tx.ExecContext(ctx, `
+        DELETE FROM inbox_messages WHERE tenant_id = $1 AND message_id = ANY($2)
+    `, tenantID, pq.Array(messageIDs))
+	if err != nil {
+		return fmt.Errorf("failed to release inbox messages: %w", err)
+	}
+	return nil
+}
+
+// DeleteProcessedBefore removes up to limit of the tenant's records older
+// than cutoff.
+func (r *InboxRepository) DeleteProcessedBefore(ctx context.Context, tenantID string, cutoff time.Time, limit int) (int64, error) {
+	query := `
+        DELETE FROM inbox_messages
+        WHERE (tenant_id, message_id) IN (
+            SELECT tenant_id, message_id FROM inbox_messages
+            WHERE tenant_id = $1 AND processed_at < $2
+            LIMIT $3
+        )
+    `
+	res, err := r.db.ExecContext(ctx, query, tenantID, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune inbox: %w", err)
+	}
+	return res.RowsAffected()
+}
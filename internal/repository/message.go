@@ -3,11 +3,45 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/lib/pq"
 )
 
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const nilUUID = "00000000-0000-0000-0000-000000000000"
+
+// messageColumns is the column list read by scanMessage, shared by every
+// query that loads full messages.
+const messageColumns = `id, tenant_id, payload, content_type, content_encoding, correlation_id,
+        causation_id, routing_key, ordering_key, priority, idempotency_key, headers, attributes, expires_at,
+        status, status_timestamps, delivery_attempts, created_at, updated_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row rowScanner, msg *models.Message) error {
+	return row.Scan(&msg.ID, &msg.TenantID, &msg.Payload, &msg.ContentType, &msg.ContentEncoding, &msg.CorrelationID,
+		&msg.CausationID, &msg.RoutingKey, &msg.OrderingKey, &msg.Priority, &msg.IdempotencyKey, &msg.Headers, &msg.Attributes, &msg.ExpiresAt,
+		&msg.Status, &msg.StatusTimestamps, &msg.DeliveryAttempts, &msg.CreatedAt, &msg.UpdatedAt)
+}
+
+// messageValues returns msg's values in messageColumns order.
+func messageValues(msg *models.Message) []interface{} {
+	return []interface{}{msg.ID, msg.TenantID, msg.Payload, msg.ContentType, msg.ContentEncoding, msg.CorrelationID,
+		msg.CausationID, msg.RoutingKey, msg.OrderingKey, msg.Priority, msg.IdempotencyKey, msg.Headers, msg.Attributes, msg.ExpiresAt,
+		msg.Status, msg.StatusTimestamps, msg.DeliveryAttempts, msg.CreatedAt, msg.UpdatedAt}
+}
+
 type MessageRepository struct {
 	db *sql.DB
 }
@@ -16,14 +50,104 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
-func (r *MessageRepository) CreateMessage(message *models.Message) error {
-	query := "INSERT INTO messages (tenant_id, content) VALUES ($1, $2)"
-	_, err := r.db.Exec(query, message.TenantID, message.Content)
-	return err
+// CreateMessage stores a new message, filling in its generated ID and
+// timestamps.
+func (r *MessageRepository) CreateMessage(ctx context.Context, message *models.Message) error {
+	query := `
+        INSERT INTO messages (tenant_id, payload, content_type, content_encoding, correlation_id,
+            causation_id, routing_key, ordering_key, priority, idempotency_key, headers, attributes, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        RETURNING id, status, status_timestamps, created_at, updated_at
+    `
+	return r.db.QueryRowContext(ctx, query, message.TenantID, message.Payload, message.ContentType, message.ContentEncoding,
+		message.CorrelationID, message.CausationID, message.RoutingKey, message.OrderingKey, message.Priority, message.IdempotencyKey,
+		message.Headers, message.Attributes, message.ExpiresAt).
+		Scan(&message.ID, &message.Status, &message.StatusTimestamps, &message.CreatedAt, &message.UpdatedAt)
+}
+
+// CreateScheduledMessage stores a new message together with the schedule
+// that delivers it at msg.DeliverAt.
+func (r *MessageRepository) CreateScheduledMessage(ctx context.Context, message *models.Message) error {
+	query := `
+        WITH m AS (
+            INSERT INTO messages (tenant_id, payload, content_type, content_encoding, correlation_id,
+                causation_id, routing_key, ordering_key, priority, idempotency_key, headers, attributes, expires_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+            RETURNING id, tenant_id, status, status_timestamps, created_at, updated_at
+        ), s AS (
+            INSERT INTO scheduled_messages (tenant_id, message_id, deliver_at)
+            SELECT tenant_id, id, $14 FROM m
+        )
+        SELECT id, status, status_timestamps, created_at, updated_at FROM m
+    `
+	return r.db.QueryRowContext(ctx, query, message.TenantID, message.Payload, message.ContentType, message.ContentEncoding,
+		message.CorrelationID, message.CausationID, message.RoutingKey, message.OrderingKey, message.Priority, message.IdempotencyKey,
+		message.Headers, message.Attributes, message.ExpiresAt, message.DeliverAt).
+		Scan(&message.ID, &message.Status, &message.StatusTimestamps, &message.CreatedAt, &message.UpdatedAt)
+}
+
+// Transition moves msg to status, recording when it did, and refreshes
+// msg's status fields. Entering processing counts a delivery attempt. It
+// returns models.ErrInvalidTransition if the message is missing or its
+// current status cannot move to status.
+func (r *MessageRepository) Transition(ctx context.Context, msg *models.Message, status string) error {
+	query := `
+        UPDATE messages
+        SET status = $3::text,
+            status_timestamps = status_timestamps || jsonb_build_object($3::text, CURRENT_TIMESTAMP),
+            delivery_attempts = delivery_attempts + CASE WHEN $3::text = 'processing' THEN 1 ELSE 0 END,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE tenant_id = $1 AND id = $2 AND status = ANY($4)
+        RETURNING status, status_timestamps, delivery_attempts, updated_at
+    `
+	err := r.db.QueryRowContext(ctx, query, msg.TenantID, msg.ID, status, pq.Array(models.StatusSources(status))).
+		Scan(&msg.Status, &msg.StatusTimestamps, &msg.DeliveryAttempts, &msg.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrInvalidTransition
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update message status: %w", err)
+	}
+	return nil
+}
+
+// ExpireOverdue marks up to limit queued messages expired whose expiry
+// passed more than grace ago, and returns them with only their IDs, tenant
+// and status fields set. RabbitMQ dead-letters such messages without a
+// consumer seeing them, so nothing else records their expiry.
+func (r *MessageRepository) ExpireOverdue(ctx context.Context, grace time.Duration, limit int) ([]models.Message, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE messages
+        SET status = 'expired',
+            status_timestamps = status_timestamps || jsonb_build_object('expired', CURRENT_TIMESTAMP),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE status = 'queued'
+        AND (tenant_id, created_at, id) IN (
+            SELECT tenant_id, created_at, id FROM messages
+            WHERE status = 'queued' AND expires_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'
+            LIMIT $2
+        )
+        RETURNING id, tenant_id, status, status_timestamps, delivery_attempts, updated_at
+    `, grace.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire overdue messages: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []models.Message
+	for rows.Next() {
+		var msg models.Message
+		err := rows.Scan(&msg.ID, &msg.TenantID, &msg.Status, &msg.StatusTimestamps, &msg.DeliveryAttempts, &msg.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, msg)
+	}
+	return expired, rows.Err()
 }
 
 func (r *MessageRepository) GetMessagesByTenant(tenantID string) ([]models.Message, error) {
-	query := "SELECT id, tenant_id, content FROM messages WHERE tenant_id = $1"
+	query := "SELECT id, tenant_id, payload FROM messages WHERE tenant_id = $1 ORDER BY created_at, id"
 	rows, err := r.db.Query(query, tenantID)
 	if err != nil {
 		return nil, err
@@ -33,7 +157,7 @@ func (r *MessageRepository) GetMessagesByTenant(tenantID string) ([]models.Messa
 	var messages []models.Message
 	for rows.Next() {
 		var message models.Message
-		if err := rows.Scan(&message.ID, &message.TenantID, &message.Content); err != nil {
+		if err := rows.Scan(&message.ID, &message.TenantID, &message.Payload); err != nil {
 			return nil, err
 		}
 		messages = append(messages, message)
@@ -41,18 +165,42 @@ func (r *MessageRepository) GetMessagesByTenant(tenantID string) ([]models.Messa
 	return messages, nil
 }
 
-// ListMessagesWithCursor implements cursor-based pagination for messages
-func (r *MessageRepository) ListMessagesWithCursor(ctx context.Context, tenantID string, cursor string, limit int) ([]models.Message, string, error) {
+// GetMessage returns a single message of the tenant.
+func (r *MessageRepository) GetMessage(ctx context.Context, tenantID, id string) (*models.Message, error) {
 	query := `
-        SELECT id, tenant_id, payload, created_at 
+        SELECT ` + messageColumns + `
+        FROM messages
+        WHERE tenant_id = $1 AND id = $2
+    `
+	msg := &models.Message{}
+	err := scanMessage(r.db.QueryRowContext(ctx, query, tenantID, id), msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ListMessagesWithCursor implements cursor-based pagination for messages,
+// limited to those in status unless it is empty. Messages are ordered by
+// (created_at, id) so that the cursor's created_at bound lets PostgreSQL
+// prune partitions older than the current page.
+func (r *MessageRepository) ListMessagesWithCursor(ctx context.Context, tenantID, cursor, status string, limit int) ([]models.Message, string, error) {
+	after, afterID, err := DecodeMessageCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+        SELECT ` + messageColumns + `
         FROM messages 
         WHERE tenant_id = $1 
-        AND ($2 = '' OR id > $2)
-        ORDER BY id
-        LIMIT $3
+        AND (created_at, id) > ($2, $3)
+        AND ($5 = '' OR status = $5)
+        ORDER BY created_at, id
+        LIMIT $4
     `
 
-	rows, err := r.db.QueryContext(ctx, query, tenantID, cursor, limit)
+	rows, err := r.db.QueryContext(ctx, query, tenantID, after, afterID, limit, status)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to query messages: %w", err)
 	}
@@ -61,7 +209,7 @@ func (r *MessageRepository) ListMessagesWithCursor(ctx context.Context, tenantID
 	var messages []models.Message
 	for rows.Next() {
 		var msg models.Message
-		if err := rows.Scan(&msg.ID, &msg.TenantID, &msg.Content, &msg.CreatedAt); err != nil {
+		if err := scanMessage(rows, &msg); err != nil {
 			return nil, "", fmt.Errorf("failed to scan message row: %w", err)
 		}
 		messages = append(messages, msg)
@@ -73,3 +221,58 @@ func (r *MessageRepository) ListMessagesWithCursor(ctx context.Context, tenantID
 
 	return messages, cursor, nil
 }
+
+// EncodeMessageCursor returns an opaque cursor positioned after msg.
+func EncodeMessageCursor(msg models.Message) string {
+	return EncodeCursor(msg.CreatedAt, msg.ID)
+}
+
+// EncodeCursor returns an opaque cursor positioned after the row ordered by
+// (t, id). DecodeMessageCursor decodes it.
+func EncodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.Format(time.RFC3339Nano) + "|" + id))
+}
+
+// DecodeMessageCursor parses a cursor produced by EncodeMessageCursor. An
+// empty cursor decodes to a position before every message.
+func DecodeMessageCursor(cursor string) (time.Time, string, error) {
+	if cursor == "" {
+		return time.Time{}, nilUUID, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	after, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	if !isUUID(id) {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return after, id, nil
+}
+
+// isUUID reports whether s is a UUID in its canonical hyphenated form.
+func isUUID(s string) bool {
+	if len(s) != len(nilUUID) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+func TestMessageCursorRoundTrip(t *testing.T) {
+	msg := models.Message{
+		ID:        "3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6f",
+		CreatedAt: time.Date(2024, 5, 10, 10, 30, 15, 123456789, time.UTC),
+	}
+	after, id, err := DecodeMessageCursor(EncodeMessageCursor(msg))
+	if err != nil {
+		t.Fatalf("DecodeMessageCursor: %v", err)
+	}
+	if !after.Equal(msg.CreatedAt) || id != msg.ID {
+		t.Errorf("DecodeMessageCursor = %v, %s, want %v, %s", after, id, msg.CreatedAt, msg.ID)
+	}
+
+	after, id, err = DecodeMessageCursor("")
+	if err != nil || !after.IsZero() || id != nilUUID {
+		t.Errorf("DecodeMessageCursor(\"\") = %v, %s, %v, want the start", after, id, err)
+	}
+}
+
+func TestDecodeMessageCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"malformed base64", "not base64!"},
+		{"missing separator", encode("2024-05-10T10:30:15Z")},
+		{"invalid time", encode("yesterday|3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6f")},
+		{"empty id", encode("2024-05-10T10:30:15Z|")},
+		{"non-UUID id", encode("2024-05-10T10:30:15Z|42")},
+		{"SQL in id", encode("2024-05-10T10:30:15Z|' OR 1=1 --")},
+		{"non-hex UUID", encode("2024-05-10T10:30:15Z|3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6g")},
+		{"misplaced hyphen", encode("2024-05-10T10:30:15Z|3f2b8c1e9-d4a-4e6b-8f7c-1a2b3c4d5e6f")},
+		{"UUID with braces", encode("2024-05-10T10:30:15Z|{3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6f}")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodeMessageCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeMessageCursor error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6f", true},
+		{"3F2B8C1E-9D4A-4E6B-8F7C-1A2B3C4D5E6F", true},
+		{nilUUID, true},
+		{"", false},
+		{"3f2b8c1e9d4a4e6b8f7c1a2b3c4d5e6f", false},
+		{"3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6", false},
+		{"3f2b8c1e-9d4a-4e6b-8f7c-1a2b3c4d5e6fa", false},
+		{"3f2b8c1e-9d4a-4e6b-8f7c_1a2b3c4d5e6f", false},
+	}
+	for _, tt := range tests {
+		if got := isUUID(tt.s); got != tt.want {
+			t.Errorf("isUUID(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
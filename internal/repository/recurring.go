@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// RecurringRepository stores recurring schedules and their run history.
+type RecurringRepository struct {
+	db *sql.DB
+}
+
+func NewRecurringRepository(db *sql.DB) *RecurringRepository {
+	return &RecurringRepository{db: db}
+}
+
+const recurringColumns = `id, tenant_id, name, cron_expr, timezone, template, start_at, end_at,
+        max_occurrences, occurrences, misfire_policy, enabled, next_run_at, last_run_at,
+        created_at, updated_at`
+
+func scanRecurring(row rowScanner, s *models.RecurringSchedule) error {
+	return row.Scan(&s.ID, &s.TenantID, &s.Name, &s.Cron, &s.Timezone, &s.Template, &s.StartAt, &s.EndAt,
+		&s.MaxOccurrences, &s.Occurrences, &s.MisfirePolicy, &s.Enabled, &s.NextRunAt, &s.LastRunAt,
+		&s.CreatedAt, &s.UpdatedAt)
+}
+
+func (r *RecurringRepository) querySchedules(ctx context.Context, query string, args ...interface{}) ([]models.RecurringSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []models.RecurringSchedule
+	for rows.Next() {
+		var s models.RecurringSchedule
+		if err := scanRecurring(rows, &s); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// Create inserts a schedule, filling in its ID and timestamps.
+func (r *RecurringRepository) Create(ctx context.Context, s *models.RecurringSchedule) error {
+	query := `
+        INSERT INTO recurring_schedules (tenant_id, name, cron_expr, timezone, template, start_at, end_at,
+            max_occurrences, misfire_policy, enabled, next_run_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id, created_at, updated_at
+    `
+	return r.db.QueryRowContext(ctx, query, s.TenantID, s.Name, s.Cron, s.Timezone, s.Template, s.StartAt, s.EndAt,
+		s.MaxOccurrences, s.MisfirePolicy, s.Enabled, s.NextRunAt).
+		Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// Get returns one of the tenant's schedules.
+func (r *RecurringRepository) Get(ctx context.Context, tenantID, id string) (*models.RecurringSchedule, error) {
+	query := "SELECT " + recurringColumns + " FROM recurring_schedules WHERE tenant_id = $1 AND id = $2"
+	s := &models.RecurringSchedule{}
+	if err := scanRecurring(r.db.QueryRowContext(ctx, query, tenantID, id), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the tenant's schedules, oldest first.
+func (r *RecurringRepository) List(ctx context.Context, tenantID string) ([]models.RecurringSchedule, error) {
+	query := "SELECT " + recurringColumns + " FROM recurring_schedules WHERE tenant_id = $1 ORDER BY created_at, id"
+	return r.querySchedules(ctx, query, tenantID)
+}
+
+// Update replaces a schedule's definition and next run time, releasing any
+// claim on it. The occurrence count is kept.
+func (r *RecurringRepository) Update(ctx context.Context, s *models.RecurringSchedule) error {
+	query := `
+        UPDATE recurring_schedules
+        SET name = $3, cron_expr = $4, timezone = $5, template = $6, start_at = $7, end_at = $8,
+            max_occurrences = $9, misfire_policy = $10, enabled = $11, next_run_at = $12,
+            claimed_until = NULL, updated_at = CURRENT_TIMESTAMP
+        WHERE tenant_id = $1 AND id = $2
+        RETURNING occurrences, last_run_at, created_at, updated_at
+    `
+	return r.db.QueryRowContext(ctx, query, s.TenantID, s.ID, s.Name, s.Cron, s.Timezone, s.Template, s.StartAt, s.EndAt,
+		s.MaxOccurrences, s.MisfirePolicy, s.Enabled, s.NextRunAt).
+		Scan(&s.Occurrences, &s.LastRunAt, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// Delete removes a schedule and its run history.
+func (r *RecurringRepository) Delete(ctx context.Context, tenantID, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM recurring_schedules WHERE tenant_id = $1 AND id = $2", tenantID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurring schedule: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDue reserves up to limit enabled schedules whose next run is due,
+// until lease has passed, and returns them. SKIP LOCKED lets several server
+// instances claim concurrently without picking the same schedule.
+func (r *RecurringRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.RecurringSchedule, error) {
+	query := `
+        UPDATE recurring_schedules
+        SET claimed_until = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+        WHERE id IN (
+            SELECT id FROM recurring_schedules
+            WHERE enabled AND next_run_at <= CURRENT_TIMESTAMP
+            AND (claimed_until IS NULL OR claimed_until < CURRENT_TIMESTAMP)
+            ORDER BY next_run_at
+            FOR UPDATE SKIP LOCKED
+            LIMIT $1
+        )
+        RETURNING ` + recurringColumns
+	return r.querySchedules(ctx, query, limit, lease.Seconds())
+}
+
+// Advance records the progress of a claimed schedule and releases it.
+func (r *RecurringRepository) Advance(ctx context.Context, s *models.RecurringSchedule) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE recurring_schedules
+        SET next_run_at = $2, occurrences = $3, last_run_at = $4, claimed_until = NULL,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+    `, s.ID, s.NextRunAt, s.Occurrences, s.LastRunAt)
+	if err != nil {
+		return fmt.Errorf("failed to advance recurring schedule: %w", err)
+	}
+	return nil
+}
+
+// RecordRun appends to a schedule's run history.
+func (r *RecurringRepository) RecordRun(ctx context.Context, run *models.RecurringRun) error {
+	query := `
+        INSERT INTO recurring_runs (schedule_id, tenant_id, scheduled_for, status, message_id, error)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, ran_at
+    `
+	return r.db.QueryRowContext(ctx, query, run.ScheduleID, run.TenantID, run.ScheduledFor, run.Status,
+		run.MessageID, run.Error).
+		Scan(&run.ID, &run.RanAt)
+}
+
+// ListRuns returns a schedule's most recent runs, newest first.
+func (r *RecurringRepository) ListRuns(ctx context.Context, tenantID, scheduleID string, limit int) ([]models.RecurringRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, schedule_id, tenant_id, scheduled_for, status, message_id, error, ran_at
+        FROM recurring_runs
+        WHERE tenant_id = $1 AND schedule_id = $2
+        ORDER BY scheduled_for DESC, ran_at DESC
+        LIMIT $3
+    `, tenantID, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.RecurringRun
+	for rows.Next() {
+		var run models.RecurringRun
+		err := rows.Scan(&run.ID, &run.ScheduleID, &run.TenantID, &run.ScheduledFor, &run.Status,
+			&run.MessageID, &run.Error, &run.RanAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
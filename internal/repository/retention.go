@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RetentionRepository removes messages that fall outside a tenant's
+// retention policy. Every call touches at most limit rows so that a purge
+// never holds locks on a large part of the table.
+type RetentionRepository struct {
+	db *sql.DB
+}
+
+func NewRetentionRepository(db *sql.DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// retentionLockID guards retention runs across server instances.
+const retentionLockID int64 = 7_240_031_028
+
+// MessageKey is a message's position in the order retention purges in.
+type MessageKey struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// KeyAt returns the key ordered before every message created at t or later.
+func KeyAt(t time.Time) MessageKey {
+	return MessageKey{CreatedAt: t, ID: nilUUID}
+}
+
+// TryLock takes the retention advisory lock on a dedicated connection, so
+// that only one server instance purges at a time. It returns false if
+// another instance holds the lock; otherwise the returned function releases
+// it.
+func (r *RetentionRepository) TryLock(ctx context.Context) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", retentionLockID).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire retention lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", retentionLockID); err != nil {
+			log.Printf("Failed to release retention lock: %v", err)
+		}
+		conn.Close()
+	}, true, nil
+}
+
+// PurgeOlderThan removes up to limit of the tenant's messages created
+// before cutoff, oldest first, continuing after the key after if it is not
+// nil. It returns how many were removed and the key of the last one.
+func (r *RetentionRepository) PurgeOlderThan(ctx context.Context, tenantID string, cutoff time.Time, after *MessageKey, limit int) (int64, *MessageKey, error) {
+	return r.purge(ctx, tenantID, "created_at < $2", []interface{}{cutoff}, after, limit)
+}
+
+// KeepBoundary returns the key of the oldest of the tenant's newest keep
+// messages, or nil if the tenant has no more than keep messages.
+func (r *RetentionRepository) KeepBoundary(ctx context.Context, tenantID string, keep int64) (*MessageKey, error) {
+	query := `
+        SELECT created_at, id FROM messages
+        WHERE tenant_id = $1
+        ORDER BY created_at DESC, id DESC
+        OFFSET $2 LIMIT 1
+    `
+	var key MessageKey
+	err := r.db.QueryRowContext(ctx, query, tenantID, keep-1).Scan(&key.CreatedAt, &key.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find retention boundary: %w", err)
+	}
+	return &key, nil
+}
+
+// PurgeBefore removes up to limit of the tenant's messages ordered before
+// the key before, oldest first, continuing after the key after if it is not
+// nil. It returns how many were removed and the key of the last one.
+func (r *RetentionRepository) PurgeBefore(ctx context.Context, tenantID string, before MessageKey, after *MessageKey, limit int) (int64, *MessageKey, error) {
+	return r.purge(ctx, tenantID, "(created_at, id) < ($2, $3)", []interface{}{before.CreatedAt, before.ID}, after, limit)
+}
+
+// purge deletes up to limit of the tenant's messages matching cond, which
+// refers to the parameters $2 onwards given by args, in primary key order
+// from after, together with their events. Batches continue from the last
+// key rather than rescanning the rows just deleted.
+func (r *RetentionRepository) purge(ctx context.Context, tenantID, cond string, args []interface{}, after *MessageKey, limit int) (int64, *MessageKey, error) {
+	args = append([]interface{}{tenantID}, args...)
+	if after != nil {
+		cond += fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(args)+1, len(args)+2)
+		args = append(args, after.CreatedAt, after.ID)
+	}
+	args = append(args, limit)
+	selection := fmt.Sprintf(`
+        SELECT tenant_id, created_at, id FROM messages
+        WHERE tenant_id = $1 AND %s
+        ORDER BY created_at, id
+        LIMIT $%d
+    `, cond, len(args))
+
+	query := fmt.Sprintf(`
+        WITH doomed AS (
+            DELETE FROM messages
+            WHERE (tenant_id, created_at, id) IN (%s)
+            RETURNING tenant_id, created_at, id
+        ), events AS (
+            DELETE FROM message_events e
+            USING doomed
+            WHERE e.tenant_id = doomed.tenant_id AND e.message_id = doomed.id
+        )
+        %s
+    `, selection, purgeResult)
+
+	var n int64
+	var createdAt sql.NullTime
+	var id sql.NullString
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&n, &createdAt, &id); err != nil {
+		return 0, nil, fmt.Errorf("failed to purge messages: %w", err)
+	}
+	if n == 0 {
+		return 0, after, nil
+	}
+	return n, &MessageKey{CreatedAt: createdAt.Time, ID: id.String}, nil
+}
+
+// purgeResult selects the number of rows a purge deleted and the key of
+// the last one.
+const purgeResult = `
+        SELECT c.n, last.created_at, last.id
+        FROM (SELECT count(*) AS n FROM doomed) c
+        LEFT JOIN LATERAL (
+            SELECT created_at, id FROM doomed ORDER BY created_at DESC, id DESC LIMIT 1
+        ) last ON true
+`
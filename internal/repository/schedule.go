@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// ScheduleRepository tracks messages waiting for their delivery time.
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleRepository(db *sql.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+const scheduledColumns = `message_id, tenant_id, deliver_at, status, attempts, last_error,
+        created_at, updated_at, delivered_at`
+
+func scanScheduled(row rowScanner, s *models.ScheduledMessage) error {
+	return row.Scan(&s.MessageID, &s.TenantID, &s.DeliverAt, &s.Status, &s.Attempts, &s.LastError,
+		&s.CreatedAt, &s.UpdatedAt, &s.DeliveredAt)
+}
+
+func (r *ScheduleRepository) queryScheduled(ctx context.Context, query string, args ...interface{}) ([]models.ScheduledMessage, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled messages: %w", err)
+	}
+	defer rows.Close()
+
+	var scheduled []models.ScheduledMessage
+	for rows.Next() {
+		var s models.ScheduledMessage
+		if err := scanScheduled(rows, &s); err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, s)
+	}
+	return scheduled, rows.Err()
+}
+
+// ClaimDue marks up to limit due messages as dispatching until lease has
+// passed and returns them. Messages whose claim expired without being
+// delivered, e.g. after a crash, are claimed again. SKIP LOCKED lets several
+// server instances claim concurrently without picking the same rows.
+func (r *ScheduleRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.ScheduledMessage, error) {
+	query := `
+        UPDATE scheduled_messages
+        SET status = 'dispatching', attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP,
+            claimed_until = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+        WHERE (tenant_id, message_id) IN (
+            SELECT tenant_id, message_id FROM scheduled_messages
+            WHERE (status = 'pending' AND deliver_at <= CURRENT_TIMESTAMP)
+               OR (status = 'dispatching' AND claimed_until < CURRENT_TIMESTAMP)
+            ORDER BY deliver_at
+            FOR UPDATE SKIP LOCKED
+            LIMIT $1
+        )
+        RETURNING ` + scheduledColumns
+	return r.queryScheduled(ctx, query, limit, lease.Seconds())
+}
+
+// MarkDelivered records that a claimed message was enqueued.
+func (r *ScheduleRepository) MarkDelivered(ctx context.Context, tenantID, messageID string) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_messages
+        SET status = 'delivered', delivered_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP,
+            claimed_until = NULL, last_error = ''
+        WHERE tenant_id = $1 AND message_id = $2
+    `, tenantID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to mark scheduled message delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed delivery attempt. The claim is left to
+// expire, which delays the retry; once maxAttempts is reached the message
+// is given up as failed.
+func (r *ScheduleRepository) MarkFailed(ctx context.Context, tenantID, messageID, reason string, maxAttempts int) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE scheduled_messages
+        SET last_error = $3, updated_at = CURRENT_TIMESTAMP,
+            status = CASE WHEN attempts >= $4 THEN 'failed' ELSE status END
+        WHERE tenant_id = $1 AND message_id = $2
+    `, tenantID, messageID, reason, maxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to record scheduled delivery failure: %w", err)
+	}
+	return nil
+}
+
+// Get returns one of the tenant's scheduled messages.
+func (r *ScheduleRepository) Get(ctx context.Context, tenantID, messageID string) (*models.ScheduledMessage, error) {
+	query := "SELECT " + scheduledColumns + " FROM scheduled_messages WHERE tenant_id = $1 AND message_id = $2"
+	s := &models.ScheduledMessage{}
+	if err := scanScheduled(r.db.QueryRowContext(ctx, query, tenantID, messageID), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns up to limit of the tenant's scheduled messages after cursor,
+// ordered by delivery time. An empty status lists every status.
+func (r *ScheduleRepository) List(ctx context.Context, tenantID, status, cursor string, limit int) ([]models.ScheduledMessage, error) {
+	after, afterID, err := DecodeMessageCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        SELECT ` + scheduledColumns + `
+        FROM scheduled_messages
+        WHERE tenant_id = $1 AND ($2 = '' OR status = $2)
+        AND (deliver_at, message_id) > ($3, $4)
+        ORDER BY deliver_at, message_id
+        LIMIT $5
+    `
+	return r.queryScheduled(ctx, query, tenantID, status, after, afterID, limit)
+}
+
+// Reschedule moves a pending message's delivery time. It returns
+// sql.ErrNoRows if the message is not pending.
+func (r *ScheduleRepository) Reschedule(ctx context.Context, tenantID, messageID string, deliverAt time.Time) (*models.ScheduledMessage, error) {
+	query := `
+        UPDATE scheduled_messages SET deliver_at = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE tenant_id = $1 AND message_id = $2 AND status = 'pending'
+        RETURNING ` + scheduledColumns
+	s := &models.ScheduledMessage{}
+	if err := scanScheduled(r.db.QueryRowContext(ctx, query, tenantID, messageID, deliverAt), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Cancel stops a pending message from being delivered. It returns
+// sql.ErrNoRows if the message is not pending.
+func (r *ScheduleRepository) Cancel(ctx context.Context, tenantID, messageID string) (*models.ScheduledMessage, error) {
+	query := `
+        UPDATE scheduled_messages SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+        WHERE tenant_id = $1 AND message_id = $2 AND status = 'pending'
+        RETURNING ` + scheduledColumns
+	s := &models.ScheduledMessage{}
+	if err := scanScheduled(r.db.QueryRowContext(ctx, query, tenantID, messageID), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
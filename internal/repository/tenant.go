@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/abiewardani/go-messaging-system/internal/models"
 )
@@ -16,28 +17,81 @@ func NewTenantRepository(db *sql.DB) *TenantRepository {
 }
 
 func (r *TenantRepository) Create(ctx context.Context, tenant *models.Tenant) error {
-	query := "INSERT INTO tenants (name, description) VALUES ($1, $2) RETURNING id"
-	return r.db.QueryRowContext(ctx, query, tenant.Name, tenant.Description).Scan(&tenant.ID)
+	query := "INSERT INTO tenants (name, description, config) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at"
+	return r.db.QueryRowContext(ctx, query, tenant.Name, tenant.Description, tenant.Config).
+		Scan(&tenant.ID, &tenant.CreatedAt, &tenant.UpdatedAt)
 }
 
-func (r *TenantRepository) GetTenantByID(id int) (*models.Tenant, error) {
+func (r *TenantRepository) GetTenantByID(ctx context.Context, id string) (*models.Tenant, error) {
 	tenant := &models.Tenant{}
-	query := "SELECT id, name, config FROM tenants WHERE id = $1"
-	err := r.db.QueryRow(query, id).Scan(&tenant.ID, &tenant.Name, &tenant.Config)
+	query := "SELECT id, name, description, config, created_at, updated_at FROM tenants WHERE id = $1"
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&tenant.ID, &tenant.Name, &tenant.Description, &tenant.Config, &tenant.CreatedAt, &tenant.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return tenant, nil
 }
 
-func (r *TenantRepository) UpdateTenant(tenant *models.Tenant) error {
-	query := "UPDATE tenants SET name = $1, config = $2 WHERE id = $3"
-	_, err := r.db.Exec(query, tenant.Name, tenant.Config, tenant.ID)
+// ListTenants returns every tenant ordered by creation time.
+func (r *TenantRepository) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	query := "SELECT id, name, description, config, created_at, updated_at FROM tenants ORDER BY created_at, id"
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var tenant models.Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Name, &tenant.Description, &tenant.Config, &tenant.CreatedAt, &tenant.UpdatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+func (r *TenantRepository) UpdateTenant(ctx context.Context, tenant *models.Tenant) error {
+	query := "UPDATE tenants SET name = $1, config = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3"
+	_, err := r.db.ExecContext(ctx, query, tenant.Name, tenant.Config, tenant.ID)
 	return err
 }
 
-func (r *TenantRepository) DeleteTenant(id int) error {
+// UpdateConfig applies fn to the tenant's config and saves the result in a
+// transaction that locks the tenant's row, so concurrent updates of
+// different settings cannot overwrite each other. An error from fn aborts
+// the update and is returned as is.
+func (r *TenantRepository) UpdateConfig(ctx context.Context, id string, fn func(*models.TenantConfig) error) (*models.Tenant, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tenant := &models.Tenant{}
+	query := "SELECT id, name, description, config, created_at, updated_at FROM tenants WHERE id = $1 FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&tenant.ID, &tenant.Name, &tenant.Description, &tenant.Config, &tenant.CreatedAt, &tenant.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(&tenant.Config); err != nil {
+		return nil, err
+	}
+	query = "UPDATE tenants SET config = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING updated_at"
+	if err := tx.QueryRowContext(ctx, query, tenant.Config, tenant.ID).Scan(&tenant.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to update tenant config: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tenant config: %w", err)
+	}
+	return tenant, nil
+}
+
+func (r *TenantRepository) DeleteTenant(ctx context.Context, id string) error {
 	query := "DELETE FROM tenants WHERE id = $1"
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// Env is what a condition is evaluated against.
+type Env struct {
+	Attributes map[string]interface{}
+	// Content is the decoded JSON payload, or nil if the payload is not
+	// JSON.
+	Content       interface{}
+	Headers       map[string]string
+	RoutingKey    string
+	ContentType   string
+	CorrelationID string
+	Priority      uint8
+}
+
+// MessageEnv builds the environment of msg, decoding its payload when it is
+// uncompressed JSON.
+func MessageEnv(msg *models.Message) *Env {
+	env := &Env{
+		Attributes:    msg.Attributes,
+		Headers:       msg.Headers,
+		RoutingKey:    msg.RoutingKey,
+		ContentType:   msg.ContentType,
+		CorrelationID: msg.CorrelationID,
+		Priority:      msg.Priority,
+	}
+	if isJSON(msg.ContentType, msg.ContentEncoding) {
+		var content interface{}
+		if err := json.Unmarshal(msg.Payload, &content); err == nil {
+			env.Content = content
+		}
+	}
+	return env
+}
+
+func (env *Env) root(name string) interface{} {
+	switch name {
+	case "attributes":
+		if env.Attributes == nil {
+			return nil
+		}
+		return env.Attributes
+	case "content":
+		return env.Content
+	case "headers":
+		return normalize(env.Headers)
+	case "routing_key":
+		return env.RoutingKey
+	case "content_type":
+		return env.ContentType
+	case "correlation_id":
+		return env.CorrelationID
+	case "priority":
+		return float64(env.Priority)
+	}
+	return nil
+}
+
+func isJSON(contentType, encoding string) bool {
+	if encoding != "" && encoding != "identity" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
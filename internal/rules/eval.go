@@ -0,0 +1,204 @@
+package rules
+
+import "strings"
+
+// node is an expression. eval returns nil, a bool, a float64, a string,
+// a []interface{} or a map[string]interface{}; a missing path is nil.
+type node interface {
+	eval(env *Env) interface{}
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (l literal) eval(*Env) interface{} {
+	return l.value
+}
+
+type listNode []node
+
+func (l listNode) eval(env *Env) interface{} {
+	values := make([]interface{}, len(l))
+	for i, item := range l {
+		values[i] = item.eval(env)
+	}
+	return values
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(env *Env) interface{} {
+	return n.x.eval(env) != true
+}
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicNode) eval(env *Env) interface{} {
+	left := n.left.eval(env) == true
+	if n.and != left {
+		// false and ..., true or ...
+		return left
+	}
+	return n.right.eval(env) == true
+}
+
+type pathNode struct {
+	root string
+	// segments are map keys (string) or list indexes (int).
+	segments []interface{}
+}
+
+func (n *pathNode) eval(env *Env) interface{} {
+	value := env.root(n.root)
+	for _, seg := range n.segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			key, ok := seg.(string)
+			if !ok {
+				return nil
+			}
+			value = v[key]
+		case []interface{}:
+			i, ok := seg.(int)
+			if !ok || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return normalize(value)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+// eval compares the operands. Values of different types are never equal
+// nor ordered, so every comparison with a missing value but "!= null" is
+// false.
+func (n *compareNode) eval(env *Env) interface{} {
+	left, right := n.left.eval(env), n.right.eval(env)
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "<", "<=", ">", ">=":
+		c, ok := order(left, right)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c >= 0
+		}
+	case "in":
+		return contains(right, left)
+	case "contains":
+		return contains(left, right)
+	}
+	return false
+}
+
+// contains reports whether the list has an item equal to x, the string has
+// the substring x, or the object has the key x.
+func contains(container, x interface{}) bool {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if equal(item, x) {
+				return true
+			}
+		}
+	case string:
+		s, ok := x.(string)
+		return ok && strings.Contains(c, s)
+	case map[string]interface{}:
+		key, ok := x.(string)
+		if ok {
+			_, ok = c[key]
+		}
+		return ok
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case float64:
+		b, ok := b.(float64)
+		return ok && a == b
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	}
+	return false
+}
+
+func order(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+// normalize converts the numbers of Go-built values to float64, as
+// decoded JSON has them.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	}
+	return v
+}
@@ -0,0 +1,277 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits on conditions, so evaluating one stays cheap.
+const (
+	MaxLength = 1024
+	maxDepth  = 32
+)
+
+// roots are the names a path may start with.
+var roots = map[string]bool{
+	"attributes":     true,
+	"content":        true,
+	"headers":        true,
+	"routing_key":    true,
+	"content_type":   true,
+	"correlation_id": true,
+	"priority":       true,
+}
+
+// Condition is a compiled rule condition.
+type Condition struct {
+	src  string
+	root node
+}
+
+// Compile parses a condition such as
+//
+//	attributes.region == 'EU' and content.amount > 1000
+func Compile(src string) (*Condition, error) {
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("condition is longer than %d bytes", MaxLength)
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok)
+	}
+	return &Condition{src: src, root: root}, nil
+}
+
+// Match reports whether the condition holds for env. Only a condition that
+// evaluates to true matches.
+func (c *Condition) Match(env *Env) bool {
+	return c.root.eval(env) == true
+}
+
+func (c *Condition) String() string {
+	return c.src
+}
+
+type parser struct {
+	toks  []token
+	pos   int
+	depth int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given punctuation or
+// keywords.
+func (p *parser) accept(texts ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokPunct && tok.kind != tokIdent {
+		return "", false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *parser) unexpected(tok token) error {
+	if tok.kind == tokEOF {
+		return errors.New("unexpected end of condition")
+	}
+	return fmt.Errorf("unexpected %q at %d", tok.text, tok.pos+1)
+}
+
+// enter bounds nesting, so deeply nested input cannot exhaust the stack.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return fmt.Errorf("condition is nested deeper than %d levels", maxDepth)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("or", "||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("and", "&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.accept("not", "!"); ok {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseNot()
+		p.depth--
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in", "contains")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return literal{tok.num}, nil
+	case tokString:
+		return literal{tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		if !roots[tok.text] {
+			return nil, fmt.Errorf("unknown name %q at %d", tok.text, tok.pos+1)
+		}
+		return p.parsePath(tok.text)
+	case tokPunct:
+		switch tok.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			return p.parseList()
+		case "-":
+			if num := p.peek(); num.kind == tokNumber {
+				p.pos++
+				return literal{-num.num}, nil
+			}
+		}
+	}
+	return nil, p.unexpected(tok)
+}
+
+// parsePath parses the segments after a root: .name, ['name'] or [index].
+func (p *parser) parsePath(root string) (node, error) {
+	path := &pathNode{root: root}
+	for {
+		if _, ok := p.accept("."); ok {
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, p.unexpected(tok)
+			}
+			path.segments = append(path.segments, tok.text)
+			continue
+		}
+		if _, ok := p.accept("["); ok {
+			tok := p.next()
+			switch {
+			case tok.kind == tokString:
+				path.segments = append(path.segments, tok.text)
+			case tok.kind == tokNumber && tok.num >= 0 && tok.num == float64(int(tok.num)):
+				path.segments = append(path.segments, int(tok.num))
+			default:
+				return nil, p.unexpected(tok)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return path, nil
+	}
+}
+
+func (p *parser) parseList() (node, error) {
+	var list listNode
+	if _, ok := p.accept("]"); ok {
+		return list, nil
+	}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		return list, p.expect("]")
+	}
+}
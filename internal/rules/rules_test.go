@@ -0,0 +1,226 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+func testEnv() *Env {
+	return MessageEnv(&models.Message{
+		Payload:     []byte(`{"amount": 1250, "items": [{"sku": "A-1", "qty": 2}], "tags": ["vip", "eu"], "customer": {"tier": "gold"}}`),
+		ContentType: "application/json; charset=utf-8",
+		Headers:     models.Headers{"customer_id": "c-42"},
+		Attributes:  models.Attributes{"region": "EU", "count": 3, "flag": true},
+		RoutingKey:  "orders.created",
+		Priority:    5,
+	})
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty", "", "unexpected end of condition"},
+		{"unknown root", "region == 'EU'", `unknown name "region" at 1`},
+		{"missing operand", "attributes.region ==", "unexpected end of condition"},
+		{"trailing token", "attributes.a == 1 2", `unexpected "2" at 19`},
+		{"chained comparison", "1 < 2 == true", `unexpected "==" at 7`},
+		{"unclosed paren", "(attributes.a == 1", "unexpected end of condition"},
+		{"unclosed list", "attributes.a in [1, 2", "unexpected end of condition"},
+		{"dangling dot", "attributes.", "unexpected end of condition"},
+		{"fractional index", "content.items[1.5] == 1", `unexpected "1.5" at 15`},
+		{"negative index", "content.items[-1] == 1", `unexpected "-" at 15`},
+		{"unterminated string", "attributes.region == 'EU", "unterminated string at 22"},
+		{"bad character", "attributes.a = 1", "unexpected character '=' at 14"},
+		{"bad number", "attributes.a == 1e", `invalid number "1e" at 17`},
+		{"too long", "attributes.a == '" + strings.Repeat("x", MaxLength) + "'", "condition is longer than"},
+		{"nested parens", strings.Repeat("(", maxDepth) + "true" + strings.Repeat(")", maxDepth), "nested deeper than"},
+		{"nested nots", strings.Repeat("not ", maxDepth+1) + "true", "nested deeper than"},
+		{"nested lists", strings.Repeat("[", maxDepth+1) + strings.Repeat("]", maxDepth+1), "nested deeper than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if err == nil {
+				t.Fatalf("Compile(%q) succeeded, want error containing %q", tt.src, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile(%q) error = %q, want it to contain %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// Precedence: not binds tighter than and, and than or
+		{"true or false and false", true},
+		{"(true or false) and false", false},
+		{"false and false or true", true},
+		{"false and (false or true)", false},
+		{"not true and false", false},
+		{"not (true and false)", true},
+		{"not false or false", true},
+		{"not not true", true},
+		{"true || false && false", true},
+		{"!true && false", false},
+		{strings.Repeat("(", maxDepth-1) + "true" + strings.Repeat(")", maxDepth-1), true},
+		{strings.Repeat("not ", maxDepth-1) + "false", true},
+
+		// Paths and comparisons
+		{"attributes.region == 'EU'", true},
+		{`attributes.region == "EU"`, true},
+		{"attributes.region != 'US'", true},
+		{"attributes.count == 3", true},
+		{"attributes.count >= 3 and attributes.count < 4", true},
+		{"attributes.flag", true},
+		{"attributes.flag == true", true},
+		{"attributes['region'] == 'EU'", true},
+		{"content.amount > 1000", true},
+		{"content.amount <= 1250.0", true},
+		{"content.amount > -1", true},
+		{"content.items[0].sku == 'A-1'", true},
+		{"content.items[0]['qty'] == 2", true},
+		{"content.customer.tier == 'gold'", true},
+		{"headers.customer_id == 'c-42'", true},
+		{"routing_key == 'orders.created'", true},
+		{"content_type contains 'json'", true},
+		{"priority >= 5", true},
+		{"'b' > 'a'", true},
+		{"attributes.region in ['EU', 'UK']", true},
+		{"attributes.region in ['US']", false},
+		{"attributes.region in []", false},
+		{"content.tags contains 'vip'", true},
+		{"content.tags contains 'us'", false},
+		{"routing_key contains 'created'", true},
+		{"content.customer contains 'tier'", true},
+		{"content.customer contains 'name'", false},
+		{"content contains 1250", false},
+
+		// Missing paths are null, which equals only null and orders with
+		// nothing
+		{"attributes.missing == null", true},
+		{"attributes.missing != null", false},
+		{"attributes.missing == 0", false},
+		{"attributes.missing != 0", true},
+		{"attributes.missing > 0", false},
+		{"attributes.missing < 0", false},
+		{"attributes.missing", false},
+		{"not attributes.missing", true},
+		{"content.items[5].sku == null", true},
+		{"content.items.sku == null", true},
+		{"content.customer[0] == null", true},
+		{"content.amount.value == null", true},
+		{"headers.missing == null", true},
+		{"attributes.missing in ['EU']", false},
+		{"attributes.missing contains 'EU'", false},
+	}
+	env := testEnv()
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			cond, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.src, err)
+			}
+			if got := cond.Match(env); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchWithoutContent(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *models.Message
+		src  string
+		want bool
+	}{
+		{"text payload", &models.Message{Payload: []byte(`{"a": 1}`), ContentType: "text/plain"}, "content == null", true},
+		{"compressed payload", &models.Message{Payload: []byte(`{"a": 1}`), ContentType: "application/json", ContentEncoding: "gzip"}, "content.a == null", true},
+		{"invalid JSON", &models.Message{Payload: []byte(`{`), ContentType: "application/json"}, "content == null", true},
+		{"suffixed JSON", &models.Message{Payload: []byte(`{"a": 1}`), ContentType: "application/vnd.orders+json"}, "content.a == 1", true},
+		{"no attributes", &models.Message{}, "attributes == null", true},
+		{"no attributes path", &models.Message{}, "attributes.region == null", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.src, err)
+			}
+			if got := cond.Match(MessageEnv(tt.msg)); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	list := []interface{}{1.0}
+	object := map[string]interface{}{"a": 1.0}
+	tests := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{"nulls", nil, nil, true},
+		{"null and false", nil, false, false},
+		{"false and null", false, nil, false},
+		{"bools", true, true, true},
+		{"different bools", true, false, false},
+		{"numbers", 1.0, 1.0, true},
+		{"different numbers", 1.0, 2.0, false},
+		{"number and string", 1.0, "1", false},
+		{"string and number", "1", 1.0, false},
+		{"number and bool", 1.0, true, false},
+		{"zero and null", 0.0, nil, false},
+		{"strings", "a", "a", true},
+		{"empty string and null", "", nil, false},
+		{"lists", list, list, false},
+		{"objects", object, object, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("equal(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b interface{}
+		want int
+		ok   bool
+	}{
+		{"less", 1.0, 2.0, -1, true},
+		{"greater", 2.0, 1.0, 1, true},
+		{"equal numbers", 2.0, 2.0, 0, true},
+		{"strings", "a", "b", -1, true},
+		{"equal strings", "b", "b", 0, true},
+		{"number and string", 1.0, "2", 0, false},
+		{"string and number", "1", 2.0, 0, false},
+		{"bools", false, true, 0, false},
+		{"nulls", nil, nil, 0, false},
+		{"number and null", 1.0, nil, 0, false},
+		{"lists", []interface{}{}, []interface{}{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := order(tt.a, tt.b)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("order(%v, %v) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/archive"
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+)
+
+// MaxRestoreBatch is the most archived messages a single restore moves back.
+const MaxRestoreBatch = 10000
+
+// ArchiveService moves old messages into compressed segment files and reads
+// them back for lookups, listings and restores.
+type ArchiveService struct {
+	tenants repository.TenantRepository
+	repo    repository.ArchiveRepository
+	store   archive.ObjectStore
+	events  *EventRecorder
+	cfg     config.ArchiveConfig
+}
+
+func NewArchiveService(tenants repository.TenantRepository, repo repository.ArchiveRepository, store archive.ObjectStore, events *EventRecorder, cfg config.ArchiveConfig) *ArchiveService {
+	if cfg.OlderThanDays <= 0 {
+		cfg.OlderThanDays = 365
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = 10000
+	}
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 3600
+	}
+	return &ArchiveService{tenants: tenants, repo: repo, store: store, events: events, cfg: cfg}
+}
+
+// Run archives old messages on every interval until ctx is cancelled.
+func (s *ArchiveService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(ctx); err != nil {
+				log.Printf("Archive run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce archives every tenant's messages older than the threshold. It
+// does nothing if another server instance is already archiving.
+func (s *ArchiveService) RunOnce(ctx context.Context) error {
+	unlock, locked, err := s.repo.TryLock(ctx)
+	if err != nil || !locked {
+		return err
+	}
+	defer unlock()
+
+	tenants, err := s.tenants.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+	cutoff := repository.KeyAt(time.Now().UTC().AddDate(0, 0, -s.cfg.OlderThanDays))
+	for _, tenant := range tenants {
+		if _, err := s.archiveTenant(ctx, tenant.ID, cutoff); err != nil {
+			log.Printf("Archiving failed for tenant %s: %v", tenant.ID, err)
+		}
+	}
+	return nil
+}
+
+// ArchiveBefore archives the tenant's messages ordered before the key
+// before, as a retention policy's archive action does, and returns how many
+// were archived.
+func (s *ArchiveService) ArchiveBefore(ctx context.Context, tenantID string, before repository.MessageKey) (int64, error) {
+	return s.archiveTenant(ctx, tenantID, before)
+}
+
+// archiveTenant writes segments until no messages ordered before the key
+// before remain, and returns how many messages it archived. The segment is
+// stored before the index is committed, so a failure leaves at worst an
+// unreferenced object, never a lost message.
+func (s *ArchiveService) archiveTenant(ctx context.Context, tenantID string, before repository.MessageKey) (int64, error) {
+	var archived int64
+	for {
+		messages, err := s.repo.ArchivableMessages(ctx, tenantID, before, s.cfg.SegmentSize)
+		if err != nil || len(messages) == 0 {
+			return archived, err
+		}
+
+		body, err := archive.EncodeSegment(messages)
+		if err != nil {
+			return archived, err
+		}
+		minCreated := messages[0].CreatedAt
+		maxCreated := messages[len(messages)-1].CreatedAt
+
+		segID := archive.NewSegmentID()
+		seg := &models.ArchiveSegment{
+			ID:           segID,
+			TenantID:     tenantID,
+			ObjectKey:    archive.SegmentKey(tenantID, segID, minCreated),
+			MinCreatedAt: minCreated,
+			MaxCreatedAt: maxCreated,
+			MessageCount: len(messages),
+			SizeBytes:    int64(len(body)),
+		}
+		if err := s.store.PutObject(ctx, seg.ObjectKey, body); err != nil {
+			return archived, fmt.Errorf("failed to store segment: %w", err)
+		}
+		if err := s.repo.CommitSegment(ctx, seg, messages); err != nil {
+			if delErr := s.store.DeleteObject(ctx, seg.ObjectKey); delErr != nil {
+				log.Printf("Failed to remove orphaned segment %s: %v", seg.ObjectKey, delErr)
+			}
+			return archived, err
+		}
+		archived += int64(len(messages))
+		metrics.MessagesArchived.WithLabelValues(tenantID).Add(float64(len(messages)))
+		log.Printf("Archived %d messages for tenant %s into %s", len(messages), tenantID, seg.ObjectKey)
+
+		if len(messages) < s.cfg.SegmentSize {
+			return archived, nil
+		}
+	}
+}
+
+// GetMessage looks up an archived message by ID. It returns sql.ErrNoRows
+// if the message is not in the archive.
+func (s *ArchiveService) GetMessage(ctx context.Context, tenantID, id string) (*models.Message, error) {
+	ref, err := s.repo.FindArchivedMessage(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.loadMessages(ctx, []models.ArchivedMessageRef{*ref})
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &messages[0], nil
+}
+
+// ListAfter returns up to limit archived messages positioned after cursor.
+// Segments are located by their created_at bounds and scanned in order, so
+// a page decodes only the segments it takes messages from, and each of
+// those only up to its last message on the page.
+func (s *ArchiveService) ListAfter(ctx context.Context, tenantID, cursor string, limit int) ([]models.Message, error) {
+	after, afterID, err := repository.DecodeMessageCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	// Every segment indexes at least one message, so limit segments past
+	// the cursor's one hold a full page
+	segments, err := s.repo.SegmentsAfter(ctx, tenantID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	for _, seg := range segments {
+		// Restored messages archived again can overlap older segments, so
+		// stop only at a segment that starts after the full page
+		if len(messages) >= limit && seg.MinCreatedAt.After(messages[limit-1].CreatedAt) {
+			break
+		}
+		page, err := s.segmentAfter(ctx, seg, after, afterID, limit)
+		if err != nil {
+			return nil, err
+		}
+		messages = mergeByCursorOrder(messages, page)
+		if len(messages) > limit {
+			messages = messages[:limit]
+		}
+	}
+	return messages, nil
+}
+
+// segmentAfter returns up to limit of the segment's indexed messages
+// positioned after (after, afterID), reading the segment only until all of
+// them are found. Segments store messages in (created_at, id) order.
+func (s *ArchiveService) segmentAfter(ctx context.Context, seg models.ArchiveSegment, after time.Time, afterID string, limit int) ([]models.Message, error) {
+	ids, err := s.repo.SegmentMessagesAfter(ctx, seg.TenantID, seg.ID, after, afterID, limit)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	body, err := s.store.GetObject(ctx, seg.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segment %s: %w", seg.ObjectKey, err)
+	}
+	messages := make([]models.Message, 0, len(ids))
+	err = archive.ScanSegment(body, func(msg models.Message) bool {
+		if wanted[msg.ID] {
+			messages = append(messages, msg)
+		}
+		return len(messages) < len(ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Restore moves up to limit archived messages created in [from, to) back
+// into the messages table, oldest first, and returns how many were
+// restored.
+func (s *ArchiveService) Restore(ctx context.Context, tenantID string, from, to time.Time, limit int) (int, error) {
+	if limit <= 0 || limit > MaxRestoreBatch {
+		limit = MaxRestoreBatch
+	}
+	refs, err := s.repo.ArchivedInRange(ctx, tenantID, from, to, limit)
+	if err != nil || len(refs) == 0 {
+		return 0, err
+	}
+	messages, err := s.loadMessages(ctx, refs)
+	if err != nil {
+		return 0, err
+	}
+
+	emptied, err := s.repo.RestoreMessages(ctx, tenantID, messages)
+	if err != nil {
+		return 0, err
+	}
+	events := make([]models.MessageEvent, len(messages))
+	for i := range messages {
+		events[i] = messageEvent(&messages[i], models.EventRestored, nil)
+	}
+	s.events.Record(ctx, events...)
+	for _, key := range emptied {
+		if err := s.store.DeleteObject(ctx, key); err != nil {
+			log.Printf("Failed to delete emptied segment %s: %v", key, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// EraseMessages removes archived messages of the tenant for which match
+// returns true, or all of them if match is nil. Segments left empty are
+// deleted; others are rewritten to a new object without the removed
+// messages. It returns the number of messages removed and of segments
+// deleted and rewritten.
+func (s *ArchiveService) EraseMessages(ctx context.Context, tenantID string, match func(models.Message) bool) (removed, deleted, rewritten int64, err error) {
+	segments, err := s.repo.TenantSegments(ctx, tenantID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, seg := range segments {
+		var kept []models.Message
+		var removedIDs []string
+		removedCount := seg.MessageCount
+		if match != nil {
+			body, err := s.store.GetObject(ctx, seg.ObjectKey)
+			if err != nil {
+				return removed, deleted, rewritten, fmt.Errorf("failed to read segment %s: %w", seg.ObjectKey, err)
+			}
+			messages, err := archive.DecodeSegment(body)
+			if err != nil {
+				return removed, deleted, rewritten, err
+			}
+			for _, msg := range messages {
+				if match(msg) {
+					removedIDs = append(removedIDs, msg.ID)
+				} else {
+					kept = append(kept, msg)
+				}
+			}
+			if len(removedIDs) == 0 {
+				continue
+			}
+			removedCount = len(removedIDs)
+		}
+
+		oldKey := seg.ObjectKey
+		if len(kept) == 0 {
+			if err := s.repo.DeleteSegment(ctx, seg.ID); err != nil {
+				return removed, deleted, rewritten, err
+			}
+			deleted++
+		} else {
+			body, err := archive.EncodeSegment(kept)
+			if err != nil {
+				return removed, deleted, rewritten, err
+			}
+			seg.ObjectKey = archive.SegmentKey(tenantID, archive.NewSegmentID(), seg.MinCreatedAt)
+			seg.MessageCount = len(kept)
+			seg.SizeBytes = int64(len(body))
+			if err := s.store.PutObject(ctx, seg.ObjectKey, body); err != nil {
+				return removed, deleted, rewritten, err
+			}
+			if err := s.repo.ReplaceSegmentObject(ctx, &seg, removedIDs); err != nil {
+				return removed, deleted, rewritten, err
+			}
+			rewritten++
+		}
+		if err := s.store.DeleteObject(ctx, oldKey); err != nil {
+			return removed, deleted, rewritten, err
+		}
+		removed += int64(removedCount)
+	}
+
+	// Sweep objects the index doesn't know about, e.g. left behind by a
+	// crash between storing a segment and committing its index
+	if match == nil {
+		keys, err := s.store.ListObjects(ctx, archive.TenantPrefix(tenantID))
+		if err != nil {
+			return removed, deleted, rewritten, err
+		}
+		for _, key := range keys {
+			if err := s.store.DeleteObject(ctx, key); err != nil {
+				return removed, deleted, rewritten, err
+			}
+		}
+	}
+	return removed, deleted, rewritten, nil
+}
+
+// loadMessages reads the referenced messages from their segments, fetching
+// each segment once, and returns them in the order of refs.
+func (s *ArchiveService) loadMessages(ctx context.Context, refs []models.ArchivedMessageRef) ([]models.Message, error) {
+	segments := make(map[string]map[string]models.Message)
+	messages := make([]models.Message, 0, len(refs))
+	for _, ref := range refs {
+		byID, ok := segments[ref.ObjectKey]
+		if !ok {
+			body, err := s.store.GetObject(ctx, ref.ObjectKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read segment %s: %w", ref.ObjectKey, err)
+			}
+			decoded, err := archive.DecodeSegment(body)
+			if err != nil {
+				return nil, err
+			}
+			byID = make(map[string]models.Message, len(decoded))
+			for _, msg := range decoded {
+				byID[msg.ID] = msg
+			}
+			segments[ref.ObjectKey] = byID
+		}
+		if msg, ok := byID[ref.MessageID]; ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+// mergeByCursorOrder merges two lists already sorted by (created_at, id).
+func mergeByCursorOrder(a, b []models.Message) []models.Message {
+	merged := append(append(make([]models.Message, 0, len(a)+len(b)), a...), b...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		ti, tj := merged[i].CreatedAt, merged[j].CreatedAt
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return merged[i].ID < merged[j].ID
+	})
+	return merged
+}
+
+// isNotFound reports whether err means a row does not exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
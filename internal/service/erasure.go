@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+)
+
+const certificateAlgorithm = "HMAC-SHA256"
+
+// QueuePurger removes messages waiting in a tenant's broker queue.
+type QueuePurger interface {
+	PurgeQueue(tenantID string) (int, error)
+	PurgeQueueMatching(tenantID, header, value string) (int, error)
+}
+
+// ErasureService runs tenant data erasure jobs in the background and issues
+// signed certificates describing what each job removed.
+type ErasureService struct {
+	repo    repository.ErasureRepository
+	archive *ArchiveService
+	queues  QueuePurger
+	cfg     config.ErasureConfig
+	wake    chan struct{}
+}
+
+// NewErasureService creates the erasure service. archive may be nil when
+// cold archiving is disabled.
+func NewErasureService(repo repository.ErasureRepository, archive *ArchiveService, queues QueuePurger, cfg config.ErasureConfig) (*ErasureService, error) {
+	if cfg.SigningKey == "" {
+		return nil, errors.New("erasure signing key is not configured")
+	}
+	if cfg.PollIntervalSeconds <= 0 {
+		cfg.PollIntervalSeconds = 30
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	return &ErasureService{
+		repo:    repo,
+		archive: archive,
+		queues:  queues,
+		cfg:     cfg,
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// RequestErasure queues an erasure of the tenant's data. If header is set
+// only messages whose header equals value are erased.
+func (s *ErasureService) RequestErasure(ctx context.Context, tenantID, header, value string) (*models.ErasureJob, error) {
+	if (header == "") != (value == "") {
+		return nil, errors.New("subject header and value must be given together")
+	}
+	job := &models.ErasureJob{TenantID: tenantID, SubjectHeader: header, SubjectValue: value}
+	if err := s.repo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// GetJob returns an erasure job of the tenant.
+func (s *ErasureService) GetJob(ctx context.Context, tenantID, id string) (*models.ErasureJob, error) {
+	return s.repo.GetJob(ctx, tenantID, id)
+}
+
+// Run executes pending jobs as they are requested, and polls for jobs
+// requested on other instances, until ctx is cancelled.
+func (s *ErasureService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		for {
+			job, err := s.repo.ClaimNextJob(ctx)
+			if isNotFound(err) {
+				break
+			}
+			if err != nil {
+				log.Printf("Failed to claim erasure job: %v", err)
+				break
+			}
+			s.execute(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *ErasureService) execute(ctx context.Context, job *models.ErasureJob) {
+	log.Printf("Starting erasure job %s for tenant %s", job.ID, job.TenantID)
+
+	if err := s.erase(ctx, job); err != nil {
+		job.Status = models.ErasureFailed
+		job.Error = err.Error()
+		log.Printf("Erasure job %s failed: %v", job.ID, err)
+	} else {
+		job.Status = models.ErasureCompleted
+		job.Certificate = s.certify(job)
+	}
+	metrics.ErasureJobs.WithLabelValues(job.Status).Inc()
+
+	if err := s.repo.FinishJob(context.Background(), job); err != nil {
+		log.Printf("Failed to record erasure job %s: %v", job.ID, err)
+	}
+}
+
+// erase removes the job's data from every place it is kept, accumulating
+// counts in job.Result as it goes so a failure still reports progress.
+func (s *ErasureService) erase(ctx context.Context, job *models.ErasureJob) error {
+	header, value := job.SubjectHeader, job.SubjectValue
+
+	// Schedules go first, so they cannot publish more of the subject's
+	// messages while the rest is erased
+	schedules, runs, err := s.repo.DeleteRecurringSchedules(ctx, job.TenantID, header, value)
+	job.Result.RecurringSchedules += schedules
+	job.Result.RecurringRuns += runs
+	if err != nil {
+		return err
+	}
+
+	// Delivery schedules are found through their messages, and go before
+	// them so none is dispatched without its message
+	scheduled, err := s.repo.DeleteScheduledMessages(ctx, job.TenantID, header, value)
+	job.Result.ScheduledMessages += scheduled
+	if err != nil {
+		return err
+	}
+
+	// IDs of the subject's erased messages, whose events go with them
+	var erased []string
+	for _, table := range []string{"messages", "messages_archive"} {
+		for {
+			ids, err := s.repo.DeleteMessages(ctx, table, job.TenantID, header, value, s.cfg.BatchSize)
+			n := int64(len(ids))
+			if table == "messages" {
+				job.Result.Messages += n
+			} else {
+				job.Result.RetainedArchive += n
+			}
+			if err != nil {
+				return err
+			}
+			if header != "" {
+				erased = append(erased, ids...)
+			}
+			if n < int64(s.cfg.BatchSize) {
+				break
+			}
+		}
+	}
+
+	n, err := s.repo.DeleteIdempotencyRecords(ctx, job.TenantID, header, value)
+	job.Result.IdempotencyRecords += n
+	if err != nil {
+		return err
+	}
+
+	if s.archive != nil {
+		var match func(models.Message) bool
+		if header != "" {
+			match = func(msg models.Message) bool {
+				if msg.Headers[header] != value {
+					return false
+				}
+				erased = append(erased, msg.ID)
+				return true
+			}
+		}
+		removed, deleted, rewritten, err := s.archive.EraseMessages(ctx, job.TenantID, match)
+		job.Result.ArchivedMessages += removed
+		job.Result.SegmentsDeleted += deleted
+		job.Result.SegmentsRewritten += rewritten
+		if err != nil {
+			return fmt.Errorf("failed to erase archive: %w", err)
+		}
+	}
+
+	// Inbox records hold only message IDs, and keep redeliveries of a
+	// subject's erased messages from being handled again, so only a
+	// full-tenant erasure removes them
+	if header == "" {
+		for {
+			n, err := s.repo.DeleteInboxRecords(ctx, job.TenantID, s.cfg.BatchSize)
+			job.Result.InboxRecords += n
+			if err != nil {
+				return err
+			}
+			if n < int64(s.cfg.BatchSize) {
+				break
+			}
+		}
+	}
+
+	if header == "" {
+		n, err = s.repo.DeleteMessageEvents(ctx, job.TenantID)
+		job.Result.MessageEvents += n
+		if err != nil {
+			return err
+		}
+	}
+	for len(erased) > 0 {
+		batch := erased[:min(len(erased), s.cfg.BatchSize)]
+		erased = erased[len(batch):]
+		n, err = s.repo.DeleteEventsOfMessages(ctx, job.TenantID, batch)
+		job.Result.MessageEvents += n
+		if err != nil {
+			return err
+		}
+	}
+
+	var purged int
+	if header == "" {
+		purged, err = s.queues.PurgeQueue(job.TenantID)
+	} else {
+		purged, err = s.queues.PurgeQueueMatching(job.TenantID, header, value)
+	}
+	job.Result.QueueMessages += int64(purged)
+	if err != nil {
+		return fmt.Errorf("failed to purge queue: %w", err)
+	}
+	return nil
+}
+
+// certify builds and signs the certificate for a completed job.
+func (s *ErasureService) certify(job *models.ErasureJob) *models.ErasureCertificate {
+	cert := &models.ErasureCertificate{
+		JobID:     job.ID,
+		TenantID:  job.TenantID,
+		Scope:     "tenant",
+		Removed:   job.Result,
+		IssuedAt:  time.Now().UTC(),
+		Algorithm: certificateAlgorithm,
+	}
+	if job.SubjectHeader != "" {
+		digest := sha256.Sum256([]byte(job.SubjectValue))
+		cert.Scope = "subject"
+		cert.SubjectHeader = job.SubjectHeader
+		cert.SubjectDigest = hex.EncodeToString(digest[:])
+	}
+	cert.Signature = s.sign(cert)
+	return cert
+}
+
+// VerifyCertificate reports whether cert carries a valid signature.
+func (s *ErasureService) VerifyCertificate(cert *models.ErasureCertificate) bool {
+	expected, err := hex.DecodeString(s.sign(cert))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(cert.Signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
+
+// sign returns the hex HMAC of the certificate's JSON form with an empty
+// signature field.
+func (s *ErasureService) sign(cert *models.ErasureCertificate) string {
+	unsigned := *cert
+	unsigned.Signature = ""
+	payload, _ := json.Marshal(unsigned)
+
+	mac := hmac.New(sha256.New, []byte(s.cfg.SigningKey))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
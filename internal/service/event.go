@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+)
+
+// EventRecorder appends to message timelines, stamping each event with this
+// server's node ID. Timelines are informational, so a failure to record is
+// logged and never fails the operation being recorded. A nil EventRecorder
+// records nothing.
+type EventRecorder struct {
+	repo   repository.EventRepository
+	nodeID string
+}
+
+func NewEventRecorder(repo repository.EventRepository, nodeID string) *EventRecorder {
+	return &EventRecorder{repo: repo, nodeID: nodeID}
+}
+
+// Record stores events, timestamping any without an occurrence time.
+func (r *EventRecorder) Record(ctx context.Context, events ...models.MessageEvent) {
+	if r == nil || len(events) == 0 {
+		return
+	}
+	now := time.Now()
+	for i := range events {
+		events[i].NodeID = r.nodeID
+		if events[i].OccurredAt.IsZero() {
+			events[i].OccurredAt = now
+		}
+	}
+	if err := r.repo.Append(ctx, events); err != nil {
+		log.Printf("Failed to record %d message events: %v", len(events), err)
+	}
+}
+
+// Timeline returns a message's events in the order they occurred.
+func (r *EventRecorder) Timeline(ctx context.Context, tenantID, messageID string) ([]models.MessageEvent, error) {
+	return r.repo.ListForMessage(ctx, tenantID, messageID)
+}
+
+// messageEvent starts an event of msg.
+func messageEvent(msg *models.Message, eventType string, details models.EventDetails) models.MessageEvent {
+	return models.MessageEvent{TenantID: msg.TenantID, MessageID: msg.ID, Type: eventType, Details: details}
+}
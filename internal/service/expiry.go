@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+)
+
+// ExpiryJanitor marks queued messages expired once their expiry has
+// passed. Consumers record the expiry of messages they receive late, but
+// RabbitMQ dead-letters messages that expire while queued on its own.
+type ExpiryJanitor struct {
+	repo   repository.MessageRepository
+	events *EventRecorder
+	cfg    config.ExpiryConfig
+}
+
+func NewExpiryJanitor(repo repository.MessageRepository, events *EventRecorder, cfg config.ExpiryConfig) *ExpiryJanitor {
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 60
+	}
+	if cfg.GraceSeconds <= 0 {
+		cfg.GraceSeconds = 60
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	return &ExpiryJanitor{repo: repo, events: events, cfg: cfg}
+}
+
+// Run expires overdue messages on every interval until ctx is cancelled.
+func (j *ExpiryJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(j.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.RunOnce(ctx); err != nil {
+				log.Printf("Expiry sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce marks every overdue queued message expired.
+func (j *ExpiryJanitor) RunOnce(ctx context.Context) error {
+	grace := time.Duration(j.cfg.GraceSeconds) * time.Second
+	for {
+		expired, err := j.repo.ExpireOverdue(ctx, grace, j.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(expired) > 0 {
+			log.Printf("Marked %d overdue messages expired", len(expired))
+		}
+		events := make([]models.MessageEvent, len(expired))
+		for i := range expired {
+			events[i] = messageEvent(&expired[i], models.EventExpired, models.EventDetails{"reason": "expired in queue"})
+			events[i].OccurredAt = expired[i].StatusTimestamps[models.MessageExpired]
+		}
+		j.events.Record(ctx, events...)
+		if len(expired) < j.cfg.BatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a
+	// different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrIdempotencyKeyInProgress is returned while the key's original
+	// request has not finished.
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// IdempotencyService tracks publish idempotency keys and expires them.
+type IdempotencyService struct {
+	repo repository.IdempotencyRepository
+	cfg  config.IdempotencyConfig
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository, cfg config.IdempotencyConfig) *IdempotencyService {
+	if cfg.TTLSeconds <= 0 {
+		cfg.TTLSeconds = 86400
+	}
+	if cfg.LockTimeoutSeconds <= 0 {
+		cfg.LockTimeoutSeconds = 60
+	}
+	if cfg.CleanupIntervalSeconds <= 0 {
+		cfg.CleanupIntervalSeconds = 3600
+	}
+	return &IdempotencyService{repo: repo, cfg: cfg}
+}
+
+// Begin reserves key for a request fingerprinted by requestHash. It returns
+// nil if the caller now holds the key and should perform the request, or
+// the completed record whose response should be replayed.
+func (s *IdempotencyService) Begin(ctx context.Context, tenantID, key, requestHash string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	rec := &models.IdempotencyRecord{
+		TenantID:    tenantID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(time.Duration(s.cfg.TTLSeconds) * time.Second),
+	}
+	staleBefore := now.Add(-time.Duration(s.cfg.LockTimeoutSeconds) * time.Second)
+	reserved, err := s.repo.Reserve(ctx, rec, staleBefore)
+	if err != nil || reserved {
+		return nil, err
+	}
+
+	existing, err := s.repo.Get(ctx, tenantID, key)
+	if isNotFound(err) {
+		// Expired and cleaned up since the reservation attempt
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !existing.Completed() {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return existing, nil
+}
+
+// Complete records the response of the request holding key.
+func (s *IdempotencyService) Complete(ctx context.Context, tenantID, key, messageID string, statusCode int, response []byte) error {
+	return s.repo.Complete(ctx, &models.IdempotencyRecord{
+		TenantID:   tenantID,
+		Key:        key,
+		MessageID:  messageID,
+		StatusCode: statusCode,
+		Response:   response,
+	})
+}
+
+// Release gives up a key whose request failed, so a retry can run it.
+func (s *IdempotencyService) Release(ctx context.Context, tenantID, key string) error {
+	return s.repo.Release(ctx, tenantID, key)
+}
+
+// Run deletes expired keys on every interval until ctx is cancelled.
+func (s *IdempotencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.CleanupIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(ctx); err != nil {
+				log.Printf("Idempotency key cleanup failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce deletes every expired key.
+func (s *IdempotencyService) RunOnce(ctx context.Context) error {
+	const batchSize = 1000
+	for {
+		n, err := s.repo.DeleteExpired(ctx, batchSize)
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+}
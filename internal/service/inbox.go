@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+)
+
+// defaultInboxWindow is how long processed message IDs are kept when a
+// tenant's inbox policy does not say.
+const defaultInboxWindow = 7 * 24 * time.Hour
+
+// InboxJanitor prunes inbox records older than each tenant's window.
+type InboxJanitor struct {
+	tenants repository.TenantRepository
+	inbox   repository.InboxRepository
+	cfg     config.InboxConfig
+}
+
+func NewInboxJanitor(tenants repository.TenantRepository, inbox repository.InboxRepository, cfg config.InboxConfig) *InboxJanitor {
+	if cfg.CleanupIntervalSeconds <= 0 {
+		cfg.CleanupIntervalSeconds = 3600
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	return &InboxJanitor{tenants: tenants, inbox: inbox, cfg: cfg}
+}
+
+// Run prunes the inbox on every interval until ctx is cancelled.
+func (j *InboxJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(j.cfg.CleanupIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.RunOnce(ctx); err != nil {
+				log.Printf("Inbox cleanup failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce prunes every tenant's inbox. Tenants with the inbox disabled
+// have all their records removed.
+func (j *InboxJanitor) RunOnce(ctx context.Context) error {
+	tenants, err := j.tenants.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, tenant := range tenants {
+		cutoff := now
+		if policy := tenant.Config.Inbox; policy != nil && policy.Enabled {
+			window := defaultInboxWindow
+			if policy.WindowHours > 0 {
+				window = time.Duration(policy.WindowHours) * time.Hour
+			}
+			cutoff = now.Add(-window)
+		}
+		for {
+			n, err := j.inbox.DeleteProcessedBefore(ctx, tenant.ID, cutoff, j.cfg.BatchSize)
+			if err != nil {
+				log.Printf("Inbox cleanup failed for tenant %s: %v", tenant.ID, err)
+				break
+			}
+			if n < int64(j.cfg.BatchSize) {
+				break
+			}
+		}
+	}
+	return nil
+}
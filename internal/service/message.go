@@ -2,18 +2,161 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
 
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
 	"github.com/abiewardani/go-messaging-system/internal/models"
 	"github.com/abiewardani/go-messaging-system/internal/repository"
 	"github.com/abiewardani/go-messaging-system/pkg/metrics"
 )
 
+// ErrMessageExpired is returned when enqueueing a message that has already
+// expired; it is dead-lettered instead.
+var ErrMessageExpired = errors.New("message expired")
+
+// MessagePublisher delivers stored messages to the broker.
+type MessagePublisher interface {
+	Publish(ctx context.Context, msg *models.Message) error
+	PublishToQueue(ctx context.Context, queue string, msg *models.Message) error
+	DeadLetter(ctx context.Context, msg *models.Message, reason string) error
+}
+
 type MessageService struct {
-	repo repository.MessageRepository
+	repo      repository.MessageRepository
+	archive   *ArchiveService
+	publisher MessagePublisher
+	events    *EventRecorder
+	rules     *RuleEngine
+}
+
+// NewMessageService creates the message service. archive may be nil when
+// cold archiving is disabled.
+func NewMessageService(repo repository.MessageRepository, archive *ArchiveService, publisher MessagePublisher, events *EventRecorder, rules *RuleEngine) *MessageService {
+	return &MessageService{repo: repo, archive: archive, publisher: publisher, events: events, rules: rules}
+}
+
+// Publish stores msg and publishes it to its tenant. A message with a
+// future DeliverAt is stored with a schedule instead, and enqueued by the
+// Scheduler when due.
+func (ms *MessageService) Publish(ctx context.Context, msg *models.Message) error {
+	accepted := models.MessageEvent{Type: models.EventAccepted, OccurredAt: time.Now()}
+	if msg.ContentType == "" {
+		msg.ContentType = "text/plain"
+	}
+	if msg.DeliverAt != nil && msg.DeliverAt.After(time.Now()) {
+		if err := ms.repo.CreateScheduledMessage(ctx, msg); err != nil {
+			return fmt.Errorf("failed to store scheduled message: %w", err)
+		}
+		ms.recordStored(ctx, msg, accepted)
+		ms.events.Record(ctx, messageEvent(msg, models.EventScheduled, models.EventDetails{
+			"deliver_at": msg.DeliverAt.UTC().Format(time.RFC3339),
+		}))
+		metrics.MessageProcessed.WithLabelValues(msg.TenantID, "scheduled").Inc()
+		return nil
+	}
+
+	msg.DeliverAt = nil
+	if err := ms.repo.CreateMessage(ctx, msg); err != nil {
+		return fmt.Errorf("failed to store message: %w", err)
+	}
+	ms.recordStored(ctx, msg, accepted)
+	return ms.Enqueue(ctx, msg)
+}
+
+// recordStored records the acceptance and storage of a newly created
+// message.
+func (ms *MessageService) recordStored(ctx context.Context, msg *models.Message, accepted models.MessageEvent) {
+	accepted.TenantID, accepted.MessageID = msg.TenantID, msg.ID
+	stored := messageEvent(msg, models.EventStored, nil)
+	stored.OccurredAt = msg.CreatedAt
+	ms.events.Record(ctx, accepted, stored)
+}
+
+// Enqueue publishes an already stored message to its tenant, marking
+// it queued, or failed if the broker cannot be reached. A message that
+// expired while waiting, e.g. for its scheduled delivery, is marked expired
+// and dead-lettered, and ErrMessageExpired returned. A message a worker has
+// already picked up is not enqueued again.
+func (ms *MessageService) Enqueue(ctx context.Context, msg *models.Message) error {
+	if msg.Expired(time.Now()) {
+		if err := ms.expire(ctx, msg); err != nil {
+			return err
+		}
+		return ErrMessageExpired
+	}
+
+	// Queued is recorded first, so a fast consumer cannot move the message
+	// on before the publisher's update lands
+	err := ms.repo.Transition(ctx, msg, models.MessageQueued)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		log.Printf("Not enqueueing message %s of tenant %s: it was already picked up or finished", msg.ID, msg.TenantID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ms.publisher.Publish(ctx, msg); err != nil {
+		ms.events.Record(ctx, messageEvent(msg, models.EventPublishFailed, models.EventDetails{"error": err.Error()}))
+		if tErr := ms.repo.Transition(ctx, msg, models.MessageFailed); tErr != nil {
+			log.Printf("Failed to record failed enqueue of message %s: %v", msg.ID, tErr)
+		}
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	ms.events.Record(ctx, messageEvent(msg, models.EventPublished, models.EventDetails{
+		"exchange":    messaging.TenantExchangeName(msg.TenantID),
+		"routing_key": msg.RoutingKey,
+		"confirmed":   "true",
+	}))
+	metrics.MessageProcessed.WithLabelValues(msg.TenantID, "published").Inc()
+	ms.routeByRules(ctx, msg)
+	return nil
 }
 
-func NewMessageService(repo repository.MessageRepository) *MessageService {
-	return &MessageService{repo: repo}
+// routeByRules sends a published message to the subscriptions its tenant's
+// routing rules add. Failures are logged and recorded in the timeline but
+// do not fail the publish, which already reached the tenant's queue.
+func (ms *MessageService) routeByRules(ctx context.Context, msg *models.Message) {
+	if ms.rules == nil {
+		return
+	}
+	targets, err := ms.rules.Route(ctx, msg)
+	if err != nil {
+		log.Printf("Failed to evaluate routing rules for message %s of tenant %s: %v", msg.ID, msg.TenantID, err)
+		return
+	}
+	for _, t := range targets {
+		details := models.EventDetails{"rule": t.Rule, "subscription": t.Subscription}
+		queue := messaging.SubscriptionQueueName(msg.TenantID, t.Subscription)
+		if err := ms.publisher.PublishToQueue(ctx, queue, msg); err != nil {
+			log.Printf("Failed to route message %s to subscription %s: %v", msg.ID, t.Subscription, err)
+			details["error"] = err.Error()
+		}
+		ms.events.Record(ctx, messageEvent(msg, models.EventRouted, details))
+	}
+}
+
+func (ms *MessageService) expire(ctx context.Context, msg *models.Message) error {
+	err := ms.repo.Transition(ctx, msg, models.MessageExpired)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		// Already finished, or expired by the ExpiryJanitor
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	metrics.MessageProcessed.WithLabelValues(msg.TenantID, "expired").Inc()
+	details := models.EventDetails{"reason": "expired"}
+	if err := ms.publisher.DeadLetter(ctx, msg, "expired"); err != nil {
+		log.Printf("Failed to dead-letter expired message %s: %v", msg.ID, err)
+		details["error"] = err.Error()
+	}
+	ms.events.Record(ctx, messageEvent(msg, models.EventExpired, details))
+	return nil
 }
 
 // func (s *MessageService) CreateMessage(ctx context.Context, message *models.Message) error {
@@ -28,8 +171,34 @@ func NewMessageService(repo repository.MessageRepository) *MessageService {
 // 	return s.repo.Delete(ctx, messageID)
 // }
 
-// ListMessages lists messages for a tenant with pagination.
-func (ms *MessageService) ListMessages(ctx context.Context, tenantID, cursor string, limit int) ([]models.Message, string, error) {
+// GetMessage returns a message by ID, falling back to the cold archive when
+// it is no longer in the messages table.
+func (ms *MessageService) GetMessage(ctx context.Context, tenantID, id string) (*models.Message, error) {
+	msg, err := ms.repo.GetMessage(ctx, tenantID, id)
+	if isNotFound(err) && ms.archive != nil {
+		return ms.archive.GetMessage(ctx, tenantID, id)
+	}
+	return msg, err
+}
+
+// Timeline returns a message, which may be archived, and its lifecycle
+// events in the order they occurred.
+func (ms *MessageService) Timeline(ctx context.Context, tenantID, id string) (*models.Message, []models.MessageEvent, error) {
+	msg, err := ms.GetMessage(ctx, tenantID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := ms.events.Timeline(ctx, tenantID, msg.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, events, nil
+}
+
+// ListMessages lists messages for a tenant with pagination, limited to
+// those in status unless it is empty. Archived messages are only listed
+// without a status filter.
+func (ms *MessageService) ListMessages(ctx context.Context, tenantID, cursor, status string, limit int) ([]models.Message, string, error) {
 	// Validate input parameters
 	if limit <= 0 {
 		limit = 10 // Default limit
@@ -39,11 +208,23 @@ func (ms *MessageService) ListMessages(ctx context.Context, tenantID, cursor str
 	}
 
 	// Get messages from repository with pagination
-	messages, _, err := ms.repo.ListMessagesWithCursor(ctx, tenantID, cursor, limit+1) // Fetch one extra for next cursor
+	messages, _, err := ms.repo.ListMessagesWithCursor(ctx, tenantID, cursor, status, limit+1) // Fetch one extra for next cursor
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Archived messages share the same ordering, so merge the next page of
+	// each and let the slicing below pick the overall first limit+1
+	if ms.archive != nil && status == "" {
+		archived, err := ms.archive.ListAfter(ctx, tenantID, cursor, limit+1)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(archived) > 0 {
+			messages = mergeByCursorOrder(archived, messages)
+		}
+	}
+
 	// If we got more items than the limit, set the next cursor
 	var resultMessages []models.Message
 	var resultCursor string
@@ -51,7 +232,7 @@ func (ms *MessageService) ListMessages(ctx context.Context, tenantID, cursor str
 	if len(messages) > limit {
 		resultMessages = messages[:limit]
 		lastMessage := messages[limit-1]
-		resultCursor = lastMessage.ID // Using message ID as cursor
+		resultCursor = repository.EncodeMessageCursor(lastMessage)
 	} else {
 		resultMessages = messages
 		resultCursor = "" // No more messages
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/cron"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+)
+
+// maxOccurrencesPerPass bounds the missed occurrences handled for one
+// schedule per run, so a long outage is worked off over several runs.
+const maxOccurrencesPerPass = 100
+
+// ErrInvalidSchedule is returned for schedules that fail validation.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// OccurrenceData is available to payload templates as ".".
+type OccurrenceData struct {
+	ScheduleID   string
+	ScheduleName string
+	TenantID     string
+	// ScheduledFor is the occurrence time in the schedule's timezone.
+	ScheduledFor time.Time
+	// Occurrence counts published occurrences, starting at 1.
+	Occurrence int
+}
+
+// RecurringScheduler publishes the occurrences of tenants' recurring
+// schedules through the normal publish path.
+type RecurringScheduler struct {
+	repo     repository.RecurringRepository
+	messages *MessageService
+	cfg      config.SchedulerConfig
+}
+
+func NewRecurringScheduler(repo repository.RecurringRepository, messages *MessageService, cfg config.SchedulerConfig) *RecurringScheduler {
+	if cfg.PollIntervalMillis <= 0 {
+		cfg.PollIntervalMillis = 1000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.LeaseSeconds <= 0 {
+		cfg.LeaseSeconds = 30
+	}
+	if cfg.MisfireGraceSeconds <= 0 {
+		cfg.MisfireGraceSeconds = 60
+	}
+	return &RecurringScheduler{repo: repo, messages: messages, cfg: cfg}
+}
+
+// Create validates and stores a new schedule.
+func (s *RecurringScheduler) Create(ctx context.Context, sched *models.RecurringSchedule) error {
+	if err := s.prepare(sched, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return s.repo.Create(ctx, sched)
+}
+
+// Update validates and replaces a schedule's definition. Its next run is
+// recomputed from now.
+func (s *RecurringScheduler) Update(ctx context.Context, sched *models.RecurringSchedule) error {
+	current, err := s.repo.Get(ctx, sched.TenantID, sched.ID)
+	if err != nil {
+		return err
+	}
+	sched.Occurrences = current.Occurrences
+	if err := s.prepare(sched, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return s.repo.Update(ctx, sched)
+}
+
+// Get returns one of the tenant's schedules.
+func (s *RecurringScheduler) Get(ctx context.Context, tenantID, id string) (*models.RecurringSchedule, error) {
+	return s.repo.Get(ctx, tenantID, id)
+}
+
+// List returns the tenant's schedules.
+func (s *RecurringScheduler) List(ctx context.Context, tenantID string) ([]models.RecurringSchedule, error) {
+	return s.repo.List(ctx, tenantID)
+}
+
+// Delete removes a schedule and its history.
+func (s *RecurringScheduler) Delete(ctx context.Context, tenantID, id string) error {
+	return s.repo.Delete(ctx, tenantID, id)
+}
+
+// Runs returns a schedule's most recent runs.
+func (s *RecurringScheduler) Runs(ctx context.Context, tenantID, id string, limit int) ([]models.RecurringRun, error) {
+	if _, err := s.repo.Get(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRuns(ctx, tenantID, id, limit)
+}
+
+// prepare validates sched, fills in defaults and sets its first run at or
+// after now.
+func (s *RecurringScheduler) prepare(sched *models.RecurringSchedule, now time.Time) error {
+	expr, err := cron.Parse(sched.Cron)
+	if err != nil {
+		return err
+	}
+	if sched.Timezone == "" {
+		sched.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		return fmt.Errorf("unknown timezone %q", sched.Timezone)
+	}
+	switch sched.MisfirePolicy {
+	case "":
+		sched.MisfirePolicy = models.MisfireSkip
+	case models.MisfireSkip, models.MisfireCatchUp:
+	default:
+		return fmt.Errorf("invalid misfire policy %q", sched.MisfirePolicy)
+	}
+	if sched.MaxOccurrences < 0 {
+		return errors.New("max_occurrences must not be negative")
+	}
+	if sched.StartAt != nil && sched.EndAt != nil && !sched.EndAt.After(*sched.StartAt) {
+		return errors.New("end_at must be after start_at")
+	}
+	if sched.Template.ContentType == "" {
+		sched.Template.ContentType = "text/plain"
+	}
+	if sched.Template.TTL != "" {
+		if ttl, err := time.ParseDuration(sched.Template.TTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q", sched.Template.TTL)
+		}
+	}
+	if isTemplated(sched.Template) {
+		if _, err := template.New("payload").Parse(string(sched.Template.Payload)); err != nil {
+			return fmt.Errorf("invalid payload template: %w", err)
+		}
+	}
+
+	from := now
+	if sched.StartAt != nil && sched.StartAt.After(from) {
+		// Next is exclusive, so step back to allow an occurrence at StartAt
+		from = sched.StartAt.Add(-time.Nanosecond)
+	}
+	next := expr.Next(from.In(loc))
+	sched.NextRunAt = nil
+	if !next.IsZero() && !s.finished(sched, next) {
+		sched.NextRunAt = &next
+	}
+	return nil
+}
+
+// finished reports whether sched has no occurrence at t or later.
+func (s *RecurringScheduler) finished(sched *models.RecurringSchedule, t time.Time) bool {
+	if sched.MaxOccurrences > 0 && sched.Occurrences >= sched.MaxOccurrences {
+		return true
+	}
+	return sched.EndAt != nil && t.After(*sched.EndAt)
+}
+
+// Run processes due schedules on every poll until ctx is cancelled.
+func (s *RecurringScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.PollIntervalMillis) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(ctx); err != nil {
+				log.Printf("Recurring schedules failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce handles the due occurrences of every schedule.
+func (s *RecurringScheduler) RunOnce(ctx context.Context) error {
+	lease := time.Duration(s.cfg.LeaseSeconds) * time.Second
+	for {
+		due, err := s.repo.ClaimDue(ctx, s.cfg.BatchSize, lease)
+		if err != nil {
+			return err
+		}
+		for i := range due {
+			s.runSchedule(ctx, &due[i])
+		}
+		if len(due) < s.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// runSchedule publishes or skips each due occurrence of a claimed schedule,
+// then moves it to its next occurrence.
+func (s *RecurringScheduler) runSchedule(ctx context.Context, sched *models.RecurringSchedule) {
+	expr, err := cron.Parse(sched.Cron)
+	if err != nil {
+		log.Printf("Recurring schedule %s has an invalid cron expression: %v", sched.ID, err)
+		return
+	}
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		log.Printf("Recurring schedule %s has an invalid timezone: %v", sched.ID, err)
+		return
+	}
+
+	now := time.Now()
+	grace := time.Duration(s.cfg.MisfireGraceSeconds) * time.Second
+	next := sched.NextRunAt.In(loc)
+	for i := 0; i < maxOccurrencesPerPass && !next.IsZero() && !next.After(now); i++ {
+		if s.finished(sched, next) {
+			next = time.Time{}
+			break
+		}
+
+		run := &models.RecurringRun{ScheduleID: sched.ID, TenantID: sched.TenantID, ScheduledFor: next}
+		if sched.MisfirePolicy == models.MisfireSkip && now.Sub(next) > grace {
+			run.Status = models.RunSkipped
+		} else {
+			msg, err := s.publish(ctx, sched, next)
+			if err != nil {
+				run.Status = models.RunFailed
+				run.Error = err.Error()
+				log.Printf("Recurring schedule %s failed for %s: %v", sched.ID, next.Format(time.RFC3339), err)
+			} else {
+				run.Status = models.RunPublished
+				run.MessageID = msg.ID
+				sched.Occurrences++
+			}
+		}
+		if err := s.repo.RecordRun(ctx, run); err != nil {
+			log.Printf("Failed to record run of recurring schedule %s: %v", sched.ID, err)
+		}
+		ranAt := now
+		sched.LastRunAt = &ranAt
+		next = expr.Next(next)
+	}
+
+	sched.NextRunAt = nil
+	if !next.IsZero() && !s.finished(sched, next) {
+		sched.NextRunAt = &next
+	}
+	if err := s.repo.Advance(ctx, sched); err != nil {
+		log.Printf("Failed to advance recurring schedule %s: %v", sched.ID, err)
+	}
+}
+
+// publish builds the occurrence's message from the template and publishes
+// it. The idempotency key identifies the occurrence, so consumers with the
+// inbox enabled drop a re-publish after a crash.
+func (s *RecurringScheduler) publish(ctx context.Context, sched *models.RecurringSchedule, scheduledFor time.Time) (*models.Message, error) {
+	tmpl := sched.Template
+	payload := tmpl.Payload
+	if isTemplated(tmpl) {
+		t, err := template.New("payload").Option("missingkey=error").Parse(string(tmpl.Payload))
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload template: %w", err)
+		}
+		var buf bytes.Buffer
+		err = t.Execute(&buf, OccurrenceData{
+			ScheduleID:   sched.ID,
+			ScheduleName: sched.Name,
+			TenantID:     sched.TenantID,
+			ScheduledFor: scheduledFor,
+			Occurrence:   sched.Occurrences + 1,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render payload: %w", err)
+		}
+		payload = buf.Bytes()
+	}
+
+	msg := &models.Message{
+		TenantID:        sched.TenantID,
+		Payload:         payload,
+		ContentType:     tmpl.ContentType,
+		ContentEncoding: tmpl.ContentEncoding,
+		CorrelationID:   tmpl.CorrelationID,
+		CausationID:     tmpl.CausationID,
+		RoutingKey:      tmpl.RoutingKey,
+		OrderingKey:     tmpl.OrderingKey,
+		Priority:        tmpl.Priority,
+		IdempotencyKey:  fmt.Sprintf("schedule:%s:%d", sched.ID, scheduledFor.Unix()),
+		Headers:         tmpl.Headers,
+		Attributes:      tmpl.Attributes,
+	}
+	if tmpl.TTL != "" {
+		ttl, err := time.ParseDuration(tmpl.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl: %w", err)
+		}
+		expiresAt := scheduledFor.Add(ttl)
+		msg.ExpiresAt = &expiresAt
+	}
+	if err := s.messages.Publish(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// isTemplated reports whether the template's payload is rendered with
+// text/template: it must be uncompressed text or JSON containing an action.
+func isTemplated(tmpl models.MessageTemplate) bool {
+	if tmpl.ContentEncoding != "" && tmpl.ContentEncoding != "identity" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(tmpl.ContentType)
+	if err != nil {
+		return false
+	}
+	textual := strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	return textual && bytes.Contains(tmpl.Payload, []byte("{{"))
+}
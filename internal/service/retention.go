@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+)
+
+// RetentionRunStatus describes the most recent retention janitor run. A run
+// is skipped while another server instance is running.
+type RetentionRunStatus struct {
+	Running    bool                    `json:"running"`
+	Skipped    bool                    `json:"skipped,omitempty"`
+	StartedAt  time.Time               `json:"started_at"`
+	FinishedAt time.Time               `json:"finished_at,omitempty"`
+	Tenants    []TenantRetentionResult `json:"tenants"`
+	Error      string                  `json:"error,omitempty"`
+}
+
+// TenantRetentionResult is the outcome of applying one tenant's policy.
+type TenantRetentionResult struct {
+	TenantID string `json:"tenant_id"`
+	Action   string `json:"action"`
+	Removed  int64  `json:"removed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RetentionJanitor periodically enforces every tenant's retention policy.
+type RetentionJanitor struct {
+	tenants   repository.TenantRepository
+	retention repository.RetentionRepository
+	archive   *ArchiveService
+	cfg       config.RetentionConfig
+
+	mu   sync.Mutex
+	last RetentionRunStatus
+}
+
+// NewRetentionJanitor creates the janitor. archive may be nil when cold
+// archiving is disabled, in which case the archive action fails.
+func NewRetentionJanitor(tenants repository.TenantRepository, retention repository.RetentionRepository, archive *ArchiveService, cfg config.RetentionConfig) *RetentionJanitor {
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 600
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	return &RetentionJanitor{tenants: tenants, retention: retention, archive: archive, cfg: cfg}
+}
+
+// Run purges expired messages on every interval until ctx is cancelled.
+func (j *RetentionJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(j.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce applies every tenant's retention policy and records the outcome.
+// It is skipped if another server instance is already running.
+func (j *RetentionJanitor) RunOnce(ctx context.Context) RetentionRunStatus {
+	status := RetentionRunStatus{Running: true, StartedAt: time.Now().UTC()}
+	j.setStatus(status)
+
+	unlock, locked, err := j.retention.TryLock(ctx)
+	if err != nil || !locked {
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Skipped = err == nil
+		status.Running = false
+		status.FinishedAt = time.Now().UTC()
+		j.setStatus(status)
+		return status
+	}
+	defer unlock()
+
+	tenants, err := j.tenants.ListTenants(ctx)
+	if err != nil {
+		status.Error = err.Error()
+	}
+	for _, tenant := range tenants {
+		policy := tenant.Config.Retention
+		if policy == nil || (policy.MaxAgeDays == 0 && policy.MaxCount == 0) {
+			continue
+		}
+		result := j.applyPolicy(ctx, tenant.ID, policy)
+		if result.Error != "" {
+			log.Printf("Retention failed for tenant %s: %s", tenant.ID, result.Error)
+		}
+		status.Tenants = append(status.Tenants, result)
+	}
+
+	status.Running = false
+	status.FinishedAt = time.Now().UTC()
+	j.setStatus(status)
+	metrics.RetentionLastRun.Set(float64(status.FinishedAt.Unix()))
+	return status
+}
+
+// Status returns the most recent run, or the one in progress, with only
+// the given tenant's result.
+func (j *RetentionJanitor) Status(tenantID string) RetentionRunStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.last
+	status.Tenants = nil
+	for _, result := range j.last.Tenants {
+		if result.TenantID == tenantID {
+			status.Tenants = append(status.Tenants, result)
+		}
+	}
+	return status
+}
+
+func (j *RetentionJanitor) setStatus(status RetentionRunStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.last = status
+}
+
+func (j *RetentionJanitor) applyPolicy(ctx context.Context, tenantID string, policy *models.RetentionPolicy) TenantRetentionResult {
+	action := policy.Action
+	if action == "" {
+		action = "delete"
+	}
+	archive := action == "archive"
+	result := TenantRetentionResult{TenantID: tenantID, Action: action}
+	if archive && j.archive == nil {
+		result.Error = "archiving is disabled"
+		return result
+	}
+
+	if policy.MaxAgeDays > 0 {
+		cutoff := time.Now().UTC().AddDate(0, 0, -policy.MaxAgeDays)
+		var removed int64
+		var err error
+		if archive {
+			removed, err = j.archiveBefore(ctx, tenantID, repository.KeyAt(cutoff))
+		} else {
+			removed, err = j.inBatches(ctx, tenantID, action, func(after *repository.MessageKey, limit int) (int64, *repository.MessageKey, error) {
+				return j.retention.PurgeOlderThan(ctx, tenantID, cutoff, after, limit)
+			})
+		}
+		result.Removed += removed
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	if policy.MaxCount > 0 {
+		// Messages older than the oldest one kept are purged, so batches
+		// need not count the kept ones again
+		boundary, err := j.retention.KeepBoundary(ctx, tenantID, policy.MaxCount)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		if boundary == nil {
+			return result
+		}
+		var removed int64
+		if archive {
+			removed, err = j.archiveBefore(ctx, tenantID, *boundary)
+		} else {
+			removed, err = j.inBatches(ctx, tenantID, action, func(after *repository.MessageKey, limit int) (int64, *repository.MessageKey, error) {
+				return j.retention.PurgeBefore(ctx, tenantID, *boundary, after, limit)
+			})
+		}
+		result.Removed += removed
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// archiveBefore moves the tenant's messages ordered before the key before
+// into cold archive segments, where listings, lookups and restores still
+// find them.
+func (j *RetentionJanitor) archiveBefore(ctx context.Context, tenantID string, before repository.MessageKey) (int64, error) {
+	n, err := j.archive.ArchiveBefore(ctx, tenantID, before)
+	metrics.RetentionPurged.WithLabelValues(tenantID, "archive").Add(float64(n))
+	return n, err
+}
+
+// inBatches calls purge until a batch comes back short, pausing in between.
+// Each batch continues after the last message of the one before.
+func (j *RetentionJanitor) inBatches(ctx context.Context, tenantID, action string, purge func(after *repository.MessageKey, limit int) (int64, *repository.MessageKey, error)) (int64, error) {
+	var total int64
+	var after *repository.MessageKey
+	for {
+		n, last, err := purge(after, j.cfg.BatchSize)
+		after = last
+		total += n
+		metrics.RetentionPurged.WithLabelValues(tenantID, action).Add(float64(n))
+		if err != nil || n < int64(j.cfg.BatchSize) {
+			return total, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(time.Duration(j.cfg.BatchPauseMillis) * time.Millisecond):
+		}
+	}
+}
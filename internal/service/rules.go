@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/abiewardani/go-messaging-system/internal/messaging"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+	"github.com/abiewardani/go-messaging-system/internal/rules"
+	"github.com/abiewardani/go-messaging-system/pkg/metrics"
+)
+
+// ErrInvalidRules is returned for rule sets that fail validation.
+var ErrInvalidRules = errors.New("invalid rules")
+
+// RuleResult is the outcome of evaluating one routing rule against a
+// message.
+type RuleResult struct {
+	Rule         string `json:"rule"`
+	Subscription string `json:"subscription"`
+	Matched      bool   `json:"matched"`
+	// Skipped is set for the rules after a matching rule that stops
+	// evaluation.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// RuleTestResult shows how a message would be routed.
+type RuleTestResult struct {
+	// Rules lists every rule in evaluation order.
+	Rules []RuleResult `json:"rules"`
+	// PatternSubscriptions receive the message by its routing key.
+	PatternSubscriptions []string `json:"pattern_subscriptions"`
+	// RuleSubscriptions receive the message because a rule matched it.
+	RuleSubscriptions []string `json:"rule_subscriptions"`
+}
+
+// RuleEngine routes published messages to subscriptions by their tenant's
+// content-based rules.
+type RuleEngine struct {
+	tenants repository.TenantRepository
+}
+
+func NewRuleEngine(tenants repository.TenantRepository) *RuleEngine {
+	return &RuleEngine{tenants: tenants}
+}
+
+// ValidateRules checks a tenant's rule set: names are unique, conditions
+// compile and every rule's subscription exists.
+func ValidateRules(rs []models.RoutingRule, cfg models.TenantConfig) error {
+	names := make(map[string]bool, len(rs))
+	for i := range rs {
+		r := &rs[i]
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRules, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("%w: duplicate rule %q", ErrInvalidRules, r.Name)
+		}
+		names[r.Name] = true
+		if _, err := rules.Compile(r.Condition); err != nil {
+			return fmt.Errorf("%w: rule %q: %v", ErrInvalidRules, r.Name, err)
+		}
+		if cfg.FindSubscription(r.Subscription) < 0 {
+			return fmt.Errorf("%w: rule %q: unknown subscription %q", ErrInvalidRules, r.Name, r.Subscription)
+		}
+	}
+	return nil
+}
+
+// Evaluate evaluates rs against msg in evaluation order.
+func Evaluate(rs []models.RoutingRule, msg *models.Message) ([]RuleResult, error) {
+	env := rules.MessageEnv(msg)
+	sorted := models.SortRules(rs)
+	results := make([]RuleResult, len(sorted))
+	stopped := false
+	for i, r := range sorted {
+		results[i] = RuleResult{Rule: r.Name, Subscription: r.Subscription}
+		if stopped {
+			results[i].Skipped = true
+			continue
+		}
+		cond, err := rules.Compile(r.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		results[i].Matched = cond.Match(env)
+		stopped = results[i].Matched && r.Stop
+	}
+	return results, nil
+}
+
+// Route returns the matching rules that send msg to a subscription its
+// routing key does not already reach, one per subscription, and counts the
+// tenant's rule hits.
+func (e *RuleEngine) Route(ctx context.Context, msg *models.Message) ([]RuleResult, error) {
+	tenant, err := e.tenants.GetTenantByID(ctx, msg.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load routing rules: %w", err)
+	}
+	if len(tenant.Config.Rules) == 0 {
+		return nil, nil
+	}
+	results, err := Evaluate(tenant.Config.Rules, msg)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if res.Matched {
+			metrics.RuleHits.WithLabelValues(msg.TenantID, res.Rule).Inc()
+		}
+	}
+	return ruleTargets(tenant.Config, results, msg.RoutingKey), nil
+}
+
+// Test evaluates rs, or the tenant's stored rules if rs is nil, against
+// msg without routing it.
+func (e *RuleEngine) Test(ctx context.Context, msg *models.Message, rs []models.RoutingRule) (*RuleTestResult, error) {
+	tenant, err := e.tenants.GetTenantByID(ctx, msg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if rs == nil {
+		rs = tenant.Config.Rules
+	} else if err := ValidateRules(rs, tenant.Config); err != nil {
+		return nil, err
+	}
+	results, err := Evaluate(rs, msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+
+	test := &RuleTestResult{
+		Rules:                results,
+		PatternSubscriptions: []string{},
+		RuleSubscriptions:    []string{},
+	}
+	for _, sub := range tenant.Config.Subscriptions {
+		if matchesPatterns(sub, msg.RoutingKey) {
+			test.PatternSubscriptions = append(test.PatternSubscriptions, sub.Name)
+		}
+	}
+	for _, res := range ruleTargets(tenant.Config, results, msg.RoutingKey) {
+		test.RuleSubscriptions = append(test.RuleSubscriptions, res.Subscription)
+	}
+	return test, nil
+}
+
+// ruleTargets picks the matched results whose subscription exists and does
+// not receive the message by routing key, keeping the first per
+// subscription.
+func ruleTargets(cfg models.TenantConfig, results []RuleResult, routingKey string) []RuleResult {
+	var targets []RuleResult
+	seen := make(map[string]bool)
+	for _, res := range results {
+		if !res.Matched || seen[res.Subscription] {
+			continue
+		}
+		seen[res.Subscription] = true
+		i := cfg.FindSubscription(res.Subscription)
+		if i < 0 || matchesPatterns(cfg.Subscriptions[i], routingKey) {
+			continue
+		}
+		targets = append(targets, res)
+	}
+	return targets
+}
+
+func matchesPatterns(sub models.Subscription, routingKey string) bool {
+	for _, p := range sub.Patterns {
+		if messaging.TopicMatches(p, routingKey) {
+			return true
+		}
+	}
+	return false
+}
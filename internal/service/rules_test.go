@@ -0,0 +1,160 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	msg := &models.Message{RoutingKey: "orders.created", Attributes: models.Attributes{"region": "EU"}}
+	eu := "attributes.region == 'EU'"
+	us := "attributes.region == 'US'"
+
+	tests := []struct {
+		name  string
+		rules []models.RoutingRule
+		want  []RuleResult
+	}{
+		{
+			name: "priority order, listed order on ties",
+			rules: []models.RoutingRule{
+				{Name: "low", Condition: eu, Subscription: "a"},
+				{Name: "high", Priority: 10, Condition: us, Subscription: "b"},
+				{Name: "low-2", Condition: eu, Subscription: "c"},
+			},
+			want: []RuleResult{
+				{Rule: "high", Subscription: "b"},
+				{Rule: "low", Subscription: "a", Matched: true},
+				{Rule: "low-2", Subscription: "c", Matched: true},
+			},
+		},
+		{
+			name: "matching stop rule skips the rest",
+			rules: []models.RoutingRule{
+				{Name: "first", Priority: 2, Condition: eu, Subscription: "a", Stop: true},
+				{Name: "second", Priority: 1, Condition: eu, Subscription: "b"},
+				{Name: "third", Condition: eu, Subscription: "c", Stop: true},
+			},
+			want: []RuleResult{
+				{Rule: "first", Subscription: "a", Matched: true},
+				{Rule: "second", Subscription: "b", Skipped: true},
+				{Rule: "third", Subscription: "c", Skipped: true},
+			},
+		},
+		{
+			name: "stop rule that does not match continues",
+			rules: []models.RoutingRule{
+				{Name: "first", Priority: 1, Condition: us, Subscription: "a", Stop: true},
+				{Name: "second", Condition: eu, Subscription: "b"},
+			},
+			want: []RuleResult{
+				{Rule: "first", Subscription: "a"},
+				{Rule: "second", Subscription: "b", Matched: true},
+			},
+		},
+		{
+			name: "skipped rules are not compiled",
+			rules: []models.RoutingRule{
+				{Name: "first", Priority: 1, Condition: eu, Subscription: "a", Stop: true},
+				{Name: "broken", Condition: "attributes.region ==", Subscription: "b"},
+			},
+			want: []RuleResult{
+				{Rule: "first", Subscription: "a", Matched: true},
+				{Rule: "broken", Subscription: "b", Skipped: true},
+			},
+		},
+		{
+			name:  "no rules",
+			rules: nil,
+			want:  []RuleResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Evaluate(tt.rules, msg)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateInvalidCondition(t *testing.T) {
+	rules := []models.RoutingRule{{Name: "broken", Condition: "region == 'EU'", Subscription: "a"}}
+	if _, err := Evaluate(rules, &models.Message{}); err == nil {
+		t.Fatal("Evaluate succeeded with an invalid condition")
+	}
+}
+
+func TestRuleTargets(t *testing.T) {
+	cfg := models.TenantConfig{Subscriptions: []models.Subscription{
+		{Name: "orders", Patterns: []string{"orders.*"}},
+		{Name: "billing", Patterns: []string{"billing.#"}},
+		{Name: "audit", Patterns: []string{"#"}},
+		{Name: "eu", Patterns: []string{"eu.orders.*"}},
+	}}
+	matched := func(rule, sub string) RuleResult {
+		return RuleResult{Rule: rule, Subscription: sub, Matched: true}
+	}
+
+	tests := []struct {
+		name       string
+		results    []RuleResult
+		routingKey string
+		want       []RuleResult
+	}{
+		{
+			name:       "pattern subscriptions are not targeted again",
+			results:    []RuleResult{matched("r1", "orders"), matched("r2", "audit"), matched("r3", "billing")},
+			routingKey: "orders.created",
+			want:       []RuleResult{matched("r3", "billing")},
+		},
+		{
+			name:       "first matching rule per subscription",
+			results:    []RuleResult{matched("r1", "billing"), matched("r2", "eu"), matched("r3", "billing")},
+			routingKey: "orders.created",
+			want:       []RuleResult{matched("r1", "billing"), matched("r2", "eu")},
+		},
+		{
+			name: "unmatched and skipped rules are ignored",
+			results: []RuleResult{
+				{Rule: "r1", Subscription: "billing"},
+				{Rule: "r2", Subscription: "eu", Skipped: true},
+				matched("r3", "billing"),
+			},
+			routingKey: "orders.created",
+			want:       []RuleResult{matched("r3", "billing")},
+		},
+		{
+			name:       "removed subscriptions are ignored",
+			results:    []RuleResult{matched("r1", "gone"), matched("r2", "billing")},
+			routingKey: "orders.created",
+			want:       []RuleResult{matched("r2", "billing")},
+		},
+		{
+			name:       "empty routing key matches only #",
+			results:    []RuleResult{matched("r1", "orders"), matched("r2", "audit")},
+			routingKey: "",
+			want:       []RuleResult{matched("r1", "orders")},
+		},
+		{
+			name:       "nothing matched",
+			results:    []RuleResult{{Rule: "r1", Subscription: "billing"}},
+			routingKey: "orders.created",
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ruleTargets(cfg, tt.results, tt.routingKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ruleTargets = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/abiewardani/go-messaging-system/internal/config"
+	"github.com/abiewardani/go-messaging-system/internal/models"
+	"github.com/abiewardani/go-messaging-system/internal/repository"
+)
+
+// Scheduler enqueues scheduled messages into their tenant's queue once
+// they are due.
+type Scheduler struct {
+	repo     repository.ScheduleRepository
+	messages *MessageService
+	cfg      config.SchedulerConfig
+}
+
+func NewScheduler(repo repository.ScheduleRepository, messages *MessageService, cfg config.SchedulerConfig) *Scheduler {
+	if cfg.PollIntervalMillis <= 0 {
+		cfg.PollIntervalMillis = 1000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.LeaseSeconds <= 0 {
+		cfg.LeaseSeconds = 30
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	return &Scheduler{repo: repo, messages: messages, cfg: cfg}
+}
+
+// Run delivers due messages on every poll until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.PollIntervalMillis) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(ctx); err != nil {
+				log.Printf("Scheduled delivery failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce delivers every message due now. A message is enqueued before its
+// schedule is marked delivered, so a crash in between can deliver it twice
+// but never loses it.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	lease := time.Duration(s.cfg.LeaseSeconds) * time.Second
+	for {
+		due, err := s.repo.ClaimDue(ctx, s.cfg.BatchSize, lease)
+		if err != nil {
+			return err
+		}
+		for _, scheduled := range due {
+			s.deliver(ctx, scheduled)
+		}
+		if len(due) < s.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Scheduler) deliver(ctx context.Context, scheduled models.ScheduledMessage) {
+	msg, err := s.messages.GetMessage(ctx, scheduled.TenantID, scheduled.MessageID)
+	if err == nil {
+		err = s.messages.Enqueue(ctx, msg)
+	}
+	if errors.Is(err, ErrMessageExpired) {
+		// Expired while waiting; Enqueue has dead-lettered it
+		if markErr := s.repo.MarkFailed(ctx, scheduled.TenantID, scheduled.MessageID, err.Error(), 0); markErr != nil {
+			log.Printf("Failed to record scheduled message %s: %v", scheduled.MessageID, markErr)
+		}
+		return
+	}
+	if isNotFound(err) {
+		// Purged or erased before it was due; nothing left to deliver
+		err = errors.New("message no longer exists")
+		if markErr := s.repo.MarkFailed(ctx, scheduled.TenantID, scheduled.MessageID, err.Error(), 0); markErr != nil {
+			log.Printf("Failed to record scheduled message %s: %v", scheduled.MessageID, markErr)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to deliver scheduled message %s of tenant %s: %v", scheduled.MessageID, scheduled.TenantID, err)
+		if markErr := s.repo.MarkFailed(ctx, scheduled.TenantID, scheduled.MessageID, err.Error(), s.cfg.MaxAttempts); markErr != nil {
+			log.Printf("Failed to record scheduled message %s: %v", scheduled.MessageID, markErr)
+		}
+		return
+	}
+	if err := s.repo.MarkDelivered(ctx, scheduled.TenantID, scheduled.MessageID); err != nil {
+		log.Printf("Failed to record scheduled message %s: %v", scheduled.MessageID, err)
+	}
+}
+
+// List returns the tenant's scheduled messages after cursor, and the cursor
+// of the next page if there is one.
+func (s *Scheduler) List(ctx context.Context, tenantID, status, cursor string, limit int) ([]models.ScheduledMessage, string, error) {
+	scheduled, err := s.repo.List(ctx, tenantID, status, cursor, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(scheduled) <= limit {
+		return scheduled, "", nil
+	}
+	last := scheduled[limit-1]
+	return scheduled[:limit], repository.EncodeCursor(last.DeliverAt, last.MessageID), nil
+}
+
+// Reschedule moves a pending message's delivery time.
+func (s *Scheduler) Reschedule(ctx context.Context, tenantID, messageID string, deliverAt time.Time) (*models.ScheduledMessage, error) {
+	return s.repo.Reschedule(ctx, tenantID, messageID, deliverAt)
+}
+
+// Cancel stops a pending message from being delivered.
+func (s *Scheduler) Cancel(ctx context.Context, tenantID, messageID string) (*models.ScheduledMessage, error) {
+	return s.repo.Cancel(ctx, tenantID, messageID)
+}
+
+// Get returns one of the tenant's scheduled messages.
+func (s *Scheduler) Get(ctx context.Context, tenantID, messageID string) (*models.ScheduledMessage, error) {
+	return s.repo.Get(ctx, tenantID, messageID)
+}
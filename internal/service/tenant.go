@@ -19,14 +19,20 @@ func (s *TenantService) CreateTenant(ctx context.Context, tenant *models.Tenant)
 	return s.repo.Create(ctx, tenant)
 }
 
-// func (s *TenantService) GetTenantByID(ctx context.Context, id string) (*models.Tenant, error) {
-// 	return s.repo.FindByID(ctx, id)
-// }
+func (s *TenantService) GetTenantByID(ctx context.Context, id string) (*models.Tenant, error) {
+	return s.repo.GetTenantByID(ctx, id)
+}
 
-// func (s *TenantService) UpdateTenant(ctx context.Context, tenant *models.Tenant) error {
-// 	return s.repo.Update(ctx, tenant)
-// }
+func (s *TenantService) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	return s.repo.ListTenants(ctx)
+}
 
-// func (s *TenantService) DeleteTenant(ctx context.Context, id string) error {
-// 	return s.repo.Delete(ctx, id)
-// }
+// UpdateConfig applies fn to the tenant's stored config and saves the
+// result. Concurrent updates are applied one after the other.
+func (s *TenantService) UpdateConfig(ctx context.Context, id string, fn func(*models.TenantConfig) error) (*models.Tenant, error) {
+	return s.repo.UpdateConfig(ctx, id, fn)
+}
+
+func (s *TenantService) DeleteTenant(ctx context.Context, id string) error {
+	return s.repo.DeleteTenant(ctx, id)
+}
@@ -0,0 +1,305 @@
+// Package transform applies declarative transformation pipelines to
+// message payloads.
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+// MaxSteps bounds the steps of a pipeline.
+const MaxSteps = 50
+
+// ErrUnsupportedPayload is returned for payloads that are neither
+// uncompressed JSON objects nor form-encoded.
+var ErrUnsupportedPayload = errors.New("payload is not a JSON object or form data")
+
+const formContentType = "application/x-www-form-urlencoded"
+
+// Pipeline is a validated sequence of transformation steps.
+type Pipeline struct {
+	steps  []models.TransformStep
+	format string
+}
+
+// Compile validates steps.
+func Compile(steps []models.TransformStep) (*Pipeline, error) {
+	if len(steps) > MaxSteps {
+		return nil, fmt.Errorf("pipeline has more than %d steps", MaxSteps)
+	}
+	p := &Pipeline{}
+	for i, step := range steps {
+		if err := validateStep(step); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if step.Type == models.StepFormat {
+			if i != len(steps)-1 {
+				return nil, fmt.Errorf("step %d: format must be the last step", i+1)
+			}
+			p.format = step.Format
+			continue
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+func validateStep(step models.TransformStep) error {
+	switch step.Type {
+	case models.StepRename:
+		if err := validatePaths(step.From, step.To); err != nil {
+			return err
+		}
+		if step.From == step.To {
+			return errors.New("rename needs different from and to fields")
+		}
+	case models.StepDrop:
+		if len(step.Fields) == 0 {
+			return errors.New("drop needs fields")
+		}
+		return validatePaths(step.Fields...)
+	case models.StepSet:
+		return validatePaths(step.Field)
+	case models.StepLookup:
+		if err := validatePaths(step.From, step.To); err != nil {
+			return err
+		}
+		if len(step.Table) == 0 {
+			return errors.New("lookup needs a table")
+		}
+	case models.StepFormat:
+		if step.Format != models.FormatJSON && step.Format != models.FormatForm {
+			return fmt.Errorf("unknown format %q", step.Format)
+		}
+	default:
+		return fmt.Errorf("unknown step type %q", step.Type)
+	}
+	return nil
+}
+
+func validatePaths(paths ...string) error {
+	for _, path := range paths {
+		if path == "" {
+			return errors.New("missing field")
+		}
+		for _, seg := range strings.Split(path, ".") {
+			if seg == "" {
+				return fmt.Errorf("field %q has an empty segment", path)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply rewrites msg's payload and content type. The payload keeps its
+// format unless the pipeline ends with a format step.
+func (p *Pipeline) Apply(msg *models.Message) error {
+	doc, format, err := decode(msg)
+	if err != nil {
+		return err
+	}
+	for _, step := range p.steps {
+		applyStep(doc, step)
+	}
+	if p.format != "" {
+		format = p.format
+	}
+	return encode(msg, doc, format)
+}
+
+func applyStep(doc map[string]interface{}, step models.TransformStep) {
+	switch step.Type {
+	case models.StepRename:
+		if v, ok := remove(doc, step.From); ok {
+			set(doc, step.To, v)
+		}
+	case models.StepDrop:
+		for _, field := range step.Fields {
+			remove(doc, field)
+		}
+	case models.StepSet:
+		set(doc, step.Field, clone(step.Value))
+	case models.StepLookup:
+		v, ok := get(doc, step.From)
+		if !ok {
+			return
+		}
+		if found, ok := step.Table[lookupKey(v)]; ok {
+			set(doc, step.To, clone(found))
+		} else if step.Default != nil {
+			set(doc, step.To, clone(step.Default))
+		}
+	}
+}
+
+// clone deep-copies a value from a step, so later steps cannot modify the
+// pipeline through the document.
+func clone(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = clone(item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = clone(item)
+		}
+		return list
+	}
+	return v
+}
+
+// lookupKey returns the table key of a looked-up value.
+func lookupKey(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "null"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func get(doc map[string]interface{}, path string) (interface{}, bool) {
+	segs := strings.Split(path, ".")
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := doc[seg].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		doc = next
+	}
+	v, ok := doc[segs[len(segs)-1]]
+	return v, ok
+}
+
+func remove(doc map[string]interface{}, path string) (interface{}, bool) {
+	segs := strings.Split(path, ".")
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := doc[seg].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		doc = next
+	}
+	last := segs[len(segs)-1]
+	v, ok := doc[last]
+	delete(doc, last)
+	return v, ok
+}
+
+// set stores v at path, creating or replacing intermediate objects.
+func set(doc map[string]interface{}, path string, v interface{}) {
+	segs := strings.Split(path, ".")
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := doc[seg].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			doc[seg] = next
+		}
+		doc = next
+	}
+	doc[segs[len(segs)-1]] = v
+}
+
+func decode(msg *models.Message) (map[string]interface{}, string, error) {
+	if msg.ContentEncoding != "" && msg.ContentEncoding != "identity" {
+		return nil, "", ErrUnsupportedPayload
+	}
+	mediaType, _, err := mime.ParseMediaType(msg.ContentType)
+	if err != nil {
+		return nil, "", ErrUnsupportedPayload
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var doc map[string]interface{}
+		if err := json.Unmarshal(msg.Payload, &doc); err != nil || doc == nil {
+			return nil, "", ErrUnsupportedPayload
+		}
+		return doc, models.FormatJSON, nil
+	case mediaType == formContentType:
+		values, err := url.ParseQuery(string(msg.Payload))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid form payload: %w", err)
+		}
+		doc := make(map[string]interface{}, len(values))
+		for k, vs := range values {
+			if len(vs) == 1 {
+				doc[k] = vs[0]
+				continue
+			}
+			list := make([]interface{}, len(vs))
+			for i, v := range vs {
+				list[i] = v
+			}
+			doc[k] = list
+		}
+		return doc, models.FormatForm, nil
+	}
+	return nil, "", ErrUnsupportedPayload
+}
+
+func encode(msg *models.Message, doc map[string]interface{}, format string) error {
+	if format == models.FormatForm {
+		values := url.Values{}
+		flatten(values, "", doc)
+		msg.Payload = []byte(values.Encode())
+		msg.ContentType = formContentType
+		return nil
+	}
+
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	msg.Payload = payload
+	if mediaType, _, _ := mime.ParseMediaType(msg.ContentType); mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		msg.ContentType = "application/json"
+	}
+	return nil
+}
+
+// flatten adds the values of doc to values, naming nested fields by their
+// dot-separated path and repeating the key for each list item.
+func flatten(values url.Values, prefix string, doc map[string]interface{}) {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		addFormValue(values, prefix+k, doc[k])
+	}
+}
+
+func addFormValue(values url.Values, key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		flatten(values, key+".", v)
+	case []interface{}:
+		for _, item := range v {
+			addFormValue(values, key, item)
+		}
+	case string:
+		values.Add(key, v)
+	case nil:
+		values.Add(key, "")
+	default:
+		values.Add(key, lookupKey(v))
+	}
+}
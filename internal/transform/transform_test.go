@@ -0,0 +1,266 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abiewardani/go-messaging-system/internal/models"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []models.TransformStep
+		want  string
+	}{
+		{"unknown type", []models.TransformStep{{Type: "upper"}}, `step 1: unknown step type "upper"`},
+		{"rename without to", []models.TransformStep{{Type: models.StepRename, From: "a"}}, "step 1: missing field"},
+		{"rename to itself", []models.TransformStep{{Type: models.StepRename, From: "a", To: "a"}}, "different from and to"},
+		{"drop without fields", []models.TransformStep{{Type: models.StepDrop}}, "drop needs fields"},
+		{"empty segment", []models.TransformStep{{Type: models.StepDrop, Fields: []string{"a..b"}}}, `field "a..b" has an empty segment`},
+		{"trailing dot", []models.TransformStep{{Type: models.StepSet, Field: "a."}}, `field "a." has an empty segment`},
+		{"lookup without table", []models.TransformStep{{Type: models.StepLookup, From: "a", To: "b"}}, "lookup needs a table"},
+		{"unknown format", []models.TransformStep{{Type: models.StepFormat, Format: "xml"}}, `unknown format "xml"`},
+		{"format not last", []models.TransformStep{
+			{Type: models.StepFormat, Format: models.FormatForm},
+			{Type: models.StepDrop, Fields: []string{"a"}},
+		}, "step 1: format must be the last step"},
+		{"later step", []models.TransformStep{
+			{Type: models.StepDrop, Fields: []string{"a"}},
+			{Type: models.StepSet},
+		}, "step 2: missing field"},
+		{"too many steps", make([]models.TransformStep, MaxSteps+1), "more than 50 steps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.steps)
+			if err == nil {
+				t.Fatalf("Compile succeeded, want error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []models.TransformStep
+		contentType string
+		payload     string
+		wantType    string
+		want        string
+	}{
+		{
+			name:     "no steps",
+			payload:  `{"a": 1}`,
+			wantType: "application/json",
+			want:     `{"a": 1}`,
+		},
+		{
+			name:     "rename into a new object",
+			steps:    []models.TransformStep{{Type: models.StepRename, From: "customer_id", To: "customer.id"}},
+			payload:  `{"customer_id": "c-42", "amount": 10}`,
+			wantType: "application/json",
+			want:     `{"customer": {"id": "c-42"}, "amount": 10}`,
+		},
+		{
+			name:     "rename of a missing field",
+			steps:    []models.TransformStep{{Type: models.StepRename, From: "missing", To: "b"}},
+			payload:  `{"a": 1}`,
+			wantType: "application/json",
+			want:     `{"a": 1}`,
+		},
+		{
+			name:     "drop nested and missing fields",
+			steps:    []models.TransformStep{{Type: models.StepDrop, Fields: []string{"card.number", "missing", "a.b.c"}}},
+			payload:  `{"card": {"number": "4111", "brand": "visa"}, "a": 1}`,
+			wantType: "application/json",
+			want:     `{"card": {"brand": "visa"}, "a": 1}`,
+		},
+		{
+			name:     "set replaces scalars on the path",
+			steps:    []models.TransformStep{{Type: models.StepSet, Field: "meta.source", Value: "api"}},
+			payload:  `{"meta": "old"}`,
+			wantType: "application/json",
+			want:     `{"meta": {"source": "api"}}`,
+		},
+		{
+			name: "lookup by string and number",
+			steps: []models.TransformStep{
+				{Type: models.StepLookup, From: "country", To: "region", Table: map[string]interface{}{"DE": "EU"}},
+				{Type: models.StepLookup, From: "code", To: "status", Table: map[string]interface{}{"200": "ok"}},
+			},
+			payload:  `{"country": "DE", "code": 200}`,
+			wantType: "application/json",
+			want:     `{"country": "DE", "region": "EU", "code": 200, "status": "ok"}`,
+		},
+		{
+			name: "lookup default",
+			steps: []models.TransformStep{
+				{Type: models.StepLookup, From: "country", To: "region", Table: map[string]interface{}{"DE": "EU"}, Default: "other"},
+			},
+			payload:  `{"country": "BR"}`,
+			wantType: "application/json",
+			want:     `{"country": "BR", "region": "other"}`,
+		},
+		{
+			name: "lookup without match or default",
+			steps: []models.TransformStep{
+				{Type: models.StepLookup, From: "country", To: "region", Table: map[string]interface{}{"DE": "EU"}},
+			},
+			payload:  `{"country": "BR"}`,
+			wantType: "application/json",
+			want:     `{"country": "BR"}`,
+		},
+		{
+			name: "lookup of null and missing fields",
+			steps: []models.TransformStep{
+				{Type: models.StepLookup, From: "a", To: "x", Table: map[string]interface{}{"null": "none"}},
+				{Type: models.StepLookup, From: "missing", To: "y", Table: map[string]interface{}{"null": "none"}, Default: "d"},
+			},
+			payload:  `{"a": null}`,
+			wantType: "application/json",
+			want:     `{"a": null, "x": "none"}`,
+		},
+		{
+			name:        "steps apply in order",
+			steps:       []models.TransformStep{{Type: models.StepSet, Field: "a", Value: 1.0}, {Type: models.StepRename, From: "a", To: "b"}},
+			contentType: "application/vnd.orders+json",
+			payload:     `{}`,
+			wantType:    "application/vnd.orders+json",
+			want:        `{"b": 1}`,
+		},
+		{
+			name:        "form stays form",
+			steps:       []models.TransformStep{{Type: models.StepRename, From: "q", To: "query"}},
+			contentType: "application/x-www-form-urlencoded",
+			payload:     "q=go&tag=a&tag=b",
+			wantType:    "application/x-www-form-urlencoded",
+			want:        "query=go&tag=a&tag=b",
+		},
+		{
+			name:     "json to form",
+			steps:    []models.TransformStep{{Type: models.StepFormat, Format: models.FormatForm}},
+			payload:  `{"user": {"name": "ann", "age": 30}, "tags": ["a", "b"], "ok": true, "none": null}`,
+			wantType: "application/x-www-form-urlencoded",
+			want:     "none=&ok=true&tags=a&tags=b&user.age=30&user.name=ann",
+		},
+		{
+			name:        "form to json",
+			steps:       []models.TransformStep{{Type: models.StepFormat, Format: models.FormatJSON}},
+			contentType: "application/x-www-form-urlencoded",
+			payload:     "a=1&b=x&b=y",
+			wantType:    "application/json",
+			want:        `{"a": "1", "b": ["x", "y"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.steps)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			msg := &models.Message{Payload: []byte(tt.payload), ContentType: contentType}
+			if err := p.Apply(msg); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if msg.ContentType != tt.wantType {
+				t.Errorf("content type = %q, want %q", msg.ContentType, tt.wantType)
+			}
+			if strings.HasSuffix(tt.wantType, "json") {
+				assertJSON(t, msg.Payload, tt.want)
+			} else if string(msg.Payload) != tt.want {
+				t.Errorf("payload = %s, want %s", msg.Payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyUnsupportedPayload(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		payload         string
+	}{
+		{"compressed", "application/json", "gzip", `{"a": 1}`},
+		{"plain text", "text/plain", "", `{"a": 1}`},
+		{"missing content type", "", "", `{"a": 1}`},
+		{"invalid JSON", "application/json", "", `{"a": `},
+		{"JSON array", "application/json", "", `[1, 2]`},
+		{"JSON null", "application/json", "", `null`},
+	}
+	p, err := Compile([]models.TransformStep{{Type: models.StepSet, Field: "a", Value: 2.0}})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &models.Message{Payload: []byte(tt.payload), ContentType: tt.contentType, ContentEncoding: tt.contentEncoding}
+			err := p.Apply(msg)
+			if !errors.Is(err, ErrUnsupportedPayload) {
+				t.Fatalf("Apply error = %v, want %v", err, ErrUnsupportedPayload)
+			}
+			if string(msg.Payload) != tt.payload || msg.ContentType != tt.contentType {
+				t.Errorf("failed Apply changed the message to %q, %s", msg.ContentType, msg.Payload)
+			}
+		})
+	}
+}
+
+func TestApplyInvalidForm(t *testing.T) {
+	p, err := Compile(nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	msg := &models.Message{Payload: []byte("a=%zz"), ContentType: "application/x-www-form-urlencoded"}
+	if err := p.Apply(msg); err == nil || !strings.Contains(err.Error(), "invalid form payload") {
+		t.Fatalf("Apply error = %v, want an invalid form payload error", err)
+	}
+}
+
+// TestApplyDoesNotShareValues checks that a message cannot change the values
+// a pipeline sets in the next one.
+func TestApplyDoesNotShareValues(t *testing.T) {
+	p, err := Compile([]models.TransformStep{
+		{Type: models.StepSet, Field: "meta", Value: map[string]interface{}{"source": "api"}},
+		{Type: models.StepSet, Field: "meta.seen", Value: true},
+	})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		msg := &models.Message{Payload: []byte(`{}`), ContentType: "application/json"}
+		if err := p.Apply(msg); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		assertJSON(t, msg.Payload, `{"meta": {"source": "api", "seen": true}}`)
+	}
+	if got := p.steps[0].Value; !reflect.DeepEqual(got, map[string]interface{}{"source": "api"}) {
+		t.Errorf("pipeline value changed to %v", got)
+	}
+}
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("payload %s is not JSON: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+}
@@ -21,4 +21,80 @@ var (
 		Help:    "Time spent processing messages",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"tenant_id"})
+
+	RetentionPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_messages_purged_total",
+		Help: "Messages removed by tenant retention policies",
+	}, []string{"tenant_id", "action"})
+
+	RetentionLastRun = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "retention_last_run_timestamp_seconds",
+		Help: "Unix time the retention janitor last finished a run",
+	})
+
+	MessagesArchived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "messages_archived_total",
+		Help: "Messages moved to cold archive segments",
+	}, []string{"tenant_id"})
+
+	InboxDuplicates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "inbox_duplicates_total",
+		Help: "Redelivered messages skipped by the tenant inbox",
+	}, []string{"tenant_id"})
+
+	ErasureJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "erasure_jobs_total",
+		Help: "Tenant data erasure jobs by final status",
+	}, []string{"status"})
+
+	RuleHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "routing_rule_hits_total",
+		Help: "Published messages matched by tenant routing rules",
+	}, []string{"tenant_id", "rule"})
+
+	BatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "consumer_batch_size",
+		Help:    "Deliveries collected per batch by batch consumers",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+	}, []string{"tenant_id"})
+
+	AutoscaleQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "autoscaler_queue_depth",
+		Help: "Ready messages in the tenant queue at the last autoscaler sample",
+	}, []string{"tenant_id"})
+
+	AutoscaleUtilization = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "autoscaler_worker_utilization",
+		Help: "Fraction of time the tenant's workers spent handling messages between autoscaler samples",
+	}, []string{"tenant_id"})
+
+	AutoscaleLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "autoscaler_processing_latency_seconds",
+		Help: "Average handler call duration between autoscaler samples",
+	}, []string{"tenant_id"})
+
+	AutoscaleDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "autoscaler_decisions_total",
+		Help: "Worker count changes made by the autoscaler",
+	}, []string{"tenant_id", "direction"})
+
+	PoolBusyWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pool_busy_workers",
+		Help: "Workers of the shared pool handling a message",
+	})
+
+	PoolDispatched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pool_dispatched_total",
+		Help: "Messages the shared worker pool handed to a worker",
+	}, []string{"tenant_id"})
+
+	TenantPaused = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tenant_paused",
+		Help: "Whether consumption of the tenant's messages is paused (1) or not (0)",
+	}, []string{"tenant_id"})
+
+	TenantState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tenant_state",
+		Help: "Lifecycle state of the tenant's consumers: 1 for the current state, 0 for the others",
+	}, []string{"tenant_id", "state"})
 )